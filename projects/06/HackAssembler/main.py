@@ -1,11 +1,12 @@
 import argparse
-import re
+import os
+import sys
 from os import path
 
 from instuction import AInstruction, CInstruction, Label
 from parser import parse_line
+from preprocessor import MAX_ADDRESS, AssemblyError, Preprocessor
 
-COMMENT_FORMAT = "//.*"
 SYMBOLS = {
     "R0": 0,
     "R1": 1,
@@ -42,6 +43,9 @@ C_COMP_BINARY = {
     "!D": "0001101",
     "!A": "0110001",
     "!M": "1110001",
+    "-D": "0001111",
+    "-A": "0110011",
+    "-M": "1110011",
     "D+1": "0011111",
     "A+1": "0110111",
     "M+1": "1110111",
@@ -72,14 +76,10 @@ C_JUMP_BINARY = {
 
 def parse_file(input_file_name):
     instructions = []
-    with open(input_file_name, "r") as file:
-        for line in file:
-            # Strip comments and whitespace
-            line = re.sub(COMMENT_FORMAT, "", line).strip()
-            if not line:
-                continue
-
-            instructions.append(parse_line(line))
+    for line_number, line in Preprocessor().preprocess(input_file_name):
+        instruction = parse_line(line)
+        instruction.line_number = line_number
+        instructions.append(instruction)
 
     return instructions
 
@@ -100,7 +100,13 @@ def resolve_labels(instructions):
     return symbols
 
 
-def write_binary(instructions, symbols, file):
+# Writes the binary for each instruction, returning the addresses and words written for each source line,
+# of which there can be several once macros are expanded, and the variables allocated along the way.
+# Raises an AssemblyError naming file_name for a number too large for an A-instruction.
+def write_binary(file_name, instructions, symbols, file):
+    words = {}
+    address = 0
+    variables = {}
     symbol_mem_add_counter = SYMBOL_MEM_ADDR_COUNTER
     for instruction in instructions:
         if isinstance(instruction, Label):
@@ -113,10 +119,14 @@ def write_binary(instructions, symbols, file):
                 number = symbols[instruction.symbol]
             else:
                 symbols[instruction.symbol] = symbol_mem_add_counter
+                variables[instruction.symbol] = symbol_mem_add_counter
                 number = symbol_mem_add_counter
                 symbol_mem_add_counter = symbol_mem_add_counter + 1
 
-            file.write("0" + "{0:015b}".format(number) + "\n")
+            if number > MAX_ADDRESS:
+                raise AssemblyError(file_name, instruction.line_number,
+                                    "{0} does not fit in an A-instruction, the largest is {1}".format(number, MAX_ADDRESS))
+            word = "0" + "{0:015b}".format(number)
 
         if isinstance(instruction, CInstruction):
             prefix = "111"
@@ -124,7 +134,40 @@ def write_binary(instructions, symbols, file):
             dest = convert_c_instruction_dest_to_binary(instruction.dest)
             jump = convert_c_instruction_jump_to_binary(instruction.jump)
 
-            file.write(prefix + comp + dest + jump + "\n")
+            word = prefix + comp + dest + jump
+
+        words.setdefault(instruction.line_number, []).append((address, word))
+        address = address + 1
+        file.write(word + "\n")
+
+    return words, variables
+
+
+# Writes every line of the source alongside the ROM address and binary word of any instruction on it.
+# Lines that expand to several instructions have the rest listed on the lines after.
+def write_listing(input_file_name, words, file):
+    file.write("{0:>5}  {1:16}  {2:>5}  {3}\n".format("ROM", "binary", "line", "source"))
+    with open(input_file_name, "r") as source:
+        for line_number, line in enumerate(source, start=1):
+            line_words = words.get(line_number, [])
+            if not line_words:
+                file.write("{0:5}  {1:16}  {2:5}  {3}\n".format("", "", line_number, line.rstrip()))
+                continue
+
+            address, word = line_words[0]
+            file.write("{0:5}  {1}  {2:5}  {3}\n".format(address, word, line_number, line.rstrip()))
+            for address, word in line_words[1:]:
+                file.write("{0:5}  {1}\n".format(address, word))
+
+
+# Writes the labels and variables of the program with their addresses, one "label NAME ADDRESS"
+# or "variable NAME ADDRESS" per line, in the format the Go emulator and debugger read.
+def write_symbols(symbols, variables, file):
+    file.write("// Labels are ROM addresses and variables RAM addresses\n")
+    labels = {symbol: address for symbol, address in symbols.items() if symbol not in SYMBOLS and symbol not in variables}
+    for kind, table in (("label", labels), ("variable", variables)):
+        for symbol, address in sorted(table.items(), key=lambda item: (item[1], item[0])):
+            file.write("{0} {1} {2}\n".format(kind, symbol, address))
 
 
 def convert_c_instruction_dest_to_binary(dest):
@@ -145,14 +188,34 @@ def convert_c_instruction_jump_to_binary(jump):
 if __name__ == "__main__":
     parser = argparse.ArgumentParser()
     parser.add_argument("file", help="File to be assembled.")
+    parser.add_argument("-l", "--listing", action="store_true",
+                        help="Also write a .lst listing of each source line with its ROM address and binary word.")
+    parser.add_argument("-s", "--symbols", action="store_true",
+                        help="Also write a .sym file of the labels and variables with their resolved addresses.")
 
     args = parser.parse_args()
     filename = path.normpath(args.file)
 
-    instructions = parse_file(filename)
+    try:
+        instructions = parse_file(filename)
+    except AssemblyError as e:
+        sys.exit(e)
     symbols = resolve_labels(instructions)
 
     input_filename, _ = path.splitext(filename)
     output_filename = input_filename + ".hack"
-    with open(output_filename, "w") as file:
-        write_binary(instructions, symbols, file)
+    try:
+        with open(output_filename, "w") as file:
+            words, variables = write_binary(filename, instructions, symbols, file)
+    except AssemblyError as e:
+        # Leave no partly written program behind for the emulator to load
+        os.remove(output_filename)
+        sys.exit(e)
+
+    if args.listing:
+        with open(input_filename + ".lst", "w") as file:
+            write_listing(filename, words, file)
+
+    if args.symbols:
+        with open(input_filename + ".sym", "w") as file:
+            write_symbols(symbols, variables, file)
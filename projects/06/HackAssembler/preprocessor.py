@@ -0,0 +1,174 @@
+import re
+from os import path
+
+COMMENT_FORMAT = "//.*"
+INCLUDE_FORMAT = r'^\.include\s+"([^"]+)"$'
+EQU_FORMAT = r"^\.equ\s+([A-Za-z_.$:][\w.$:]*)\s+(\S+)$"
+MACRO_FORMAT = r"^\.macro\s+([A-Za-z_.$:][\w.$:]*)(.*)$"
+MACRO_END = ".endm"
+# A macro body can use \@ to make labels unique to each expansion
+UNIQUE_MARKER = "\\@"
+
+JUMPS = ["JGT", "JEQ", "JGE", "JLT", "JNE", "JLE"]
+# Values the ALU can produce without loading them into A first
+ALU_CONSTANTS = ["0", "1", "-1"]
+# The largest number an A-instruction can load, as its first bit marks it as one
+MAX_ADDRESS = 32767
+
+
+class AssemblyError(Exception):
+    def __init__(self, file_name, line_number, message):
+        super().__init__("{0}:{1}: {2}".format(file_name, line_number, message))
+
+
+class Macro:
+    def __init__(self, name, parameters, body):
+        self.name = name
+        self.parameters = parameters
+        self.body = body
+
+
+# Expands the includes, constants, macros and pseudo-instructions of a file into plain Hack assembly.
+# Returns the lines with comments stripped, each paired with the number of the line of the file it
+# came from. Lines from included files are numbered after the .include line that brought them in.
+class Preprocessor:
+    def __init__(self):
+        self.constants = {}
+        self.macros = {}
+        self.expansions = 0
+
+    def preprocess(self, file_name):
+        return list(self.expand_file(file_name, []))
+
+    # Yields the line number and text of each line of the file after expansion.
+    def expand_file(self, file_name, including):
+        if path.abspath(file_name) in including:
+            raise AssemblyError(file_name, 1, "file includes itself")
+        including = including + [path.abspath(file_name)]
+
+        with open(file_name, "r") as file:
+            lines = list(enumerate(file, start=1))
+
+        macro = None
+        for line_number, line in lines:
+            line = re.sub(COMMENT_FORMAT, "", line).strip()
+            if not line:
+                continue
+
+            # Macro bodies are kept as written until the macro is used
+            if macro is not None:
+                if line == MACRO_END:
+                    self.macros[macro.name] = macro
+                    macro = None
+                else:
+                    macro.body.append(line)
+                continue
+
+            match = re.match(MACRO_FORMAT, line)
+            if match:
+                macro = Macro(match.group(1), split_arguments(match.group(2)), [])
+                continue
+
+            match = re.match(EQU_FORMAT, line)
+            if match:
+                self.constants[match.group(1)] = self.resolve(match.group(2))
+                continue
+
+            match = re.match(INCLUDE_FORMAT, line)
+            if match:
+                included = path.join(path.dirname(file_name), match.group(1))
+                if not path.exists(included):
+                    raise AssemblyError(file_name, line_number, "cannot include " + match.group(1))
+                for _, expanded in self.expand_file(included, including):
+                    yield line_number, expanded
+                continue
+
+            try:
+                for expanded in self.expand_line(line, []):
+                    yield line_number, expanded
+            except ValueError as e:
+                raise AssemblyError(file_name, line_number, str(e))
+
+        if macro is not None:
+            raise AssemblyError(file_name, len(lines), "missing " + MACRO_END + " for macro " + macro.name)
+
+    # Returns the Hack instructions a single line stands for. Macros can use other macros, but not themselves.
+    def expand_line(self, line, expanding):
+        words = line.split(None, 1)
+        name = words[0]
+        arguments = split_arguments(words[1] if len(words) > 1 else "")
+
+        if name in self.macros:
+            if name in expanding:
+                raise ValueError("macro {0} uses itself".format(name))
+            return self.expand_macro(self.macros[name], arguments, expanding + [name])
+
+        if name == "LDI":
+            return self.load_immediate(arguments)
+
+        if name == "JMP" or name in JUMPS:
+            if len(arguments) != 1:
+                raise ValueError("{0} expects a label".format(name))
+            condition = "0" if name == "JMP" else "D"
+            return [a_instruction(self.resolve(arguments[0])), condition + ";" + name]
+
+        if name == "HALT":
+            if arguments:
+                raise ValueError("HALT expects no arguments")
+            self.expansions = self.expansions + 1
+            label = "HALT.{0}".format(self.expansions)
+            return ["(" + label + ")", "@" + label, "0;JMP"]
+
+        if line.startswith("@"):
+            return [a_instruction(self.resolve(line[1:]))]
+
+        return [line]
+
+    def expand_macro(self, macro, arguments, expanding):
+        if len(arguments) != len(macro.parameters):
+            raise ValueError("macro {0} expects {1} arguments, got {2}".format(
+                macro.name, len(macro.parameters), len(arguments)))
+
+        self.expansions = self.expansions + 1
+        values = dict(zip(macro.parameters, arguments))
+        replace = (lambda match: values.get(match.group(0), match.group(0)))
+
+        expanded = []
+        for line in macro.body:
+            line = line.replace(UNIQUE_MARKER, "." + str(self.expansions))
+            line = re.sub(r"[A-Za-z_.$:][\w.$:]*", replace, line)
+            expanded.extend(self.expand_line(line, expanding))
+
+        return expanded
+
+    # LDI dest, value sets the A and or D registers to a constant, using the ALU alone for 0, 1 and -1.
+    def load_immediate(self, arguments):
+        if len(arguments) != 2 or arguments[0] not in ("A", "D", "AD"):
+            raise ValueError("LDI expects A, D or AD and a value")
+        dest, value = arguments[0], self.resolve(arguments[1])
+
+        if value in ALU_CONSTANTS:
+            return [dest + "=" + value]
+        if value.startswith("-") and value[1:].isnumeric():
+            # A-instructions only load positive numbers, so negative ones are loaded and negated
+            if int(value[1:]) > MAX_ADDRESS:
+                raise ValueError("LDI cannot load {0}, the smallest value is -{1}".format(value, MAX_ADDRESS))
+            return [a_instruction(value[1:]), dest + "=-A"]
+        if dest == "A":
+            return [a_instruction(value)]
+        return [a_instruction(value), dest + "=A"]
+
+    # Returns the value of a constant, or the symbol itself if it is not one.
+    def resolve(self, symbol):
+        return self.constants.get(symbol, symbol)
+
+
+# Returns the A-instruction loading value, which must fit in 15 bits if it is a number.
+def a_instruction(value):
+    if value.isnumeric() and int(value) > MAX_ADDRESS:
+        raise ValueError("{0} does not fit in an A-instruction, the largest is {1}".format(value, MAX_ADDRESS))
+    return "@" + value
+
+
+def split_arguments(text):
+    return [argument.strip() for argument in text.split(",") if argument.strip()]
@@ -0,0 +1,95 @@
+import io
+import os
+import tempfile
+import unittest
+
+from instuction import AInstruction
+from main import write_binary
+from preprocessor import AssemblyError, Preprocessor
+
+
+class PreprocessorTest(unittest.TestCase):
+    def setUp(self):
+        self.directory = tempfile.TemporaryDirectory()
+
+    def tearDown(self):
+        self.directory.cleanup()
+
+    # Writes the named files into the test directory, returning the path of the first.
+    def write(self, files):
+        paths = []
+        for name, source in files.items():
+            paths.append(os.path.join(self.directory.name, name))
+            with open(paths[-1], "w") as file:
+                file.write(source)
+        return paths[0]
+
+    def preprocess(self, source, files=None):
+        name = self.write({"Main.asm": source, **(files or {})})
+        return [line for _, line in Preprocessor().preprocess(name)]
+
+    def test_macro(self):
+        lines = self.preprocess(".macro ADD x, y // x = x + y\n@y\nD=M\n@x\nM=D+M\n.endm\nADD i, sum\n")
+        self.assertEqual(lines, ["@sum", "D=M", "@i", "M=D+M"])
+
+    def test_macro_arguments(self):
+        with self.assertRaises(AssemblyError):
+            self.preprocess(".macro INC x\n@x\nM=M+1\n.endm\nINC a, b\n")
+
+    def test_macro_uses_itself(self):
+        with self.assertRaises(AssemblyError):
+            self.preprocess(".macro LOOP\nLOOP\n.endm\nLOOP\n")
+
+    def test_missing_endm(self):
+        with self.assertRaises(AssemblyError):
+            self.preprocess(".macro INC x\n@x\n")
+
+    def test_unique_labels(self):
+        lines = self.preprocess(".macro WAIT\n(WAIT\\@)\n@WAIT\\@\n0;JMP\n.endm\nWAIT\nWAIT\n")
+        self.assertEqual(lines, ["(WAIT.1)", "@WAIT.1", "0;JMP", "(WAIT.2)", "@WAIT.2", "0;JMP"])
+
+    def test_include(self):
+        lines = self.preprocess('.include "lib.asm"\nINC i\n', {"lib.asm": ".equ COUNT 5\n.macro INC x\n@x\nM=M+1\n.endm\n@COUNT\n"})
+        self.assertEqual(lines, ["@5", "@i", "M=M+1"])
+
+    def test_include_cycle(self):
+        with self.assertRaises(AssemblyError):
+            self.preprocess('.include "Main.asm"\n')
+        with self.assertRaises(AssemblyError):
+            self.preprocess('.include "a.asm"\n', {"a.asm": '.include "Main.asm"\n'})
+
+    def test_include_missing(self):
+        with self.assertRaises(AssemblyError):
+            self.preprocess('.include "missing.asm"\n')
+
+    def test_equ(self):
+        lines = self.preprocess(".equ LIMIT 100\n.equ TOP LIMIT\n@TOP\nJGT LIMIT\nLDI D, TOP\n")
+        self.assertEqual(lines, ["@100", "@100", "D;JGT", "@100", "D=A"])
+
+    def test_ldi(self):
+        tests = {
+            "LDI D, 0": ["D=0"],
+            "LDI AD, -1": ["AD=-1"],
+            "LDI A, 7": ["@7"],
+            "LDI D, -5": ["@5", "D=-A"],
+            "LDI D, 32767": ["@32767", "D=A"],
+            "LDI D, -32767": ["@32767", "D=-A"],
+            "LDI D, sum": ["@sum", "D=A"],
+        }
+        for line, expected in tests.items():
+            self.assertEqual(self.preprocess(line + "\n"), expected, line)
+
+    def test_ldi_errors(self):
+        for line in ("LDI D, 32768", "LDI D, -32768", "LDI M, 1", "LDI D"):
+            with self.assertRaises(AssemblyError, msg=line):
+                self.preprocess(line + "\n")
+
+    def test_write_binary_range(self):
+        instruction = AInstruction("40000")
+        instruction.line_number = 3
+        with self.assertRaises(AssemblyError):
+            write_binary("Main.asm", [instruction], {}, io.StringIO())
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,60 @@
+package cfg
+
+import (
+	"github.com/ChelseaDH/VMTranslator/command"
+	"github.com/ChelseaDH/VMTranslator/program"
+)
+
+// Call is an edge of the call graph, counting the call sites in Caller that call Callee.
+type Call struct {
+	Caller string `json:"caller"`
+	Callee string `json:"callee"`
+	Count  int    `json:"count"`
+}
+
+// CallGraph records which functions call which, with functions listed in declaration order
+// followed by any that are called but never declared.
+type CallGraph struct {
+	Functions []string `json:"functions"`
+	Calls     []Call   `json:"calls"`
+}
+
+// BuildCallGraph links each declared function to the functions it calls.
+// Calls made outside of any function are attributed to a caller with no name.
+func BuildCallGraph(functions []program.Function) *CallGraph {
+	cg := &CallGraph{}
+	known := make(map[string]bool)
+	edges := make(map[Call]int)
+
+	for _, f := range functions {
+		if f.Name != "" && !known[f.Name] {
+			known[f.Name] = true
+			cg.Functions = append(cg.Functions, f.Name)
+		}
+	}
+
+	for _, f := range functions {
+		for _, line := range f.Body {
+			if line.Command.Type() != command.Call {
+				continue
+			}
+
+			callee := line.Command.(*command.FunctionCommand).Name
+			if !known[callee] {
+				known[callee] = true
+				cg.Functions = append(cg.Functions, callee)
+			}
+
+			key := Call{Caller: f.Name, Callee: callee}
+			index, ok := edges[key]
+			if !ok {
+				index = len(cg.Calls)
+				edges[key] = index
+				cg.Calls = append(cg.Calls, key)
+			}
+			cg.Calls[index].Count++
+		}
+	}
+
+	return cg
+}
@@ -0,0 +1,122 @@
+package cfg
+
+import (
+	"strings"
+
+	"github.com/ChelseaDH/VMTranslator/command"
+	"github.com/ChelseaDH/VMTranslator/program"
+)
+
+// Block is a basic block: a run of commands only entered at its first command and only left after its last.
+type Block struct {
+	Index      int
+	Lines      []program.Line
+	Successors []int
+	// FallsOff is set when control can run past the last command of the function from this block.
+	FallsOff bool
+}
+
+// Label returns the name of the label starting the block, if there is one.
+func (b *Block) Label() string {
+	if len(b.Lines) == 0 || b.Lines[0].Command.Type() != command.Label {
+		return ""
+	}
+
+	return b.Lines[0].Command.(*command.BranchingCommand).Label
+}
+
+// Graph is the control flow graph of a single VM function.
+type Graph struct {
+	Function program.Function
+	Blocks   []*Block
+	// Labels maps each label declared in the function to the index of the block it starts.
+	Labels map[string]int
+}
+
+// LabelKey normalises a label name the way the translator does, as labels are written to assembly in upper case.
+func LabelKey(label string) string {
+	return strings.ToUpper(label)
+}
+
+// Build splits the body of f into basic blocks and links them by label, goto, if-goto and return.
+// Jumps to labels that are not declared in f are left without an edge.
+func Build(f program.Function) *Graph {
+	g := &Graph{
+		Function: f,
+		Labels:   make(map[string]int),
+	}
+
+	var current *Block
+	for _, line := range f.Body {
+		if current == nil || line.Command.Type() == command.Label {
+			current = &Block{Index: len(g.Blocks)}
+			g.Blocks = append(g.Blocks, current)
+		}
+
+		current.Lines = append(current.Lines, line)
+		if line.Command.Type() == command.Label {
+			key := LabelKey(line.Command.(*command.BranchingCommand).Label)
+			if _, ok := g.Labels[key]; !ok {
+				g.Labels[key] = current.Index
+			}
+		}
+
+		switch line.Command.Type() {
+		case command.Goto, command.IfGoto, command.Return:
+			current = nil
+		}
+	}
+
+	for _, b := range g.Blocks {
+		last := b.Lines[len(b.Lines)-1].Command
+		next := b.Index + 1
+
+		switch last.Type() {
+		case command.Return:
+			continue
+
+		case command.Goto, command.IfGoto:
+			target, ok := g.Labels[LabelKey(last.(*command.BranchingCommand).Label)]
+			if ok {
+				b.Successors = append(b.Successors, target)
+			}
+			if last.Type() == command.Goto {
+				continue
+			}
+		}
+
+		if next < len(g.Blocks) {
+			if len(b.Successors) == 0 || b.Successors[0] != next {
+				b.Successors = append(b.Successors, next)
+			}
+		} else {
+			b.FallsOff = true
+		}
+	}
+
+	return g
+}
+
+// Reachable returns whether each block can be reached from the start of the function.
+func (g *Graph) Reachable() []bool {
+	reachable := make([]bool, len(g.Blocks))
+	if len(g.Blocks) == 0 {
+		return reachable
+	}
+
+	work := []int{0}
+	reachable[0] = true
+	for len(work) > 0 {
+		b := g.Blocks[work[len(work)-1]]
+		work = work[:len(work)-1]
+
+		for _, s := range b.Successors {
+			if !reachable[s] {
+				reachable[s] = true
+				work = append(work, s)
+			}
+		}
+	}
+
+	return reachable
+}
@@ -0,0 +1,74 @@
+package cfg
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ChelseaDH/VMTranslator/program"
+)
+
+const whileLoop = `function Main.loop 1
+push constant 0
+pop local 0
+label WHILE_EXP0
+push local 0
+push constant 10
+lt
+not
+if-goto WHILE_END0
+push local 0
+call Main.step 1
+pop local 0
+goto WHILE_EXP0
+label WHILE_END0
+call Main.step 1
+return`
+
+type buildTest struct {
+	input           string
+	expSuccessors   [][]int
+	expInstructions []int
+	expCalls        []Call
+}
+
+var buildTests = []buildTest{
+	{
+		input:           whileLoop,
+		expSuccessors:   [][]int{{1}, {3, 2}, {1}, nil},
+		expInstructions: []int{2, 5, 4, 2},
+		expCalls:        []Call{{Caller: "Main.loop", Callee: "Main.step", Count: 2}},
+	},
+}
+
+func TestBuild(t *testing.T) {
+	for _, test := range buildTests {
+		lines, err := program.Read("test.vm", strings.NewReader(test.input))
+		if err != nil {
+			t.Fatalf("unexpected error %q", err)
+		}
+
+		functions := program.Functions(lines)
+		g := Build(functions[0])
+
+		var successors [][]int
+		var instructions []int
+		for _, b := range g.Blocks {
+			successors = append(successors, b.Successors)
+			instructions = append(instructions, b.Instructions())
+		}
+
+		if !reflect.DeepEqual(test.expSuccessors, successors) {
+			t.Errorf("expected successors %v got %v", test.expSuccessors, successors)
+		}
+
+		if !reflect.DeepEqual(test.expInstructions, instructions) {
+			t.Errorf("expected instruction counts %v got %v", test.expInstructions, instructions)
+		}
+
+		cg := BuildCallGraph(functions)
+		if !reflect.DeepEqual(test.expCalls, cg.Calls) {
+			t.Errorf("expected calls %v got %v", test.expCalls, cg.Calls)
+		}
+	}
+}
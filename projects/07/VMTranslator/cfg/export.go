@@ -0,0 +1,142 @@
+package cfg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ChelseaDH/VMTranslator/command"
+)
+
+// Instructions returns the number of commands in the block, not counting labels.
+func (b *Block) Instructions() int {
+	count := 0
+	for _, line := range b.Lines {
+		if line.Command.Type() != command.Label {
+			count++
+		}
+	}
+
+	return count
+}
+
+type jsonBlock struct {
+	Index        int    `json:"index"`
+	Label        string `json:"label,omitempty"`
+	Line         int    `json:"line"`
+	Instructions int    `json:"instructions"`
+	Successors   []int  `json:"successors"`
+	FallsOff     bool   `json:"fallsOff,omitempty"`
+}
+
+type jsonFunction struct {
+	Name   string      `json:"name"`
+	File   string      `json:"file"`
+	Line   int         `json:"line"`
+	Locals int         `json:"locals"`
+	Blocks []jsonBlock `json:"blocks"`
+}
+
+type jsonProgram struct {
+	Functions []jsonFunction `json:"functions"`
+	CallGraph *CallGraph     `json:"callGraph"`
+}
+
+// WriteJSON writes the control flow graph of every function along with the call graph as a single JSON document.
+func WriteJSON(w io.Writer, graphs []*Graph, cg *CallGraph) error {
+	p := jsonProgram{CallGraph: cg}
+
+	for _, g := range graphs {
+		f := jsonFunction{
+			Name:   g.Function.Name,
+			File:   g.Function.Declaration.File,
+			Line:   g.Function.Declaration.Number,
+			Locals: g.Function.Locals,
+			Blocks: []jsonBlock{},
+		}
+
+		for _, b := range g.Blocks {
+			successors := b.Successors
+			if successors == nil {
+				successors = []int{}
+			}
+
+			f.Blocks = append(f.Blocks, jsonBlock{
+				Index:        b.Index,
+				Label:        b.Label(),
+				Line:         b.Lines[0].Number,
+				Instructions: b.Instructions(),
+				Successors:   successors,
+				FallsOff:     b.FallsOff,
+			})
+		}
+
+		p.Functions = append(p.Functions, f)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(p)
+}
+
+// WriteDOT writes the control flow graphs as a Graphviz digraph, with one cluster per function.
+func WriteDOT(w io.Writer, graphs []*Graph) error {
+	var b strings.Builder
+
+	b.WriteString("digraph cfg {\n\tnode [shape=box];\n")
+	for i, g := range graphs {
+		name := g.Function.Name
+		if name == "" {
+			name = g.Function.Declaration.File
+		}
+
+		b.WriteString(fmt.Sprintf("\tsubgraph cluster_%d {\n\t\tlabel=%q;\n", i, name))
+		for _, block := range g.Blocks {
+			label := fmt.Sprintf("B%d", block.Index)
+			if block.Label() != "" {
+				label = fmt.Sprintf("%s %s", label, block.Label())
+			}
+			label = fmt.Sprintf("%s\\n%d instructions", label, block.Instructions())
+
+			b.WriteString(fmt.Sprintf("\t\t%q [label=\"%s\"];\n", nodeName(i, block.Index), label))
+		}
+
+		for _, block := range g.Blocks {
+			for _, s := range block.Successors {
+				b.WriteString(fmt.Sprintf("\t\t%q -> %q;\n", nodeName(i, block.Index), nodeName(i, s)))
+			}
+		}
+		b.WriteString("\t}\n")
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func nodeName(function int, block int) string {
+	return fmt.Sprintf("f%d_b%d", function, block)
+}
+
+// WriteDOT writes the call graph as a Graphviz digraph, labelling edges with the number of call sites.
+func (cg *CallGraph) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+
+	b.WriteString("digraph calls {\n\tnode [shape=box];\n")
+	for _, f := range cg.Functions {
+		b.WriteString(fmt.Sprintf("\t%q;\n", f))
+	}
+
+	for _, c := range cg.Calls {
+		caller := c.Caller
+		if caller == "" {
+			caller = "<top level>"
+		}
+		b.WriteString(fmt.Sprintf("\t%q -> %q [label=\"%d\"];\n", caller, c.Callee, c.Count))
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
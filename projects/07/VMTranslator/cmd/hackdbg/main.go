@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path"
+
+	"github.com/ChelseaDH/VMTranslator/debugger"
+	"github.com/ChelseaDH/VMTranslator/hack"
+	"github.com/ChelseaDH/VMTranslator/keyboard"
+	"github.com/ChelseaDH/VMTranslator/snapshot"
+)
+
+func main() {
+	history := flag.Int("history", 100000, "number of instructions that can be stepped back over")
+	limit := flag.Uint64("limit", 0, "most instructions run by continue and until, 0 for no limit")
+	keys := flag.String("keys", "", "keyboard script to replay, with steps counted in instructions")
+	snapshotFile := flag.String("snapshot", "", "snapshot saved by hackdbg to start the program from")
+	symbols := flag.String("symbols", "", "symbol file written by the assembler to name labels and variables in a .hack file from")
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) != 1 {
+		log.Fatal("A .asm or .hack file must be provided")
+	}
+
+	file, err := os.Open(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var p *hack.Program
+	if path.Ext(args[0]) == ".hack" {
+		p, err = hack.Load(file)
+	} else {
+		p, err = hack.Assemble(file)
+	}
+	file.Close()
+	if err != nil {
+		log.Fatalf("%s: %s", args[0], err)
+	}
+
+	if *symbols != "" {
+		file, err := os.Open(*symbols)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = p.ReadSymbols(file)
+		file.Close()
+		if err != nil {
+			log.Fatalf("%s: %s", *symbols, err)
+		}
+	}
+
+	d := debugger.New(p, *history)
+	d.Limit = *limit
+
+	if *keys != "" {
+		script, err := keyboard.ReadFile(*keys)
+		if err != nil {
+			log.Fatal(err)
+		}
+		d.CPU.KeyboardInput = func() int16 { return script.Key(d.CPU.Cycles) }
+	}
+
+	if *snapshotFile != "" {
+		file, err := os.Open(*snapshotFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cpu, err := snapshot.LoadCPU(file)
+		file.Close()
+		if err == nil {
+			err = d.Restore(cpu)
+		}
+		if err != nil {
+			log.Fatalf("%s: %s", *snapshotFile, err)
+		}
+	}
+
+	fmt.Printf("Loaded %d instructions, type help for a list of commands\n", len(p.ROM))
+	input := bufio.NewScanner(os.Stdin)
+	last := ""
+	for {
+		fmt.Print("(hackdbg) ")
+		if !input.Scan() {
+			fmt.Println()
+			return
+		}
+
+		// An empty line repeats the previous command
+		line := input.Text()
+		if line == "" {
+			line = last
+		}
+		last = line
+
+		quit, err := d.Execute(line, os.Stdout)
+		if err != nil {
+			fmt.Println(err)
+		}
+		if quit {
+			return
+		}
+	}
+}
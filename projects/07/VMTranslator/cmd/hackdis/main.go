@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/ChelseaDH/VMTranslator/hack"
+)
+
+func main() {
+	symbols := flag.String("symbols", "", "symbol file to name labels and variables from")
+	plain := flag.Bool("plain", false, "leave out the address and binary word comments, so the output can be reassembled")
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) != 1 {
+		log.Fatal("A .hack file must be provided")
+	}
+
+	file, err := os.Open(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	p, err := hack.Load(file)
+	file.Close()
+	if err != nil {
+		log.Fatalf("%s: %s", args[0], err)
+	}
+
+	if *symbols != "" {
+		file, err := os.Open(*symbols)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = p.ReadSymbols(file)
+		file.Close()
+		if err != nil {
+			log.Fatalf("%s: %s", *symbols, err)
+		}
+	}
+
+	d := hack.Disassemble(p)
+	err = d.Write(os.Stdout, !*plain)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(d.Invalid) > 0 {
+		log.Printf("%d words are not valid instructions, the first at address %d", len(d.Invalid), d.Invalid[0])
+	}
+}
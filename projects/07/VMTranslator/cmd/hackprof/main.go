@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/ChelseaDH/VMTranslator/hack"
+	"github.com/ChelseaDH/VMTranslator/profile"
+)
+
+func main() {
+	limit := flag.Uint64("limit", 0, "most instructions to run, 0 to run until the program halts")
+	tree := flag.Bool("tree", false, "print the call tree instead of the flat profile")
+	minimum := flag.Float64("min", 0.5, "leave calls costing less than this percentage of the program out of the call tree")
+	pprof := flag.String("pprof", "", "also write a profile that go tool pprof can read to this file")
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) != 1 {
+		log.Fatal("A .asm file translated by VMTranslator must be provided")
+	}
+
+	file, err := os.Open(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	p, err := hack.Assemble(file)
+	file.Close()
+	if err != nil {
+		log.Fatalf("%s: %s", args[0], err)
+	}
+
+	pr := profile.New(p)
+	err = pr.Run(*limit)
+	if err != nil && err != hack.ErrHalted {
+		log.Fatal(err)
+	}
+	pr.Finish()
+
+	if *tree {
+		err = pr.WriteTree(os.Stdout, *minimum)
+	} else {
+		err = pr.WriteFlat(os.Stdout)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *pprof != "" {
+		output, err := os.Create(*pprof)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer output.Close()
+
+		err = pr.WritePprof(output)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
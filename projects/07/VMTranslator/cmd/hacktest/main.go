@@ -0,0 +1,228 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/ChelseaDH/VMTranslator/golden"
+	"github.com/ChelseaDH/VMTranslator/hack"
+	"github.com/ChelseaDH/VMTranslator/keyboard"
+	"github.com/ChelseaDH/VMTranslator/program"
+	"github.com/ChelseaDH/VMTranslator/screen"
+	"github.com/ChelseaDH/VMTranslator/snapshot"
+	"github.com/ChelseaDH/VMTranslator/vm"
+)
+
+// machine is a program loaded on the CPU emulator or the VM interpreter.
+type machine interface {
+	// Resume runs the program until it halts, failing if it has not once limit more steps have run.
+	Resume(limit uint64) error
+	State() golden.State
+	// Count describes how long the program has run for.
+	Count() string
+	ReplayKeys(script *keyboard.Script)
+}
+
+type cpuMachine struct {
+	p   *hack.Program
+	cpu *hack.CPU
+}
+
+func (c cpuMachine) Resume(limit uint64) error {
+	return golden.Resume(c.p, c.cpu, limit)
+}
+
+func (c cpuMachine) State() golden.State {
+	return golden.CPUState(c.p, c.cpu)
+}
+
+func (c cpuMachine) Count() string {
+	return fmt.Sprintf("%d instructions", c.cpu.Cycles)
+}
+
+func (c cpuMachine) ReplayKeys(script *keyboard.Script) {
+	c.cpu.KeyboardInput = func() int16 { return script.Key(c.cpu.Cycles) }
+}
+
+type vmMachine struct {
+	m *vm.Machine
+}
+
+func (v vmMachine) Resume(limit uint64) error {
+	return golden.ResumeVM(v.m, limit)
+}
+
+func (v vmMachine) State() golden.State {
+	return golden.VMState(v.m)
+}
+
+func (v vmMachine) Count() string {
+	return fmt.Sprintf("%d commands", v.m.Steps)
+}
+
+func (v vmMachine) ReplayKeys(script *keyboard.Script) {
+	v.m.KeyboardInput = func() int16 { return script.Key(v.m.Steps) }
+}
+
+func main() {
+	limit := flag.Uint64("limit", 100000000, "most instructions or VM commands to run before a program that has not halted fails")
+	update := flag.Bool("update", false, "rewrite the golden files that do not match rather than failing")
+	screenDir := flag.String("screen", "", "directory to write the screen of each program to when it halts, as Name.png")
+	keys := flag.String("keys", "", "keyboard script to replay, with steps counted in instructions or VM commands")
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		log.Fatal("At least one .asm, .hack or .vm file, or directory containing .vm files, must be provided")
+	}
+
+	var script *keyboard.Script
+	if *keys != "" {
+		var err error
+		script, err = keyboard.ReadFile(*keys)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	failed := 0
+	for _, name := range args {
+		m, err := start(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if script != nil {
+			m.ReplayKeys(script)
+		}
+
+		err = m.Resume(*limit)
+		if err != nil {
+			fmt.Printf("FAIL %s: %s\n", name, err)
+			failed++
+			continue
+		}
+
+		state := m.State()
+		if *screenDir != "" {
+			err = screen.WriteFile(path.Join(*screenDir, path.Base(golden.Base(name))+".png"), state.RAM[screen.Base:screen.Base+screen.Words])
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		mismatches, err := golden.Check(state, name, *update)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(mismatches) > 0 {
+			fmt.Printf("FAIL %s after %s\n", name, m.Count())
+			for _, m := range mismatches {
+				fmt.Println(m)
+			}
+			failed++
+			continue
+		}
+
+		fmt.Printf("ok   %s after %s\n", name, m.Count())
+	}
+
+	if failed > 0 {
+		fmt.Printf("%d of %d programs failed\n", failed, len(args))
+		os.Exit(1)
+	}
+}
+
+// start loads the program file or directory name, ready to run from reset or from its snapshot if it has one.
+// .asm and .hack files run on the CPU emulator, and .vm files and directories on the VM interpreter.
+func start(name string) (machine, error) {
+	snapshotName := golden.Base(name) + golden.SnapshotExt
+	file, err := os.Open(snapshotName)
+	if errors.Is(err, os.ErrNotExist) {
+		file = nil
+	} else if err != nil {
+		return nil, err
+	} else {
+		defer file.Close()
+	}
+
+	if ext := path.Ext(name); ext != ".asm" && ext != ".hack" {
+		lines, err := program.ReadPath(name)
+		if err != nil {
+			return nil, err
+		}
+		if file == nil {
+			m, err := vm.New(lines)
+			return vmMachine{m}, err
+		}
+
+		m, err := snapshot.LoadVM(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", snapshotName, err)
+		}
+		if !snapshot.SameProgram(m.Lines, lines) {
+			return nil, fmt.Errorf("%s is a snapshot of a different program", snapshotName)
+		}
+		return vmMachine{m}, nil
+	}
+
+	p, err := load(name)
+	if err != nil {
+		return nil, err
+	}
+	if file == nil {
+		return cpuMachine{p, hack.NewCPU(p)}, nil
+	}
+
+	cpu, err := snapshot.LoadCPU(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", snapshotName, err)
+	}
+	if !snapshot.SameROM(cpu.ROM, p.ROM) {
+		return nil, fmt.Errorf("%s is a snapshot of a different program", snapshotName)
+	}
+	return cpuMachine{p, cpu}, nil
+}
+
+// load assembles a .asm file, or loads a .hack file along with the symbol file the assembler wrote next to it
+// if there is one, which names the Sys.halt label and the program's variables.
+func load(name string) (*hack.Program, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if path.Ext(name) != ".hack" {
+		p, err := hack.Assemble(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		return p, nil
+	}
+
+	p, err := hack.Load(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+
+	symbols := strings.TrimSuffix(name, ".hack") + ".sym"
+	symbolFile, err := os.Open(symbols)
+	if errors.Is(err, os.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer symbolFile.Close()
+
+	err = p.ReadSymbols(symbolFile)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", symbols, err)
+	}
+	return p, nil
+}
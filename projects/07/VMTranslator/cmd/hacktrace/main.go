@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/ChelseaDH/VMTranslator/hack"
+	"github.com/ChelseaDH/VMTranslator/keyboard"
+	"github.com/ChelseaDH/VMTranslator/program"
+	"github.com/ChelseaDH/VMTranslator/screen"
+	"github.com/ChelseaDH/VMTranslator/trace"
+	"github.com/ChelseaDH/VMTranslator/vm"
+)
+
+const (
+	cpuColumns = "time%S1.6.1 PC%D1.5.1 A%D1.6.1 D%D1.6.1"
+	vmColumns  = "time%S1.6.1 sp%D1.6.1 top%D1.6.1"
+)
+
+// emulator is a CPU or VM being traced.
+type emulator interface {
+	trace.Machine
+	Step() error
+	Stopped() bool
+	Set(name string, value int16) error
+	Screen() []int16
+	ReplayKeys(script *keyboard.Script)
+}
+
+// settings is a repeatable flag of NAME=VALUE variables to set before the trace starts.
+type settings []string
+
+func (s *settings) String() string {
+	return strings.Join(*s, " ")
+}
+
+func (s *settings) Set(setting string) error {
+	equals := strings.Index(setting, "=")
+	if equals < 1 {
+		return errors.New("expected NAME=VALUE")
+	}
+	if _, err := strconv.ParseInt(setting[equals+1:], 10, 16); err != nil {
+		return fmt.Errorf("invalid value in %s", setting)
+	}
+	*s = append(*s, setting)
+	return nil
+}
+
+// apply sets each variable on e, as the set commands at the start of a test script do.
+func (s settings) apply(e emulator) error {
+	for _, setting := range s {
+		equals := strings.Index(setting, "=")
+		value, _ := strconv.ParseInt(setting[equals+1:], 10, 16)
+		err := e.Set(setting[:equals], int16(value))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type cpuEmulator struct {
+	trace.CPU
+}
+
+func (c cpuEmulator) Step() error {
+	_, err := c.CPU.Step()
+	return err
+}
+
+func (c cpuEmulator) Stopped() bool {
+	return c.Halted()
+}
+
+func (c cpuEmulator) Screen() []int16 {
+	return c.RAM[screen.Base : screen.Base+screen.Words]
+}
+
+func (c cpuEmulator) ReplayKeys(script *keyboard.Script) {
+	c.KeyboardInput = func() int16 { return script.Key(c.Cycles) }
+}
+
+type vmEmulator struct {
+	trace.VM
+}
+
+func (m vmEmulator) Stopped() bool {
+	return m.Finished() || m.Halted()
+}
+
+func (m vmEmulator) Screen() []int16 {
+	return m.RAM[screen.Base : screen.Base+screen.Words]
+}
+
+func (m vmEmulator) ReplayKeys(script *keyboard.Script) {
+	m.KeyboardInput = func() int16 { return script.Key(m.Steps) }
+}
+
+func main() {
+	columns := flag.String("list", "", "columns to record, as an output-list command gives them (default \""+cpuColumns+"\" for the CPU, \""+vmColumns+"\" for the VM)")
+	every := flag.Uint64("every", 1, "record a row after every this many instructions or VM commands")
+	limit := flag.Uint64("limit", 1000000, "most instructions or VM commands to run, 0 to run until the program halts")
+	output := flag.String("o", "", "file to write the trace to rather than standard output")
+	compare := flag.String("compare", "", "comparison file to check the trace against, as a course test script would")
+	keys := flag.String("keys", "", "keyboard script to replay, with steps counted in instructions or VM commands")
+	screenFile := flag.String("screen", "", "file to write the screen to when the program stops, as a PNG image or PBM if it ends in .pbm")
+	screenEvery := flag.Uint64("screen-every", 0, "also write the screen after every this many instructions or VM commands, adding the count to the -screen file name")
+	var set settings
+	flag.Var(&set, "set", "variable to set before the trace starts, as NAME=VALUE with a name from -list such as RAM[0]=6, repeatable")
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		log.Fatal("A .asm or .hack file, or at least one .vm file or directory containing .vm files, must be provided")
+	}
+	if *every == 0 {
+		log.Fatal("-every must be at least 1")
+	}
+	if *screenEvery != 0 && *screenFile == "" {
+		log.Fatal("-screen-every needs a -screen file")
+	}
+
+	e, err := load(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *keys != "" {
+		script, err := keyboard.ReadFile(*keys)
+		if err != nil {
+			log.Fatal(err)
+		}
+		e.ReplayKeys(script)
+	}
+	err = set.apply(e)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *columns == "" {
+		*columns = cpuColumns
+		if _, ok := e.(vmEmulator); ok {
+			*columns = vmColumns
+		}
+	}
+	list, err := trace.ParseColumns(*columns)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		w = file
+	}
+	var out bytes.Buffer
+	if *compare != "" {
+		w = io.MultiWriter(w, &out)
+	}
+
+	r, err := trace.NewRecorder(w, list, e)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for steps := uint64(1); (*limit == 0 || steps <= *limit) && !e.Stopped(); steps++ {
+		err = e.Step()
+		if err != nil {
+			r.Flush()
+			log.Fatal(err)
+		}
+		if steps%*every == 0 {
+			err = r.Record()
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		if *screenEvery != 0 && steps%*screenEvery == 0 {
+			err = screen.WriteFile(screen.NumberedName(*screenFile, steps), e.Screen())
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+	err = r.Flush()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *screenFile != "" {
+		err = screen.WriteFile(*screenFile, e.Screen())
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *compare != "" {
+		cmp, err := os.Open(*compare)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer cmp.Close()
+
+		line, err := trace.Compare(&out, cmp)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if line != 0 {
+			fmt.Fprintf(os.Stderr, "Comparison failure at line %d\n", line)
+			os.Exit(1)
+		}
+		fmt.Fprintln(os.Stderr, "End of trace, comparison ended successfully")
+	}
+}
+
+// load returns the CPU loaded with a .asm or .hack file, or the VM loaded with the .vm files given.
+func load(args []string) (emulator, error) {
+	ext := path.Ext(args[0])
+	if ext != ".asm" && ext != ".hack" {
+		var lines []program.Line
+		for _, name := range args {
+			fileLines, err := program.ReadPath(name)
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, fileLines...)
+		}
+
+		m, err := vm.New(lines)
+		if err != nil {
+			return nil, err
+		}
+		return vmEmulator{trace.VM{Machine: m}}, nil
+	}
+
+	if len(args) != 1 {
+		return nil, errors.New("only one .asm or .hack file can be traced at a time")
+	}
+	file, err := os.Open(args[0])
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var p *hack.Program
+	if ext == ".hack" {
+		p, err = hack.Load(file)
+	} else {
+		p, err = hack.Assemble(file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", args[0], strings.TrimSpace(err.Error()))
+	}
+	return cpuEmulator{trace.CPU{CPU: hack.NewCPU(p)}}, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/ChelseaDH/VMTranslator/hack"
+	"github.com/ChelseaDH/VMTranslator/webui"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:8080", "address to serve the page on")
+	history := flag.Int("history", 100000, "number of instructions that can be stepped back over")
+	rate := flag.Uint64("rate", 0, "most instructions to run each second, 0 for no limit")
+	symbols := flag.String("symbols", "", "symbol file written by the assembler to name labels and variables in a .hack file from")
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) != 1 {
+		log.Fatal("A .asm or .hack file must be provided")
+	}
+
+	source, err := os.ReadFile(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var p *hack.Program
+	if path.Ext(args[0]) == ".hack" {
+		p, err = hack.Load(bytes.NewReader(source))
+	} else {
+		p, err = hack.Assemble(bytes.NewReader(source))
+	}
+	if err != nil {
+		log.Fatalf("%s: %s", args[0], err)
+	}
+
+	if *symbols != "" {
+		file, err := os.Open(*symbols)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = p.ReadSymbols(file)
+		file.Close()
+		if err != nil {
+			log.Fatalf("%s: %s", *symbols, err)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSuffix(strings.ReplaceAll(string(source), "\r\n", "\n"), "\n"), "\n")
+	s := webui.New(p, path.Base(args[0]), lines, *history)
+	s.Rate = *rate
+
+	fmt.Printf("Serving %s on http://%s/\n", args[0], *addr)
+	log.Fatal(http.ListenAndServe(*addr, s.Handler()))
+}
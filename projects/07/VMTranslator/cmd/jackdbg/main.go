@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path"
+
+	"github.com/ChelseaDH/VMTranslator/jackdebugger"
+	"github.com/ChelseaDH/VMTranslator/keyboard"
+	"github.com/ChelseaDH/VMTranslator/program"
+	"github.com/ChelseaDH/VMTranslator/snapshot"
+)
+
+func main() {
+	limit := flag.Uint64("limit", 0, "most VM commands run by a single step or continue, 0 for no limit")
+	keys := flag.String("keys", "", "keyboard script to replay, with steps counted in VM commands")
+	snapshotFile := flag.String("snapshot", "", "snapshot saved by jackdbg to start the program from")
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) != 1 {
+		log.Fatal("A directory of .vm files compiled by JackAnalyser -symbols must be provided")
+	}
+
+	lines, err := program.ReadPath(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dir := args[0]
+	if fileInfo, err := os.Stat(dir); err == nil && !fileInfo.IsDir() {
+		dir = path.Dir(dir)
+	}
+
+	classes, err := jackdebugger.LoadClasses(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(classes) == 0 {
+		log.Fatalf("No %s symbol files found in %s, compile the program with JackAnalyser -symbols", jackdebugger.SymbolFileExt, dir)
+	}
+
+	d, err := jackdebugger.New(lines, classes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	d.Limit = *limit
+
+	// The machine is replaced when a snapshot is loaded, so the script follows whichever is current
+	if *keys != "" {
+		script, err := keyboard.ReadFile(*keys)
+		if err != nil {
+			log.Fatal(err)
+		}
+		d.Machine.KeyboardInput = func() int16 { return script.Key(d.Machine.Steps) }
+	}
+
+	if *snapshotFile != "" {
+		file, err := os.Open(*snapshotFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		m, err := snapshot.LoadVM(file)
+		file.Close()
+		if err == nil {
+			err = d.Restore(m)
+		}
+		if err != nil {
+			log.Fatalf("%s: %s", *snapshotFile, err)
+		}
+	}
+
+	fmt.Printf("Loaded %d commands with symbols for %d classes, type help for a list of commands\n", len(lines), len(classes))
+	input := bufio.NewScanner(os.Stdin)
+	last := ""
+	for {
+		fmt.Print("(jackdbg) ")
+		if !input.Scan() {
+			fmt.Println()
+			return
+		}
+
+		// An empty line repeats the previous command
+		line := input.Text()
+		if line == "" {
+			line = last
+		}
+		last = line
+
+		quit, err := d.Execute(line, os.Stdout)
+		if err != nil {
+			fmt.Println(err)
+		}
+		if quit {
+			return
+		}
+	}
+}
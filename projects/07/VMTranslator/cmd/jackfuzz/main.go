@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/ChelseaDH/VMTranslator/jackgen"
+	"github.com/ChelseaDH/VMTranslator/program"
+)
+
+func main() {
+	compiler := flag.String("compiler", "", "Jack compiler to test, run with a directory of .jack files to compile (default JackAnalyser, built from this repository)")
+	analyser := flag.String("analyser", "", "directory of the JackAnalyser source built when no -compiler is given (default projects/10/JackAnalyser of the repository holding the working directory)")
+	count := flag.Int("n", 100, "number of programs to generate")
+	seed := flag.Int64("seed", 0, "seed of the first program, 0 to seed from the time")
+	limit := flag.Uint64("limit", 10000000, "most VM commands each program may run")
+	failures := flag.String("failures", "jackfuzz-failures", "directory to keep the programs that fail in")
+	flag.Parse()
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+
+	// built is the directory the compiler was built in, if it was, removed once done
+	built := ""
+	if *compiler == "" {
+		var err error
+		if *analyser == "" {
+			*analyser, err = findAnalyser()
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		built, err = os.MkdirTemp("", "jackfuzz")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		*compiler, err = build(*analyser, built)
+		if err != nil {
+			os.RemoveAll(built)
+			log.Fatal(err)
+		}
+	}
+
+	failed := fuzz(*compiler, *seed, *count, *limit, *failures)
+	if built != "" {
+		os.RemoveAll(built)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// fuzz checks count programs starting from seed, keeping those that fail in failures and returning how many did.
+func fuzz(compiler string, seed int64, count int, limit uint64, failures string) int {
+	failed := 0
+	for i := int64(0); i < int64(count); i++ {
+		p := jackgen.Generate(seed + i)
+		err := check(p, compiler, limit)
+		if err == nil {
+			continue
+		}
+
+		failed++
+		dir := filepath.Join(failures, fmt.Sprint(p.Seed))
+		fmt.Printf("seed %d: %s, kept in %s\n", p.Seed, err, dir)
+
+		err = os.MkdirAll(dir, 0755)
+		if err == nil {
+			err = p.Write(dir)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	fmt.Printf("%d of %d programs failed\n", failed, count)
+	return failed
+}
+
+// build builds the JackAnalyser source in dir into out, returning the path of the compiler.
+func build(dir string, out string) (string, error) {
+	compiler := filepath.Join(out, "JackAnalyser")
+	cmd := exec.Command("go", "build", "-o", compiler, ".")
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("building JackAnalyser in %s failed: %s: %s", dir, err, output)
+	}
+	return compiler, nil
+}
+
+// findAnalyser returns the JackAnalyser source directory of the repository holding the working directory,
+// looking for projects/10/JackAnalyser in the working directory and each directory above it.
+func findAnalyser() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		for _, analyser := range []string{filepath.Join(dir, "projects", "10", "JackAnalyser"), filepath.Join(dir, "10", "JackAnalyser")} {
+			if _, err := os.Stat(filepath.Join(analyser, "go.mod")); err == nil {
+				return analyser, nil
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", errors.New("JackAnalyser was not found above the working directory, give its source with -analyser or a compiler with -compiler")
+		}
+		dir = parent
+	}
+}
+
+// check compiles p, runs it and compares what it logs with what it should.
+func check(p *jackgen.Program, compiler string, limit uint64) error {
+	dir, err := os.MkdirTemp("", "jackfuzz")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	err = p.Write(dir)
+	if err != nil {
+		return err
+	}
+
+	output, err := exec.Command(compiler, dir).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("compiling failed: %s: %s", err, output)
+	}
+
+	lines, err := program.ReadPath(dir)
+	if err != nil {
+		return err
+	}
+
+	logged, err := jackgen.Run(lines, limit)
+	if err != nil {
+		return err
+	}
+
+	for i := range p.Expected {
+		if i >= len(logged) {
+			return fmt.Errorf("logged %d values, expected %d", len(logged), len(p.Expected))
+		}
+		if logged[i] != p.Expected[i] {
+			return fmt.Errorf("value %d logged was %d, expected %d", i, logged[i], p.Expected[i])
+		}
+	}
+	if len(logged) > len(p.Expected) {
+		return fmt.Errorf("logged %d values, expected %d", len(logged), len(p.Expected))
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path"
+
+	"github.com/ChelseaDH/VMTranslator/decompiler"
+	"github.com/ChelseaDH/VMTranslator/program"
+)
+
+func main() {
+	output := flag.String("o", ".", "directory the decompiled .jack files are written to")
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		log.Fatal("At least one .vm file or directory containing .vm files must be provided")
+	}
+
+	var lines []program.Line
+	for _, name := range args {
+		fileLines, err := program.ReadPath(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		lines = append(lines, fileLines...)
+	}
+
+	classes, err := decompiler.Decompile(lines)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = os.MkdirAll(*output, 0755)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, class := range classes {
+		err = os.WriteFile(path.Join(*output, class.Name+".jack"), []byte(class.Source), 0644)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/ChelseaDH/VMTranslator/difftest"
+	"github.com/ChelseaDH/VMTranslator/program"
+)
+
+func main() {
+	limit := flag.Uint64("limit", 10000000, "most VM commands to run, 0 to run until the program finishes or halts")
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		log.Fatal("At least one .vm file or directory containing .vm files must be provided")
+	}
+
+	var lines []program.Line
+	for _, name := range args {
+		fileLines, err := program.ReadPath(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		lines = append(lines, fileLines...)
+	}
+
+	p, err := difftest.Translate(lines)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	divergence, err := difftest.Compare(p, *limit)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if divergence != nil {
+		fmt.Print(divergence)
+		os.Exit(1)
+	}
+	fmt.Println("No divergence found")
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"log"
+	"os"
+	"path"
+
+	"github.com/ChelseaDH/VMTranslator/format"
+)
+
+func main() {
+	write := flag.Bool("w", false, "write the result back to each file instead of printing it")
+	stripComments := flag.Bool("strip-comments", false, "remove comments")
+	renumber := flag.Bool("renumber", false, "rename the labels of each function to L0, L1, ... in order of appearance")
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		log.Fatal("At least one .vm file or directory containing .vm files must be provided")
+	}
+
+	options := format.Options{StripComments: *stripComments, RenumberLabels: *renumber}
+	for _, name := range args {
+		files, err := vmFiles(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, file := range files {
+			err = formatFile(file, options, *write)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+}
+
+// vmFiles returns name if it is a file, or every .vm file in it in name order if it is a directory.
+func vmFiles(name string) ([]string, error) {
+	fileInfo, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !fileInfo.IsDir() {
+		return []string{name}, nil
+	}
+
+	entries, err := os.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if path.Ext(entry.Name()) == ".vm" {
+			files = append(files, path.Join(name, entry.Name()))
+		}
+	}
+
+	return files, nil
+}
+
+func formatFile(name string, options format.Options, write bool) error {
+	input, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	var output bytes.Buffer
+	err = format.Format(name, bytes.NewReader(input), &output, options)
+	if err != nil {
+		return err
+	}
+
+	if !write {
+		_, err = os.Stdout.Write(output.Bytes())
+		return err
+	}
+
+	if bytes.Equal(input, output.Bytes()) {
+		return nil
+	}
+
+	return os.WriteFile(name, output.Bytes(), 0644)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/ChelseaDH/VMTranslator/cfg"
+	"github.com/ChelseaDH/VMTranslator/program"
+)
+
+func main() {
+	format := flag.String("format", "dot", "output format, either dot or json")
+	graph := flag.String("graph", "cfg", "graph written in dot format, either cfg or calls")
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		log.Fatal("At least one .vm file or directory containing .vm files must be provided")
+	}
+
+	var lines []program.Line
+	for _, name := range args {
+		fileLines, err := program.ReadPath(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		lines = append(lines, fileLines...)
+	}
+
+	functions := program.Functions(lines)
+	var graphs []*cfg.Graph
+	for _, f := range functions {
+		graphs = append(graphs, cfg.Build(f))
+	}
+	cg := cfg.BuildCallGraph(functions)
+
+	var err error
+	switch {
+	case *format == "json":
+		err = cfg.WriteJSON(os.Stdout, graphs, cg)
+	case *format == "dot" && *graph == "cfg":
+		err = cfg.WriteDOT(os.Stdout, graphs)
+	case *format == "dot" && *graph == "calls":
+		err = cg.WriteDOT(os.Stdout)
+	default:
+		log.Fatalf("Unsupported combination of format %s and graph %s", *format, *graph)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/ChelseaDH/VMTranslator/lint"
+	"github.com/ChelseaDH/VMTranslator/program"
+)
+
+func main() {
+	strict := flag.Bool("strict", false, "report calls to Jack OS functions that are not part of the program")
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		log.Fatal("At least one .vm file or directory containing .vm files must be provided")
+	}
+
+	var lines []program.Line
+	for _, name := range args {
+		fileLines, err := program.ReadPath(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		lines = append(lines, fileLines...)
+	}
+
+	isExternal := lint.IsOSFunction
+	if *strict {
+		isExternal = nil
+	}
+
+	issues := lint.Lint(lines, isExternal)
+	for _, issue := range issues {
+		fmt.Println(issue)
+	}
+
+	if len(issues) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ChelseaDH/VMTranslator/keyboard"
+	"github.com/ChelseaDH/VMTranslator/program"
+	"github.com/ChelseaDH/VMTranslator/screen"
+	"github.com/ChelseaDH/VMTranslator/terminal"
+	"github.com/ChelseaDH/VMTranslator/vm"
+)
+
+// drawInterval is the number of VM commands run between frames, which Player.Draw skips if they come too soon.
+const drawInterval = 1000
+
+func main() {
+	braille := flag.Bool("braille", false, "draw with braille characters, 2x4 pixels each, rather than half blocks")
+	fps := flag.Int("fps", 20, "frames drawn per second")
+	keys := flag.String("keys", "", "keyboard script to replay, with steps counted in VM commands")
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) != 1 {
+		log.Fatal("A .vm file or directory containing .vm files must be provided")
+	}
+
+	if *fps < 1 {
+		log.Fatal("At least one frame per second is required")
+	}
+
+	lines, err := program.ReadPath(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	m, err := vm.New(lines)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var script *keyboard.Script
+	if *keys != "" {
+		script, err = keyboard.ReadFile(*keys)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	p := &terminal.Player{In: os.Stdin, Out: os.Stdout, Refresh: time.Second / time.Duration(*fps)}
+	if *braille {
+		p.Mode = terminal.Braille
+	}
+
+	interactive := true
+	restore, err := terminal.Raw(os.Stdin)
+	if err != nil {
+		log.Printf("Could not put the terminal into raw mode, key presses will need enter: %s", err)
+		restore = func() {}
+		interactive = false
+	}
+	defer restore()
+
+	// Ctrl-C and Ctrl-\ are read as keys in raw mode, and the terminal must be restored however the player stops
+	p.Quit = func() {
+		restore()
+		os.Exit(0)
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		<-signals
+		p.Quit()
+	}()
+
+	draw := func() { p.Draw(m.RAM[screen.Base : screen.Base+screen.Words]) }
+	m.KeyboardInput = p.Key
+	if script != nil {
+		// Keys pressed at the terminal are read whenever the script holds none
+		m.KeyboardInput = func() int16 {
+			if key := script.Key(m.Steps); key != 0 {
+				return key
+			}
+			return p.Key()
+		}
+	}
+	p.Start()
+
+	for !m.Halted() && !m.Finished() {
+		err = m.Step()
+		if err != nil {
+			break
+		}
+		if m.Steps%drawInterval == 0 {
+			draw()
+		}
+	}
+	p.Refresh = 0
+	draw()
+	if err != nil {
+		log.Print(err)
+	}
+
+	// Leave the final frame on screen until a key is pressed, unless no more keys can come
+	for interactive && p.Key() == 0 && !p.Closed() {
+		time.Sleep(time.Second / 20)
+	}
+}
@@ -65,11 +65,13 @@ const (
 	Temp
 )
 
+var segmentNames = []string{"local", "argument", "this", "that", "constant", "static", "pointer", "temp"}
+
 func (s Segment) String() string {
-	if int(s) >= len(commandNames) || int(s) < 0 {
+	if int(s) >= len(segmentNames) || int(s) < 0 {
 		return ""
 	}
-	return commandNames[s]
+	return segmentNames[s]
 }
 
 func (s Segment) Label() string {
@@ -98,9 +100,8 @@ func (m MemoryAccessCommand) String() string {
 }
 
 func ToCommandType(s string) CommandType {
-	names := []string{"add", "sub", "neg", "eq", "gt", "lt", "and", "or", "not", "pop", "push", "label", "goto", "if-goto", "function", "call", "return"}
-	for i := range names {
-		if names[i] == s {
+	for i := range commandNames {
+		if commandNames[i] == s {
 			return CommandType(i)
 		}
 	}
@@ -109,9 +110,8 @@ func ToCommandType(s string) CommandType {
 }
 
 func ToSegment(s string) Segment {
-	segments := []string{"local", "argument", "this", "that", "constant", "static", "pointer", "temp"}
-	for i := range segments {
-		if segments[i] == s {
+	for i := range segmentNames {
+		if segmentNames[i] == s {
 			return Segment(i)
 		}
 	}
@@ -0,0 +1,428 @@
+package ctranslator
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/ChelseaDH/VMTranslator/command"
+)
+
+const (
+	tempIndex   = 5
+	staticIndex = 16
+)
+
+// Translator converts a stream of VM commands into a single portable C program.
+// Every VM function becomes a labelled block inside one C function, with calls and
+// returns implemented through an explicit return address dispatch table.
+// The program is buffered until Terminate, as calls may refer to functions defined later.
+type Translator struct {
+	Output      io.Writer
+	Namespace   string
+	currentFunc string
+	body        bytes.Buffer
+	bootstrap   bool
+	functions   map[string]int
+	defined     map[string]bool
+	funcOrder   []string
+	labels      map[string]int
+	statics     map[string]int
+	returnCount int
+}
+
+func (t *Translator) SetNamespace(namespace string) {
+	t.Namespace = namespace
+}
+
+func (t *Translator) Translate(c command.Command) error {
+	t.write(fmt.Sprintf("\t/* %s */\n", c.String()))
+
+	switch c.Type() {
+	case command.Add:
+		t.translateBinaryExpression("(int16_t)(x + y)")
+		return nil
+
+	case command.Sub:
+		t.translateBinaryExpression("(int16_t)(x - y)")
+		return nil
+
+	case command.Eq:
+		t.translateBinaryExpression("x == y ? -1 : 0")
+		return nil
+
+	case command.Gt:
+		t.translateBinaryExpression("x > y ? -1 : 0")
+		return nil
+
+	case command.Lt:
+		t.translateBinaryExpression("x < y ? -1 : 0")
+		return nil
+
+	case command.And:
+		t.translateBinaryExpression("x & y")
+		return nil
+
+	case command.Or:
+		t.translateBinaryExpression("x | y")
+		return nil
+
+	case command.Neg:
+		t.write("\tTOP = (int16_t)-TOP;\n")
+		return nil
+
+	case command.Not:
+		t.write("\tTOP = ~TOP;\n")
+		return nil
+
+	case command.Pop:
+		mac := c.(*command.MemoryAccessCommand)
+		return t.translatePop(mac)
+
+	case command.Push:
+		mac := c.(*command.MemoryAccessCommand)
+		return t.translatePush(mac)
+
+	case command.Label:
+		bc := c.(*command.BranchingCommand)
+		t.write(fmt.Sprintf("%s:\n", t.label(bc)))
+		return nil
+
+	case command.Goto:
+		bc := c.(*command.BranchingCommand)
+		t.write(fmt.Sprintf("\tgoto %s;\n", t.label(bc)))
+		return nil
+
+	case command.IfGoto:
+		bc := c.(*command.BranchingCommand)
+		t.write(fmt.Sprintf("\tif (pop() != 0)\n\t\tgoto %s;\n", t.label(bc)))
+		return nil
+
+	case command.Function:
+		fc := c.(*command.FunctionCommand)
+		t.currentFunc = fc.Name
+		return t.defineFunction(fc)
+
+	case command.Call:
+		fc := c.(*command.FunctionCommand)
+		t.callFunction(fc)
+		return nil
+
+	case command.Return:
+		t.write("\tret = frame_return();\n\tgoto dispatch;\n")
+		return nil
+
+	default:
+		return fmt.Errorf("translation not yet implemented for command of type %s", c.Type())
+	}
+}
+
+func (t *Translator) write(input string) {
+	t.body.WriteString(input)
+}
+
+func (t *Translator) output(input string) {
+	_, err := fmt.Fprintf(t.Output, "%s", input)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Initialise mirrors the Hack bootstrap code: the stack pointer is set to 256 and Sys.init is called.
+// The program halts should Sys.init ever return.
+func (t *Translator) Initialise() error {
+	t.bootstrap = true
+	t.write("\t/* Initialising stack pointer */\n\tSP = 256;\n")
+	err := t.Translate(&command.FunctionCommand{
+		RawCommand: command.RawCommand{Typ: command.Call},
+		Name:       "Sys.init",
+		Args:       0,
+	})
+	if err != nil {
+		return err
+	}
+
+	t.write("\thalt();\n")
+	return nil
+}
+
+// Terminate writes the complete C program to Output.
+func (t *Translator) Terminate() {
+	t.output(prelude)
+	if t.hasUndefined() {
+		t.output(undefinedFunction)
+	}
+
+	t.output("static void run(void)\n{\n\tint16_t x, y, ret;\n\n")
+	if !t.bootstrap {
+		t.output("\tSP = 256;\n")
+	}
+	t.output(t.body.String())
+	t.output("\thalt();\n\n")
+
+	// Calls to functions that were never defined fail at runtime rather than at compile time,
+	// so that programs only using a subset of the OS can still be built.
+	for _, name := range t.funcOrder {
+		if !t.defined[name] {
+			t.output(fmt.Sprintf("%s:\n\tundefined(\"%s\");\n", t.functionLabel(name), name))
+		}
+	}
+
+	t.output("\ndispatch:\n\tswitch (ret) {\n")
+	for i := 0; i < t.returnCount; i++ {
+		t.output(fmt.Sprintf("\tcase %d:\n\t\tgoto R%d;\n", i, i))
+	}
+	t.output("\tdefault:\n\t\tfprintf(stderr, \"invalid return address %d\\n\", ret);\n\t\texit(1);\n\t}\n}\n")
+	t.output(mainFunction)
+}
+
+func (t *Translator) hasUndefined() bool {
+	for _, name := range t.funcOrder {
+		if !t.defined[name] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t *Translator) translateBinaryExpression(expression string) {
+	t.write(fmt.Sprintf("\ty = pop();\n\tx = TOP;\n\tTOP = %s;\n", expression))
+}
+
+func (t *Translator) translatePop(c *command.MemoryAccessCommand) error {
+	loc, err := t.location(c)
+	if err != nil {
+		return err
+	}
+
+	t.write(fmt.Sprintf("\t%s = pop();\n", loc))
+	return nil
+}
+
+func (t *Translator) translatePush(c *command.MemoryAccessCommand) error {
+	if c.Segment == command.Constant {
+		t.write(fmt.Sprintf("\tpush(%d);\n", c.Index))
+		return nil
+	}
+
+	loc, err := t.location(c)
+	if err != nil {
+		return err
+	}
+
+	t.write(fmt.Sprintf("\tpush(%s);\n", loc))
+	return nil
+}
+
+// location returns the C lvalue for the memory segment cell referenced by c.
+func (t *Translator) location(c *command.MemoryAccessCommand) (string, error) {
+	switch c.Segment {
+	case command.Local, command.Argument, command.This, command.That:
+		return fmt.Sprintf("MEM(%s + %d)", c.Segment.Label(), c.Index), nil
+
+	case command.Static:
+		return fmt.Sprintf("RAM[%d]", t.static(c.Index)), nil
+
+	case command.Temp:
+		return fmt.Sprintf("RAM[%d]", tempIndex+c.Index), nil
+
+	case command.Pointer:
+		if c.Index == 0 {
+			return "THIS", nil
+		}
+		return "THAT", nil
+
+	default:
+		return "", fmt.Errorf("%s is not a valid segment type for %s", c.Segment.String(), c.Type())
+	}
+}
+
+// static allocates static variables from RAM[16] in order of first use, as the Hack assembler does.
+func (t *Translator) static(index int) int {
+	if t.statics == nil {
+		t.statics = make(map[string]int)
+	}
+
+	name := fmt.Sprintf("%s.%d", t.Namespace, index)
+	address, ok := t.statics[name]
+	if !ok {
+		address = staticIndex + len(t.statics)
+		t.statics[name] = address
+	}
+
+	return address
+}
+
+func (t *Translator) label(bc *command.BranchingCommand) string {
+	if t.labels == nil {
+		t.labels = make(map[string]int)
+	}
+
+	name := fmt.Sprintf("%s$%s", t.currentFunc, strings.ToUpper(bc.Label))
+	id, ok := t.labels[name]
+	if !ok {
+		id = len(t.labels)
+		t.labels[name] = id
+	}
+
+	return fmt.Sprintf("L%d", id)
+}
+
+func (t *Translator) functionLabel(name string) string {
+	if t.functions == nil {
+		t.functions = make(map[string]int)
+	}
+
+	id, ok := t.functions[name]
+	if !ok {
+		id = len(t.functions)
+		t.functions[name] = id
+		t.funcOrder = append(t.funcOrder, name)
+	}
+
+	return fmt.Sprintf("F%d", id)
+}
+
+func (t *Translator) defineFunction(fc *command.FunctionCommand) error {
+	if t.defined == nil {
+		t.defined = make(map[string]bool)
+	}
+
+	if t.defined[fc.Name] {
+		return fmt.Errorf("function %s defined more than once", fc.Name)
+	}
+	t.defined[fc.Name] = true
+
+	t.write(fmt.Sprintf("%s:\n\tlocals(%d);\n", t.functionLabel(fc.Name), fc.Args))
+	return nil
+}
+
+func (t *Translator) callFunction(fc *command.FunctionCommand) {
+	// Sys.halt never returns, so stop the native program rather than spinning forever
+	if fc.Name == "Sys.halt" {
+		t.write("\thalt();\n")
+		return
+	}
+
+	t.write(fmt.Sprintf("\tframe_call(%d, %d);\n\tgoto %s;\nR%d:\n", t.returnCount, fc.Args, t.functionLabel(fc.Name), t.returnCount))
+	t.returnCount++
+}
+
+const prelude = `#include <stdint.h>
+#include <stdio.h>
+#include <stdlib.h>
+
+#define RAM_SIZE 32768
+#define SCREEN 16384
+#define SCREEN_WIDTH 512
+#define SCREEN_HEIGHT 256
+
+static int16_t RAM[RAM_SIZE];
+static const char *screen_file;
+
+#define MEM(address) RAM[(uint16_t)(address) & (RAM_SIZE - 1)]
+#define SP RAM[0]
+#define LCL RAM[1]
+#define ARG RAM[2]
+#define THIS RAM[3]
+#define THAT RAM[4]
+#define TOP MEM(SP - 1)
+
+static void push(int16_t value)
+{
+	MEM(SP) = value;
+	SP++;
+}
+
+static int16_t pop(void)
+{
+	SP--;
+	return MEM(SP);
+}
+
+static void locals(int count)
+{
+	for (int i = 0; i < count; i++)
+		push(0);
+}
+
+static void frame_call(int16_t ret, int16_t args)
+{
+	push(ret);
+	push(LCL);
+	push(ARG);
+	push(THIS);
+	push(THAT);
+	ARG = SP - 5 - args;
+	LCL = SP;
+}
+
+static int16_t frame_return(void)
+{
+	int16_t frame = LCL;
+	int16_t ret = MEM(frame - 5);
+
+	MEM(ARG) = pop();
+	SP = ARG + 1;
+	THAT = MEM(frame - 1);
+	THIS = MEM(frame - 2);
+	ARG = MEM(frame - 3);
+	LCL = MEM(frame - 4);
+	return ret;
+}
+
+/* Writes the screen memory map as a binary PBM image, where a set bit is a black pixel. */
+static void dump_screen(const char *path)
+{
+	FILE *f = fopen(path, "wb");
+	if (f == NULL) {
+		perror(path);
+		return;
+	}
+
+	fprintf(f, "P4\n%d %d\n", SCREEN_WIDTH, SCREEN_HEIGHT);
+	for (int word = 0; word < SCREEN_WIDTH * SCREEN_HEIGHT / 16; word++) {
+		uint16_t value = (uint16_t)RAM[SCREEN + word];
+		unsigned char bytes[2] = {0, 0};
+
+		/* The least significant bit of a screen word is its leftmost pixel */
+		for (int bit = 0; bit < 16; bit++) {
+			if (value & (1 << bit))
+				bytes[bit / 8] |= 0x80 >> (bit % 8);
+		}
+		fwrite(bytes, 1, 2, f);
+	}
+	fclose(f);
+}
+
+static void halt(void)
+{
+	if (screen_file != NULL)
+		dump_screen(screen_file);
+	exit(0);
+}
+
+`
+
+const undefinedFunction = `static void undefined(const char *name)
+{
+	fprintf(stderr, "call to undefined function %s\n", name);
+	exit(1);
+}
+
+`
+
+const mainFunction = `
+int main(int argc, char **argv)
+{
+	if (argc > 1)
+		screen_file = argv[1];
+
+	run();
+	halt();
+	return 0;
+}
+`
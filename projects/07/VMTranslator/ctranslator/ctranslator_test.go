@@ -0,0 +1,73 @@
+package ctranslator
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ChelseaDH/VMTranslator/parser"
+)
+
+type translateTest struct {
+	input     []string
+	expOutput []string
+	expectErr bool
+}
+
+var translateTests = []translateTest{
+	{
+		input:     []string{"push constant 7", "push static 3", "add", "pop temp 2"},
+		expOutput: []string{"push(7);", "push(RAM[16]);", "TOP = (int16_t)(x + y);", "RAM[7] = pop();"},
+	},
+	{
+		input:     []string{"function Test.f 2", "label loop", "push local 1", "if-goto loop", "call Test.g 1", "return"},
+		expOutput: []string{"F0:\n\tlocals(2);", "L0:\n", "goto L0;", "frame_call(0, 1);\n\tgoto F1;\nR0:", "F1:\n\tundefined(\"Test.g\");"},
+	},
+	{
+		input:     []string{"call Sys.halt 0"},
+		expOutput: []string{"\thalt();\n"},
+	},
+	{
+		input:     []string{"function Test.f 0", "function Test.f 0"},
+		expectErr: true,
+	},
+}
+
+func TestTranslator_Translate(t *testing.T) {
+	for _, test := range translateTests {
+		var output bytes.Buffer
+		translator := Translator{Output: &output, Namespace: "Test"}
+
+		var err error
+		for _, line := range test.input {
+			c, parseErr := parser.Parse(line)
+			if parseErr != nil {
+				t.Fatalf("unexpected parse error %q for %s", parseErr, line)
+			}
+
+			err = translator.Translate(c)
+			if err != nil {
+				break
+			}
+		}
+
+		if err == nil && test.expectErr {
+			t.Errorf("expected an error but none returned for %v", test.input)
+		}
+
+		if err != nil && !test.expectErr {
+			t.Errorf("did not expect an error, but %q returned for %v", err, test.input)
+		}
+
+		if err != nil {
+			continue
+		}
+
+		translator.Terminate()
+		for _, exp := range test.expOutput {
+			if !strings.Contains(output.String(), exp) {
+				t.Errorf("expected output to contain %q for %v", exp, test.input)
+			}
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package debugger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ChelseaDH/VMTranslator/hack"
+	"github.com/ChelseaDH/VMTranslator/screen"
+	"github.com/ChelseaDH/VMTranslator/snapshot"
+)
+
+const help = `Commands:
+  step [n], s         run n instructions (default 1)
+  reverse [n], r      undo n instructions (default 1)
+  continue, c         run until a breakpoint, watchpoint or halt
+  until <addr>, u     run until the ROM address or label is reached
+  break <addr>, b     set a breakpoint at a ROM address or label
+  delete <addr>, d    remove a breakpoint
+  watch <addr>, w     stop when a RAM address or symbol changes
+  unwatch <addr>      remove a watchpoint
+  info, i             list breakpoints and watchpoints
+  regs                show the registers and segment pointers
+  stack [n]           show the top n values of the stack (default 8)
+  ram <addr> [n]      show n RAM cells starting at an address or symbol (default 1)
+  list [n]            show the n instructions around PC (default 5)
+  screenshot <file>   write the screen as a PNG image, or PBM if the file name ends in .pbm
+  save <file>         save a snapshot of the CPU
+  load <file>         restore a snapshot of the CPU, saved while running this program
+  quit, q             exit
+`
+
+// Execute runs a single debugger command, writing its output to w. It returns true if the command was quit.
+func (d *Debugger) Execute(line string, w io.Writer) (bool, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false, nil
+	}
+
+	command, args := fields[0], fields[1:]
+	switch command {
+	case "step", "s":
+		n, err := count(args, 1)
+		if err != nil {
+			return false, err
+		}
+
+		for i := 0; i < n; i++ {
+			stop, err := d.Step()
+			if err != nil {
+				return false, err
+			}
+			if stop.Reason != Stepped {
+				d.writeStop(w, stop)
+				return false, nil
+			}
+		}
+		d.writeLocation(w)
+
+	case "reverse", "r":
+		n, err := count(args, 1)
+		if err != nil {
+			return false, err
+		}
+
+		for i := 0; i < n; i++ {
+			if !d.Back() {
+				fmt.Fprintln(w, "No more history")
+				break
+			}
+		}
+		d.writeLocation(w)
+
+	case "continue", "c":
+		stop, err := d.Continue(nil)
+		if err != nil {
+			return false, err
+		}
+		d.writeStop(w, stop)
+
+	case "until", "u":
+		address, err := d.romArgument(args)
+		if err != nil {
+			return false, err
+		}
+
+		stop, err := d.Continue(&address)
+		if err != nil {
+			return false, err
+		}
+		d.writeStop(w, stop)
+
+	case "break", "b", "delete", "d":
+		address, err := d.romArgument(args)
+		if err != nil {
+			return false, err
+		}
+		d.SetBreakpoint(address, command == "break" || command == "b")
+
+	case "watch", "w", "unwatch":
+		address, err := d.ramArgument(args)
+		if err != nil {
+			return false, err
+		}
+		d.SetWatchpoint(address, command != "unwatch")
+
+	case "info", "i":
+		for _, address := range d.Breakpoints() {
+			fmt.Fprintf(w, "breakpoint %d (%s)\n", address, d.Location(address))
+		}
+		for _, address := range d.Watchpoints() {
+			fmt.Fprintf(w, "watchpoint RAM[%d] = %d\n", address, d.CPU.RAM[address])
+		}
+
+	case "regs":
+		cpu := d.CPU
+		fmt.Fprintf(w, "PC %d (%s)  A %d  D %d  M %d  cycles %d\n", cpu.PC, d.Location(cpu.PC), cpu.A, cpu.D, cpu.RAM[uint16(cpu.A)&0x7fff], cpu.Cycles)
+		fmt.Fprintf(w, "SP %d  LCL %d  ARG %d  THIS %d  THAT %d\n", cpu.RAM[0], cpu.RAM[1], cpu.RAM[2], cpu.RAM[3], cpu.RAM[4])
+
+	case "stack":
+		n, err := count(args, 8)
+		if err != nil {
+			return false, err
+		}
+
+		sp := int(uint16(d.CPU.RAM[0]) & 0x7fff)
+		for address := sp - 1; address >= 0 && address >= sp-n; address-- {
+			fmt.Fprintf(w, "%5d: %d\n", address, d.CPU.RAM[address])
+		}
+
+	case "ram":
+		if len(args) == 0 {
+			return false, fmt.Errorf("%s needs an address", command)
+		}
+
+		address, err := d.RAMAddress(args[0])
+		if err != nil {
+			return false, err
+		}
+
+		n, err := count(args[1:], 1)
+		if err != nil {
+			return false, err
+		}
+
+		for i := 0; i < n && int(address)+i < hack.RAMSize; i++ {
+			fmt.Fprintf(w, "%5d: %d\n", int(address)+i, d.CPU.RAM[int(address)+i])
+		}
+
+	case "list":
+		n, err := count(args, 5)
+		if err != nil {
+			return false, err
+		}
+		d.writeListing(w, n)
+
+	case "screenshot":
+		if len(args) != 1 {
+			return false, fmt.Errorf("%s needs a file name", command)
+		}
+
+		err := screen.WriteFile(args[0], d.CPU.RAM[screen.Base:screen.Base+screen.Words])
+		if err != nil {
+			return false, err
+		}
+
+	case "save", "load":
+		if len(args) != 1 {
+			return false, fmt.Errorf("%s needs a file name", command)
+		}
+
+		var err error
+		if command == "save" {
+			err = d.save(args[0])
+		} else {
+			err = d.load(args[0])
+		}
+		if err != nil {
+			return false, err
+		}
+		if command == "load" {
+			d.writeLocation(w)
+		}
+
+	case "help", "h":
+		io.WriteString(w, help)
+
+	case "quit", "q":
+		return true, nil
+
+	default:
+		return false, fmt.Errorf("unknown command %s, try help", command)
+	}
+
+	return false, nil
+}
+
+func (d *Debugger) save(name string) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	err = snapshot.SaveCPU(file, d.CPU)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (d *Debugger) load(name string) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	cpu, err := snapshot.LoadCPU(file)
+	if err != nil {
+		return fmt.Errorf("%s: %s", name, err)
+	}
+	return d.Restore(cpu)
+}
+
+func count(args []string, def int) (int, error) {
+	if len(args) == 0 {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("expected a positive count, got %s", args[0])
+	}
+	return n, nil
+}
+
+func (d *Debugger) romArgument(args []string) (uint16, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("expected a ROM address or label")
+	}
+	return d.ROMAddress(args[0])
+}
+
+func (d *Debugger) ramArgument(args []string) (uint16, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("expected a RAM address or symbol")
+	}
+	return d.RAMAddress(args[0])
+}
+
+func (d *Debugger) writeStop(w io.Writer, stop Stop) {
+	switch stop.Reason {
+	case Watchpoint:
+		fmt.Fprintf(w, "Watchpoint: RAM[%d] changed from %d to %d\n", stop.Address, stop.Old, stop.New)
+	case Breakpoint:
+		fmt.Fprintf(w, "Breakpoint: %s\n", d.Location(stop.Address))
+	case Halted:
+		fmt.Fprintln(w, "Program halted")
+	case Limit:
+		fmt.Fprintf(w, "Stopped after %d instructions\n", d.Limit)
+	}
+	d.writeLocation(w)
+}
+
+func (d *Debugger) writeLocation(w io.Writer) {
+	pc := d.CPU.PC
+	source := ""
+	if int(pc) < len(d.Program.Source) {
+		source = fmt.Sprintf("  line %d: %s", d.Program.Source[pc].Number, d.Program.Source[pc].Text)
+	}
+	fmt.Fprintf(w, "%d (%s)%s\n", pc, d.Location(pc), source)
+}
+
+func (d *Debugger) writeListing(w io.Writer, n int) {
+	start := int(d.CPU.PC) - n/2
+	if start < 0 {
+		start = 0
+	}
+
+	for address := start; address < start+n && address < len(d.Program.Source); address++ {
+		marker := "  "
+		if address == int(d.CPU.PC) {
+			marker = "=>"
+		} else if d.breakpoints[uint16(address)] {
+			marker = "* "
+		}
+		fmt.Fprintf(w, "%s %5d  %-20s %s\n", marker, address, d.Location(uint16(address)), d.Program.Source[address].Text)
+	}
+}
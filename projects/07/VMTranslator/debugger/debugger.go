@@ -0,0 +1,242 @@
+// Package debugger steps through Hack programs with breakpoints, watchpoints and reverse stepping.
+package debugger
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/ChelseaDH/VMTranslator/hack"
+	"github.com/ChelseaDH/VMTranslator/snapshot"
+)
+
+// Reason describes why the debugger stopped running the program.
+type Reason int
+
+const (
+	Stepped Reason = iota
+	Breakpoint
+	Watchpoint
+	Halted
+	Limit
+)
+
+var reasonNames = []string{"stepped", "breakpoint", "watchpoint", "halted", "limit"}
+
+func (r Reason) String() string {
+	return reasonNames[r]
+}
+
+// Stop is where and why the program stopped.
+type Stop struct {
+	Reason Reason
+	// Address is the RAM address written for a Watchpoint, and the ROM address reached for a Breakpoint.
+	Address  uint16
+	Old, New int16
+}
+
+// Debugger runs a program on a CPU, keeping the changes made by recent instructions so they can be undone.
+type Debugger struct {
+	CPU     *hack.CPU
+	Program *hack.Program
+	// History is the most instructions that can be stepped back over.
+	History int
+	// Limit is the most instructions Continue runs before stopping, 0 for no limit.
+	Limit uint64
+
+	// changes is a ring buffer of the last History changes, with the oldest at first
+	changes     []hack.Change
+	first       int
+	count       int
+	breakpoints map[uint16]bool
+	watchpoints map[uint16]bool
+	labels      []label
+}
+
+type label struct {
+	name    string
+	address uint16
+}
+
+// New returns a Debugger for p, ready to run its first instruction.
+func New(p *hack.Program, history int) *Debugger {
+	d := &Debugger{
+		CPU:         hack.NewCPU(p),
+		Program:     p,
+		History:     history,
+		breakpoints: make(map[uint16]bool),
+		watchpoints: make(map[uint16]bool),
+	}
+
+	for name, address := range p.Labels {
+		d.labels = append(d.labels, label{name: name, address: address})
+	}
+	sort.Slice(d.labels, func(i, j int) bool {
+		if d.labels[i].address != d.labels[j].address {
+			return d.labels[i].address < d.labels[j].address
+		}
+		return d.labels[i].name < d.labels[j].name
+	})
+
+	return d
+}
+
+// ROMAddress resolves a ROM address given as a number or a label.
+func (d *Debugger) ROMAddress(s string) (uint16, error) {
+	if address, err := strconv.ParseUint(s, 10, 15); err == nil {
+		return uint16(address), nil
+	}
+
+	if address, ok := d.Program.Labels[s]; ok {
+		return address, nil
+	}
+
+	return 0, fmt.Errorf("unknown label %s", s)
+}
+
+// RAMAddress resolves a RAM address given as a number, a predefined symbol such as SP, or a variable.
+func (d *Debugger) RAMAddress(s string) (uint16, error) {
+	if address, err := strconv.ParseUint(s, 10, 15); err == nil {
+		return uint16(address), nil
+	}
+
+	if address, ok := hack.PredefinedSymbols[s]; ok {
+		return address, nil
+	}
+
+	if address, ok := d.Program.Variables[s]; ok {
+		return address, nil
+	}
+
+	return 0, fmt.Errorf("unknown symbol %s", s)
+}
+
+// Location names a ROM address after the closest label at or before it, such as LOOP+2.
+func (d *Debugger) Location(address uint16) string {
+	i := sort.Search(len(d.labels), func(i int) bool {
+		return d.labels[i].address > address
+	})
+
+	if i == 0 {
+		return strconv.Itoa(int(address))
+	}
+
+	// Prefer the first of several labels at the same address
+	l := d.labels[i-1]
+	for i > 1 && d.labels[i-2].address == l.address {
+		i--
+		l = d.labels[i-1]
+	}
+
+	if l.address == address {
+		return l.name
+	}
+	return fmt.Sprintf("%s+%d", l.name, address-l.address)
+}
+
+func (d *Debugger) SetBreakpoint(address uint16, set bool) {
+	if set {
+		d.breakpoints[address] = true
+	} else {
+		delete(d.breakpoints, address)
+	}
+}
+
+func (d *Debugger) SetWatchpoint(address uint16, set bool) {
+	if set {
+		d.watchpoints[address] = true
+	} else {
+		delete(d.watchpoints, address)
+	}
+}
+
+// Breakpoints and Watchpoints return the addresses being watched, in order.
+func (d *Debugger) Breakpoints() []uint16 {
+	return sortedAddresses(d.breakpoints)
+}
+
+func (d *Debugger) Watchpoints() []uint16 {
+	return sortedAddresses(d.watchpoints)
+}
+
+func sortedAddresses(set map[uint16]bool) []uint16 {
+	var addresses []uint16
+	for address := range set {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool { return addresses[i] < addresses[j] })
+	return addresses
+}
+
+// Step executes a single instruction, stopping early if the program has halted.
+func (d *Debugger) Step() (Stop, error) {
+	if d.CPU.Halted() {
+		return Stop{Reason: Halted}, nil
+	}
+
+	change, err := d.CPU.Step()
+	if err != nil {
+		return Stop{}, err
+	}
+
+	if d.History > 0 {
+		if len(d.changes) != d.History {
+			d.changes, d.first, d.count = make([]hack.Change, d.History), 0, 0
+		}
+
+		d.changes[(d.first+d.count)%d.History] = change
+		if d.count < d.History {
+			d.count++
+		} else {
+			d.first = (d.first + 1) % d.History
+		}
+	}
+
+	if change.Wrote && d.watchpoints[change.Address] && d.CPU.RAM[change.Address] != change.Old {
+		return Stop{Reason: Watchpoint, Address: change.Address, Old: change.Old, New: d.CPU.RAM[change.Address]}, nil
+	}
+
+	return Stop{Reason: Stepped}, nil
+}
+
+// Restore puts the CPU into the state of cpu, such as one loaded from a snapshot, which must be running the same
+// program. The history is cleared, as it cannot step back past the restored state.
+func (d *Debugger) Restore(cpu *hack.CPU) error {
+	if !snapshot.SameROM(cpu.ROM, d.Program.ROM) {
+		return errors.New("the snapshot is of a different program")
+	}
+
+	d.CPU.RAM, d.CPU.A, d.CPU.D, d.CPU.PC, d.CPU.Cycles = cpu.RAM, cpu.A, cpu.D, cpu.PC, cpu.Cycles
+	d.count = 0
+	return nil
+}
+
+// Back undoes the last instruction, returning false if there is no history left to undo.
+func (d *Debugger) Back() bool {
+	if d.count == 0 {
+		return false
+	}
+
+	d.count--
+	d.CPU.Undo(d.changes[(d.first+d.count)%len(d.changes)])
+	return true
+}
+
+// Continue runs until a breakpoint other than the current instruction is reached, a watched RAM cell changes,
+// the program halts or Limit instructions have run. If until is not nil it is treated as an extra breakpoint.
+func (d *Debugger) Continue(until *uint16) (Stop, error) {
+	for count := uint64(0); d.Limit == 0 || count < d.Limit; count++ {
+		stop, err := d.Step()
+		if err != nil || stop.Reason != Stepped {
+			return stop, err
+		}
+
+		pc := d.CPU.PC
+		if d.breakpoints[pc] || (until != nil && *until == pc) {
+			return Stop{Reason: Breakpoint, Address: pc}, nil
+		}
+	}
+
+	return Stop{Reason: Limit}, nil
+}
@@ -0,0 +1,175 @@
+package debugger
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ChelseaDH/VMTranslator/hack"
+)
+
+// count sums 1 to 3 into sum
+const program = `@sum
+M=0
+@i
+M=1
+(LOOP)
+@i
+D=M
+@4
+D=D-A
+@END
+D;JEQ
+@i
+D=M
+@sum
+M=D+M
+@i
+M=M+1
+@LOOP
+0;JMP
+(END)
+@END
+0;JMP
+`
+
+type commandTest struct {
+	command   string
+	expOutput string
+	expectErr bool
+}
+
+var commandTests = []commandTest{
+	{command: "break END", expOutput: ""},
+	{command: "watch sum", expOutput: ""},
+	{command: "c", expOutput: "Watchpoint: RAM[16] changed from 0 to 1\n14 (LOOP+10)  line 16: @i\n"},
+	{command: "c", expOutput: "Watchpoint: RAM[16] changed from 1 to 3\n"},
+	{command: "reverse 2", expOutput: "12 (LOOP+8)  line 14: @sum\n"},
+	{command: "ram sum", expOutput: "   16: 1\n"},
+	{command: "unwatch sum", expOutput: ""},
+	{command: "c", expOutput: "Breakpoint: END\n18 (END)  line 21: @END\n"},
+	{command: "ram 16 2", expOutput: "   16: 6\n   17: 4\n"},
+	{command: "step", expOutput: "Program halted\n"},
+	{command: "break NOWHERE", expectErr: true},
+	{command: "step lots", expectErr: true},
+	{command: "screenshot", expectErr: true},
+	{command: "jump", expectErr: true},
+}
+
+func TestDebugger_Execute(t *testing.T) {
+	p, err := hack.Assemble(strings.NewReader(program))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := New(p, 10)
+	for _, test := range commandTests {
+		var output strings.Builder
+		_, err := d.Execute(test.command, &output)
+
+		if err == nil && test.expectErr {
+			t.Errorf("expected an error but none returned for %s", test.command)
+		}
+
+		if err != nil && !test.expectErr {
+			t.Errorf("did not expect an error, but %q returned for %s", err, test.command)
+		}
+
+		if !strings.HasPrefix(output.String(), test.expOutput) {
+			t.Errorf("expected output starting %q but got %q for %s", test.expOutput, output.String(), test.command)
+		}
+	}
+}
+
+func TestDebugger_Screenshot(t *testing.T) {
+	p, err := hack.Assemble(strings.NewReader("@SCREEN\nM=1\n(END)\n@END\n0;JMP"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "screen.pbm")
+
+	d := New(p, 10)
+	for _, command := range []string{"c", "screenshot " + name} {
+		_, err = d.Execute(command, io.Discard)
+		if err != nil {
+			t.Fatalf("did not expect an error, but %q returned for %s", err, command)
+		}
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The leftmost pixel of the top row is the most significant bit of the first byte
+	header := "P4\n512 256\n"
+	if !strings.HasPrefix(string(data), header) || data[len(header)] != 0x80 {
+		t.Errorf("expected a PBM image with the top left pixel set")
+	}
+}
+
+func TestDebugger_Back(t *testing.T) {
+	p, err := hack.Assemble(strings.NewReader(program))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := New(p, 3)
+	for i := 0; i < 5; i++ {
+		_, err = d.Step()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		if !d.Back() {
+			t.Fatalf("expected to step back over %d instructions of history", i+1)
+		}
+	}
+
+	if d.Back() {
+		t.Errorf("expected the history to be limited to 3 instructions")
+	}
+
+	if d.CPU.PC != 2 || d.CPU.RAM[17] != 0 {
+		t.Errorf("expected to be back at PC 2 before i was set, got PC %d and i %d", d.CPU.PC, d.CPU.RAM[17])
+	}
+}
+
+func TestDebugger_Snapshot(t *testing.T) {
+	p, err := hack.Assemble(strings.NewReader(program))
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "count.snapshot")
+
+	d := New(p, 10)
+	for _, command := range []string{"break 14", "c", "save " + name, "c"} {
+		_, err = d.Execute(command, io.Discard)
+		if err != nil {
+			t.Fatalf("did not expect an error, but %q returned for %s", err, command)
+		}
+	}
+
+	var output strings.Builder
+	_, err = d.Execute("load "+name, &output)
+	if err != nil {
+		t.Fatalf("did not expect an error, but %q returned", err)
+	}
+	if expOutput := "14 (LOOP+10)  line 16: @i\n"; output.String() != expOutput {
+		t.Errorf("expected output %q but got %q", expOutput, output.String())
+	}
+	if d.CPU.RAM[16] != 1 || d.CPU.Cycles != 14 || d.Back() {
+		t.Errorf("expected the state at the first breakpoint with no history, got sum %d after %d cycles", d.CPU.RAM[16], d.CPU.Cycles)
+	}
+
+	other, err := hack.Assemble(strings.NewReader("@0\n0;JMP"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(other, 10).Execute("load "+name, io.Discard); err == nil {
+		t.Errorf("expected an error loading a snapshot of another program, but none returned")
+	}
+}
@@ -0,0 +1,245 @@
+package decompiler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ChelseaDH/VMTranslator/cfg"
+	"github.com/ChelseaDH/VMTranslator/command"
+	"github.com/ChelseaDH/VMTranslator/program"
+)
+
+const indent = "    "
+
+// Class is the Jack source reconstructed from the functions of a single .vm file.
+type Class struct {
+	Name   string
+	Source string
+}
+
+type subroutineKind int
+
+const (
+	function subroutineKind = iota
+	method
+	constructor
+)
+
+var subroutineKindNames = []string{"function", "method", "constructor"}
+
+type subroutine struct {
+	program.Function
+	class   string
+	name    string
+	kind    subroutineKind
+	fields  int
+	args    int
+	void    bool
+	prelude int
+}
+
+// Decompile reconstructs a Jack class for every .vm file in lines, recognising the code patterns emitted
+// by JackAnalyser and the course compiler.
+// Variable names are lost in compilation, so variables are named after their segment and index and declared as int.
+func Decompile(lines []program.Line) ([]Class, error) {
+	functions := program.Functions(lines)
+
+	callArgs := make(map[string]int)
+	for _, line := range lines {
+		if fc, ok := line.Command.(*command.FunctionCommand); ok && fc.Type() == command.Call && fc.Args > callArgs[fc.Name] {
+			callArgs[fc.Name] = fc.Args
+		}
+	}
+
+	var files []string
+	byFile := make(map[string][]*subroutine)
+	kinds := make(map[string]subroutineKind)
+	for _, f := range functions {
+		if f.Name == "" {
+			return nil, fmt.Errorf("%s: commands found outside of a function", f.Declaration.Position())
+		}
+
+		s := analyse(f, callArgs[f.Name])
+		if _, ok := byFile[f.Declaration.File]; !ok {
+			files = append(files, f.Declaration.File)
+		}
+		byFile[f.Declaration.File] = append(byFile[f.Declaration.File], s)
+		kinds[f.Name] = s.kind
+	}
+
+	var classes []Class
+	for _, file := range files {
+		class, err := decompileClass(byFile[file], kinds)
+		if err != nil {
+			return nil, err
+		}
+		classes = append(classes, class)
+	}
+
+	return classes, nil
+}
+
+// analyse works out the kind, signature and prelude length of a function from its body.
+func analyse(f program.Function, callArgs int) *subroutine {
+	s := &subroutine{Function: f, kind: function, void: true}
+
+	s.class, s.name = f.Name, f.Name
+	if i := strings.Index(f.Name, "."); i != -1 {
+		s.class, s.name = f.Name[:i], f.Name[i+1:]
+	}
+
+	body := f.Body
+	if len(body) >= 3 && isPush(body[0], command.Constant) && isCall(body[1], "Memory.alloc", 1) && isPop(body[2], command.Pointer, 0) {
+		s.kind = constructor
+		s.fields = body[0].Command.(*command.MemoryAccessCommand).Index
+		s.prelude = 3
+	} else if len(body) >= 2 && isPushIndex(body[0], command.Argument, 0) && isPop(body[1], command.Pointer, 0) {
+		s.kind = method
+		s.prelude = 2
+	}
+
+	s.args = callArgs
+	for i, line := range body {
+		if mac, ok := line.Command.(*command.MemoryAccessCommand); ok && mac.Segment == command.Argument && mac.Index+1 > s.args {
+			s.args = mac.Index + 1
+		}
+
+		if mac, ok := line.Command.(*command.MemoryAccessCommand); ok && mac.Segment == command.This && mac.Index+1 > s.fields {
+			s.fields = mac.Index + 1
+		}
+
+		if line.Command.Type() == command.Return && (i == 0 || !isPushIndex(body[i-1], command.Constant, 0)) {
+			s.void = false
+		}
+	}
+
+	if s.kind == method && s.args > 0 {
+		s.args--
+	}
+
+	return s
+}
+
+func decompileClass(subroutines []*subroutine, kinds map[string]subroutineKind) (Class, error) {
+	name := subroutines[0].class
+	fields := 0
+	statics := 0
+
+	for _, s := range subroutines {
+		if s.class != name {
+			return Class{}, fmt.Errorf("%s: function %s does not belong to class %s", s.Declaration.Position(), s.Name, name)
+		}
+
+		if s.kind != function && s.fields > fields {
+			fields = s.fields
+		}
+
+		for _, line := range s.Body {
+			if mac, ok := line.Command.(*command.MemoryAccessCommand); ok && mac.Segment == command.Static && mac.Index+1 > statics {
+				statics = mac.Index + 1
+			}
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("class %s {\n", name))
+	if fields > 0 {
+		b.WriteString(fmt.Sprintf("%sfield int %s;\n", indent, variableList("field", fields)))
+	}
+	if statics > 0 {
+		b.WriteString(fmt.Sprintf("%sstatic int %s;\n", indent, variableList("static", statics)))
+	}
+
+	// JackAnalyser names its labels COND_CLASS_n, where the course compiler uses IF_TRUE0, WHILE_EXP0 and so on
+	baseFirst := false
+	for _, s := range subroutines {
+		for _, line := range s.Body {
+			if bc, ok := line.Command.(*command.BranchingCommand); ok && bc.Type() == command.Label && strings.HasPrefix(bc.Label, "COND_") {
+				baseFirst = true
+			}
+		}
+	}
+
+	for _, s := range subroutines {
+		d := decompiler{subroutine: s, kinds: kinds, body: s.Body[s.prelude:], temp: make(map[int]expression), baseFirst: baseFirst}
+		statements, err := d.parseStatements(func() bool { return false })
+		if err != nil {
+			return Class{}, err
+		}
+
+		b.WriteString("\n")
+		b.WriteString(s.signature())
+		if s.Locals > 0 {
+			b.WriteString(fmt.Sprintf("%s%svar int %s;\n\n", indent, indent, variableList("local", s.Locals)))
+		}
+		writeStatements(&b, statements, 2)
+		b.WriteString(fmt.Sprintf("%s}\n", indent))
+	}
+	b.WriteString("}\n")
+
+	return Class{Name: name, Source: b.String()}, nil
+}
+
+func (s *subroutine) signature() string {
+	returnType := "int"
+	if s.kind == constructor {
+		returnType = s.class
+	} else if s.void {
+		returnType = "void"
+	}
+
+	params := make([]string, s.args)
+	for i := range params {
+		params[i] = fmt.Sprintf("int %s", variableName(command.Argument, i))
+	}
+
+	return fmt.Sprintf("%s%s %s %s(%s) {\n", indent, subroutineKindNames[s.kind], returnType, s.name, strings.Join(params, ", "))
+}
+
+func variableList(prefix string, count int) string {
+	names := make([]string, count)
+	for i := range names {
+		names[i] = fmt.Sprintf("%s%d", prefix, i)
+	}
+
+	return strings.Join(names, ", ")
+}
+
+func variableName(segment command.Segment, index int) string {
+	switch segment {
+	case command.Argument:
+		return fmt.Sprintf("arg%d", index)
+	case command.This:
+		return fmt.Sprintf("field%d", index)
+	default:
+		return fmt.Sprintf("%s%d", segment, index)
+	}
+}
+
+func isPush(line program.Line, segment command.Segment) bool {
+	mac, ok := line.Command.(*command.MemoryAccessCommand)
+	return ok && mac.Type() == command.Push && mac.Segment == segment
+}
+
+func isPushIndex(line program.Line, segment command.Segment, index int) bool {
+	return isPush(line, segment) && line.Command.(*command.MemoryAccessCommand).Index == index
+}
+
+func isPop(line program.Line, segment command.Segment, index int) bool {
+	mac, ok := line.Command.(*command.MemoryAccessCommand)
+	return ok && mac.Type() == command.Pop && mac.Segment == segment && mac.Index == index
+}
+
+func isCall(line program.Line, name string, args int) bool {
+	fc, ok := line.Command.(*command.FunctionCommand)
+	return ok && fc.Type() == command.Call && fc.Name == name && fc.Args == args
+}
+
+func isBranch(line program.Line, typ command.CommandType, label string) bool {
+	bc, ok := line.Command.(*command.BranchingCommand)
+	return ok && bc.Type() == typ && (label == "" || cfg.LabelKey(bc.Label) == cfg.LabelKey(label))
+}
+
+func branchLabel(line program.Line) string {
+	return line.Command.(*command.BranchingCommand).Label
+}
@@ -0,0 +1,222 @@
+package decompiler
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ChelseaDH/VMTranslator/program"
+)
+
+type decompileTest struct {
+	input     string
+	expOutput []string
+	expectErr bool
+}
+
+var decompileTests = []decompileTest{
+	{
+		// Course compiler layout
+		input: `function Point.new 0
+push constant 2
+call Memory.alloc 1
+pop pointer 0
+push argument 0
+pop this 0
+push argument 1
+pop this 1
+push pointer 0
+return
+function Point.isOrigin 0
+push argument 0
+pop pointer 0
+push this 0
+push constant 0
+eq
+if-goto IF_TRUE0
+goto IF_FALSE0
+label IF_TRUE0
+push constant 0
+not
+return
+label IF_FALSE0
+push constant 0
+return`,
+		expOutput: []string{
+			"class Point {\n    field int field0, field1;\n",
+			"    constructor Point new(int arg0, int arg1) {\n        let field0 = arg0;\n        let field1 = arg1;\n        return this;\n    }\n",
+			"    method int isOrigin() {\n        if (field0 = 0) {\n            return true;\n        }\n        return 0;\n    }\n",
+		},
+	},
+	{
+		// JackAnalyser layout, which pushes an array before its index when writing an element
+		input: `function Main.main 2
+push constant 0
+pop local 0
+label COND_MAIN_0
+push local 0
+push constant 10
+lt
+not
+if-goto COND_MAIN_1
+push local 1
+push local 0
+add
+push local 0
+push constant 2
+call Math.multiply 2
+pop temp 0
+pop pointer 1
+push temp 0
+pop that 0
+push local 0
+push constant 1
+add
+pop local 0
+goto COND_MAIN_0
+label COND_MAIN_1
+push constant 2
+call String.new 1
+push constant 104
+call String.appendChar 2
+push constant 105
+call String.appendChar 2
+call Output.printString 1
+pop temp 0
+push constant 0
+return`,
+		expOutput: []string{
+			"    function void main() {\n        var int local0, local1;\n\n",
+			"        while (local0 < 10) {\n            let local1[local0] = local0 * 2;\n            let local0 = local0 + 1;\n        }\n",
+			"        do Output.printString(\"hi\");\n        return;\n",
+		},
+	},
+	{
+		input: `function Main.main 0
+goto SOMEWHERE
+push constant 0
+return`,
+		expectErr: true,
+	},
+}
+
+func TestDecompile(t *testing.T) {
+	for _, test := range decompileTests {
+		lines, err := program.Read("test.vm", strings.NewReader(test.input))
+		if err != nil {
+			t.Fatalf("unexpected error %q", err)
+		}
+
+		classes, err := Decompile(lines)
+		if err == nil && test.expectErr {
+			t.Errorf("expected an error but none returned for %s", test.input)
+		}
+
+		if err != nil && !test.expectErr {
+			t.Errorf("did not expect an error, but %q returned for %s", err, test.input)
+		}
+
+		if err != nil {
+			continue
+		}
+
+		for _, exp := range test.expOutput {
+			if !strings.Contains(classes[0].Source, exp) {
+				t.Errorf("expected output to contain\n%s\ngot\n%s", exp, classes[0].Source)
+			}
+		}
+	}
+}
+
+func TestDecompile_GameOfLife(t *testing.T) {
+	lines, err := program.ReadPath("../../../09/GameOfLife")
+	if err != nil {
+		t.Fatalf("unexpected error %q", err)
+	}
+
+	classes, err := Decompile(lines)
+	if err != nil {
+		t.Fatalf("did not expect an error, but %q returned", err)
+	}
+
+	if len(classes) != 6 {
+		t.Errorf("expected 6 classes, got %d", len(classes))
+	}
+}
+
+// TestDecompile_Recompile decompiles JackAnalyser's output for the game of life and the OS, and checks that
+// compiling the decompiled classes again gives the same VM code.
+func TestDecompile_Recompile(t *testing.T) {
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go is not installed")
+	}
+	analyser, err := filepath.Abs("../../../10/JackAnalyser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compiler := filepath.Join(t.TempDir(), "JackAnalyser")
+	build := exec.Command(goTool, "build", "-o", compiler, ".")
+	build.Dir = analyser
+	if output, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("building JackAnalyser failed: %s\n%s", err, output)
+	}
+
+	compile := func(dir string) {
+		if output, err := exec.Command(compiler, dir).CombinedOutput(); err != nil {
+			t.Fatalf("compiling %s failed: %s\n%s", dir, err, output)
+		}
+	}
+
+	for _, source := range []string{"../../../09/GameOfLife/source", "../../../12"} {
+		original, recompiled := t.TempDir(), t.TempDir()
+		sources, err := filepath.Glob(filepath.Join(source, "*.jack"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range sources {
+			data, err := os.ReadFile(name)
+			if err == nil {
+				err = os.WriteFile(filepath.Join(original, filepath.Base(name)), data, 0644)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		compile(original)
+
+		lines, err := program.ReadPath(original)
+		if err != nil {
+			t.Fatal(err)
+		}
+		classes, err := Decompile(lines)
+		if err != nil {
+			t.Fatalf("did not expect an error, but %q returned for %s", err, source)
+		}
+		for _, class := range classes {
+			err = os.WriteFile(filepath.Join(recompiled, class.Name+".jack"), []byte(class.Source), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		compile(recompiled)
+
+		for _, name := range sources {
+			vm := strings.TrimSuffix(filepath.Base(name), ".jack") + ".vm"
+			exp, err := os.ReadFile(filepath.Join(original, vm))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(filepath.Join(recompiled, vm))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, exp) {
+				t.Errorf("expected %s to compile to the same VM code once decompiled", vm)
+			}
+		}
+	}
+}
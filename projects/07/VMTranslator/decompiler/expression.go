@@ -0,0 +1,135 @@
+package decompiler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// expression is a Jack expression reconstructed from the VM working stack.
+type expression interface {
+	// jack renders the expression, wrapping it in brackets when it must be usable as a single term.
+	jack(term bool) string
+}
+
+type intConst struct {
+	value int
+}
+
+func (i intConst) jack(term bool) string {
+	return strconv.Itoa(i.value)
+}
+
+type variable struct {
+	name string
+}
+
+func (v variable) jack(term bool) string {
+	return v.name
+}
+
+type thisConst struct{}
+
+func (t thisConst) jack(term bool) string {
+	return "this"
+}
+
+type arrayAccess struct {
+	name  string
+	index expression
+}
+
+func (a arrayAccess) jack(term bool) string {
+	return fmt.Sprintf("%s[%s]", a.name, a.index.jack(false))
+}
+
+type unary struct {
+	operator string
+	operand  expression
+}
+
+func (u unary) jack(term bool) string {
+	// The course compiler writes true as "push constant 0, not"
+	if i, ok := u.operand.(intConst); ok && u.operator == "~" && i.value == 0 {
+		return "true"
+	}
+
+	return u.operator + u.operand.jack(true)
+}
+
+type binary struct {
+	operator    string
+	left, right expression
+}
+
+func (b binary) jack(term bool) string {
+	s := fmt.Sprintf("%s %s %s", b.left.jack(true), b.operator, b.right.jack(true))
+	if term {
+		return "(" + s + ")"
+	}
+	return s
+}
+
+// call is a subroutine call. Method calls are written as functions taking the object as their first argument,
+// which compiles to the same VM code, unless the method is called on this from within its own class.
+type call struct {
+	class, name string
+	args        []expression
+	implicit    bool
+}
+
+func (c call) jack(term bool) string {
+	args := c.args
+	name := fmt.Sprintf("%s.%s", c.class, c.name)
+	if c.implicit {
+		args = args[1:]
+		name = c.name
+	}
+
+	rendered := make([]string, len(args))
+	for i, a := range args {
+		rendered[i] = a.jack(false)
+	}
+
+	return fmt.Sprintf("%s(%s)", name, strings.Join(rendered, ", "))
+}
+
+// stringConst is a string literal, built up by a call to String.new followed by a call to String.appendChar per character.
+// Until every character has been appended it is rendered as the equivalent calls.
+type stringConst struct {
+	length int
+	chars  []int
+}
+
+func (s stringConst) complete() bool {
+	return len(s.chars) == s.length
+}
+
+func (s stringConst) jack(term bool) string {
+	if s.complete() {
+		var b strings.Builder
+		for _, c := range s.chars {
+			b.WriteRune(rune(c))
+		}
+		return "\"" + b.String() + "\""
+	}
+
+	rendered := fmt.Sprintf("String.new(%d)", s.length)
+	for _, c := range s.chars {
+		rendered = fmt.Sprintf("String.appendChar(%s, %d)", rendered, c)
+	}
+	return rendered
+}
+
+// printable reports whether c can appear inside a Jack string literal.
+func printable(c int) bool {
+	return c >= 32 && c <= 126 && c != '"'
+}
+
+func negate(e expression) expression {
+	if u, ok := e.(unary); ok && u.operator == "~" {
+		return u.operand
+	}
+
+	return unary{operator: "~", operand: e}
+}
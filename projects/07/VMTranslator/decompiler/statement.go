@@ -0,0 +1,512 @@
+package decompiler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ChelseaDH/VMTranslator/command"
+	"github.com/ChelseaDH/VMTranslator/program"
+)
+
+type statement interface {
+	write(b *strings.Builder, depth int)
+}
+
+type letStatement struct {
+	target expression
+	value  expression
+}
+
+func (s letStatement) write(b *strings.Builder, depth int) {
+	b.WriteString(fmt.Sprintf("%slet %s = %s;\n", strings.Repeat(indent, depth), s.target.jack(false), s.value.jack(false)))
+}
+
+type doStatement struct {
+	call call
+}
+
+func (s doStatement) write(b *strings.Builder, depth int) {
+	b.WriteString(fmt.Sprintf("%sdo %s;\n", strings.Repeat(indent, depth), s.call.jack(false)))
+}
+
+type returnStatement struct {
+	value expression
+}
+
+func (s returnStatement) write(b *strings.Builder, depth int) {
+	if s.value == nil {
+		b.WriteString(fmt.Sprintf("%sreturn;\n", strings.Repeat(indent, depth)))
+		return
+	}
+
+	b.WriteString(fmt.Sprintf("%sreturn %s;\n", strings.Repeat(indent, depth), s.value.jack(false)))
+}
+
+type ifStatement struct {
+	condition expression
+	body      []statement
+	elseBody  []statement
+}
+
+func (s ifStatement) write(b *strings.Builder, depth int) {
+	prefix := strings.Repeat(indent, depth)
+	b.WriteString(fmt.Sprintf("%sif (%s) {\n", prefix, s.condition.jack(false)))
+	writeStatements(b, s.body, depth+1)
+	if len(s.elseBody) > 0 {
+		b.WriteString(fmt.Sprintf("%s} else {\n", prefix))
+		writeStatements(b, s.elseBody, depth+1)
+	}
+	b.WriteString(fmt.Sprintf("%s}\n", prefix))
+}
+
+type whileStatement struct {
+	condition expression
+	body      []statement
+}
+
+func (s whileStatement) write(b *strings.Builder, depth int) {
+	prefix := strings.Repeat(indent, depth)
+	b.WriteString(fmt.Sprintf("%swhile (%s) {\n", prefix, s.condition.jack(false)))
+	writeStatements(b, s.body, depth+1)
+	b.WriteString(fmt.Sprintf("%s}\n", prefix))
+}
+
+func writeStatements(b *strings.Builder, statements []statement, depth int) {
+	for _, s := range statements {
+		s.write(b, depth)
+	}
+}
+
+// decompiler rebuilds the statements of a subroutine by evaluating its commands against a stack of expressions.
+// Statement boundaries are the points at which the stack is empty.
+type decompiler struct {
+	*subroutine
+	kinds map[string]subroutineKind
+	body  []program.Line
+	pos   int
+	stack []expression
+	that  expression
+	temp  map[int]expression
+	// baseFirst is set for JackAnalyser's output, which pushes an array before its index when writing an element
+	baseFirst bool
+}
+
+func (d *decompiler) errorf(format string, a ...interface{}) error {
+	line := d.Declaration
+	if d.pos < len(d.body) {
+		line = d.body[d.pos]
+	}
+
+	return fmt.Errorf("%s: %s", line.Position(), fmt.Sprintf(format, a...))
+}
+
+func (d *decompiler) push(e expression) {
+	d.stack = append(d.stack, e)
+}
+
+func (d *decompiler) pop() (expression, error) {
+	if len(d.stack) == 0 {
+		return nil, d.errorf("working stack is empty")
+	}
+
+	e := d.stack[len(d.stack)-1]
+	d.stack = d.stack[:len(d.stack)-1]
+	return e, nil
+}
+
+// at reports whether the command offset places ahead is a branching command of the given type and label.
+// An empty label matches any label.
+func (d *decompiler) at(offset int, typ command.CommandType, label string) bool {
+	i := d.pos + offset
+	return i < len(d.body) && isBranch(d.body[i], typ, label)
+}
+
+// parseStatements decompiles commands until stop reports true at a statement boundary or the body ends.
+func (d *decompiler) parseStatements(stop func() bool) ([]statement, error) {
+	var statements []statement
+
+	for d.pos < len(d.body) {
+		if len(d.stack) == 0 && stop() {
+			return statements, nil
+		}
+
+		line := d.body[d.pos]
+		switch line.Command.Type() {
+		case command.Label:
+			s, err := d.parseWhile()
+			if err != nil {
+				return nil, err
+			}
+			statements = append(statements, s)
+
+		case command.IfGoto:
+			s, err := d.parseIf()
+			if err != nil {
+				return nil, err
+			}
+			statements = append(statements, s)
+
+		case command.Goto:
+			return nil, d.errorf("unstructured %s", line.Command)
+
+		default:
+			s, err := d.step(line.Command)
+			if err != nil {
+				return nil, err
+			}
+			d.pos++
+
+			if s != nil {
+				if len(d.stack) != 0 {
+					return nil, d.errorf("statement completed with %d values left on the working stack", len(d.stack))
+				}
+				statements = append(statements, s)
+			}
+		}
+	}
+
+	if len(d.stack) != 0 {
+		return nil, d.errorf("body ended with %d values left on the working stack", len(d.stack))
+	}
+
+	return statements, nil
+}
+
+// parseWhile decompiles "label L, condition, if-goto X, body, goto L, label X".
+func (d *decompiler) parseWhile() (statement, error) {
+	start := branchLabel(d.body[d.pos])
+	d.pos++
+
+	for !d.at(0, command.IfGoto, "") {
+		if d.pos >= len(d.body) || d.body[d.pos].Command.Type() == command.Label || d.body[d.pos].Command.Type() == command.Goto {
+			return nil, d.errorf("label %s does not start a while loop", start)
+		}
+
+		s, err := d.step(d.body[d.pos].Command)
+		if err != nil {
+			return nil, err
+		}
+		if s != nil {
+			return nil, d.errorf("label %s does not start a while loop", start)
+		}
+		d.pos++
+	}
+
+	exit := branchLabel(d.body[d.pos])
+	condition, err := d.pop()
+	if err != nil {
+		return nil, err
+	}
+	if len(d.stack) != 0 {
+		return nil, d.errorf("while condition leaves values on the working stack")
+	}
+	d.pos++
+
+	body, err := d.parseStatements(func() bool {
+		return d.at(0, command.Goto, start) && d.at(1, command.Label, exit)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !d.at(0, command.Goto, start) {
+		return nil, d.errorf("while loop starting at label %s is not closed", start)
+	}
+	d.pos += 2
+
+	return whileStatement{condition: negate(condition), body: body}, nil
+}
+
+// parseIf decompiles the two if statement layouts:
+// "condition, if-goto T, goto F, label T, body, [goto E, label F, else body, label E | label F]" from the course compiler and
+// "condition, if-goto F, body, goto E, label F, else body, label E" from JackAnalyser, where the condition is negated.
+func (d *decompiler) parseIf() (statement, error) {
+	value, err := d.pop()
+	if err != nil {
+		return nil, err
+	}
+
+	target := branchLabel(d.body[d.pos])
+	condition := negate(value)
+	elseLabel := target
+	if d.at(1, command.Goto, "") && d.at(2, command.Label, target) {
+		condition = value
+		elseLabel = branchLabel(d.body[d.pos+1])
+		d.pos += 2
+	}
+	d.pos++
+
+	body, err := d.parseStatements(func() bool {
+		return d.at(0, command.Label, elseLabel) || (d.at(0, command.Goto, "") && d.at(1, command.Label, elseLabel))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if d.at(0, command.Label, elseLabel) {
+		d.pos++
+		return ifStatement{condition: condition, body: body}, nil
+	}
+	if !d.at(0, command.Goto, "") {
+		return nil, d.errorf("if statement jumping to label %s is not closed", elseLabel)
+	}
+
+	end := branchLabel(d.body[d.pos])
+	d.pos += 2
+
+	elseBody, err := d.parseStatements(func() bool { return d.at(0, command.Label, end) })
+	if err != nil {
+		return nil, err
+	}
+	if !d.at(0, command.Label, end) {
+		return nil, d.errorf("else branch ending at label %s is not closed", end)
+	}
+	d.pos++
+
+	return ifStatement{condition: condition, body: body, elseBody: elseBody}, nil
+}
+
+var binaryOperators = map[command.CommandType]string{
+	command.Add: "+",
+	command.Sub: "-",
+	command.Eq:  "=",
+	command.Gt:  ">",
+	command.Lt:  "<",
+	command.And: "&",
+	command.Or:  "|",
+}
+
+// step applies a non-branching command to the working stack, returning a statement if the command completes one.
+func (d *decompiler) step(c command.Command) (statement, error) {
+	switch c.Type() {
+	case command.Add, command.Sub, command.Eq, command.Gt, command.Lt, command.And, command.Or:
+		right, err := d.pop()
+		if err != nil {
+			return nil, err
+		}
+		left, err := d.pop()
+		if err != nil {
+			return nil, err
+		}
+		d.push(binary{operator: binaryOperators[c.Type()], left: left, right: right})
+
+	case command.Neg, command.Not:
+		operand, err := d.pop()
+		if err != nil {
+			return nil, err
+		}
+
+		operator := "-"
+		if c.Type() == command.Not {
+			operator = "~"
+		}
+		d.push(unary{operator: operator, operand: operand})
+
+	case command.Push:
+		e, err := d.read(c.(*command.MemoryAccessCommand))
+		if err != nil {
+			return nil, err
+		}
+		d.push(e)
+
+	case command.Pop:
+		return d.write(c.(*command.MemoryAccessCommand))
+
+	case command.Call:
+		return nil, d.call(c.(*command.FunctionCommand))
+
+	case command.Return:
+		value, err := d.pop()
+		if err != nil {
+			return nil, err
+		}
+
+		if d.void {
+			return returnStatement{}, nil
+		}
+		if d.kind == constructor {
+			if _, ok := value.(thisConst); !ok {
+				return nil, d.errorf("constructor %s does not return this", d.Name)
+			}
+		}
+		return returnStatement{value: value}, nil
+
+	default:
+		return nil, d.errorf("unexpected %s", c)
+	}
+
+	return nil, nil
+}
+
+// read returns the expression pushed by a push command.
+func (d *decompiler) read(c *command.MemoryAccessCommand) (expression, error) {
+	switch c.Segment {
+	case command.Constant:
+		return intConst{value: c.Index}, nil
+
+	case command.Local, command.Argument, command.Static, command.This:
+		return d.variable(c.Segment, c.Index)
+
+	case command.Pointer:
+		if c.Index == 0 && d.kind != function {
+			return thisConst{}, nil
+		}
+
+	case command.Temp:
+		if e, ok := d.temp[c.Index]; ok {
+			delete(d.temp, c.Index)
+			return e, nil
+		}
+
+	case command.That:
+		return d.arrayElement(c.Index, false)
+	}
+
+	return nil, d.errorf("unsupported %s", c)
+}
+
+// write handles a pop command, returning the let or do statement it completes, if any.
+func (d *decompiler) write(c *command.MemoryAccessCommand) (statement, error) {
+	value, err := d.pop()
+	if err != nil {
+		return nil, err
+	}
+
+	switch c.Segment {
+	case command.Local, command.Argument, command.Static, command.This:
+		target, err := d.variable(c.Segment, c.Index)
+		if err != nil {
+			return nil, err
+		}
+		return letStatement{target: target, value: value}, nil
+
+	case command.That:
+		target, err := d.arrayElement(c.Index, true)
+		if err != nil {
+			return nil, err
+		}
+		return letStatement{target: target, value: value}, nil
+
+	case command.Pointer:
+		if c.Index == 1 {
+			d.that = value
+			return nil, nil
+		}
+
+	case command.Temp:
+		// A discarded value is a do statement, otherwise it is held for an array assignment
+		if len(d.stack) != 0 {
+			d.temp[c.Index] = value
+			return nil, nil
+		}
+
+		switch v := value.(type) {
+		case call:
+			return doStatement{call: v}, nil
+		case binary:
+			if v.operator == "*" || v.operator == "/" {
+				name := map[string]string{"*": "multiply", "/": "divide"}[v.operator]
+				return doStatement{call: call{class: "Math", name: name, args: []expression{v.left, v.right}}}, nil
+			}
+		}
+	}
+
+	return nil, d.errorf("unsupported %s", c)
+}
+
+func (d *decompiler) variable(segment command.Segment, index int) (expression, error) {
+	if segment == command.This && d.kind == function {
+		return nil, d.errorf("this segment used outside of a method or constructor")
+	}
+
+	if segment == command.Argument && d.kind == method {
+		if index == 0 {
+			return thisConst{}, nil
+		}
+		index--
+	}
+
+	return variable{name: variableName(segment, index)}, nil
+}
+
+// arrayElement converts the address held in pointer 1 back into an array access, which must be a variable plus an index.
+// write is set when the element is being assigned to.
+func (d *decompiler) arrayElement(offset int, write bool) (expression, error) {
+	if d.that == nil || offset != 0 {
+		return nil, d.errorf("that segment accessed without an array address in pointer 1")
+	}
+
+	address := d.that
+	if v, ok := address.(variable); ok {
+		return arrayAccess{name: v.name, index: intConst{value: 0}}, nil
+	}
+
+	// Both compilers push the array variable second when reading, and the course compiler also does so when writing,
+	// but JackAnalyser pushes it first when writing
+	if b, ok := address.(binary); ok && b.operator == "+" {
+		array, index := b.right, b.left
+		if write && d.baseFirst {
+			array, index = b.left, b.right
+		}
+		if v, ok := array.(variable); ok {
+			return arrayAccess{name: v.name, index: index}, nil
+		}
+		if v, ok := index.(variable); ok {
+			return arrayAccess{name: v.name, index: array}, nil
+		}
+	}
+
+	return nil, d.errorf("array address %s is not a variable plus an index", address.jack(false))
+}
+
+// call pops the arguments of c and pushes the call, folding the OS calls emitted for operators and string literals.
+func (d *decompiler) call(c *command.FunctionCommand) error {
+	if len(d.stack) < c.Args {
+		return d.errorf("%s needs %d arguments but the working stack holds %d", c, c.Args, len(d.stack))
+	}
+
+	args := make([]expression, c.Args)
+	copy(args, d.stack[len(d.stack)-c.Args:])
+	d.stack = d.stack[:len(d.stack)-c.Args]
+
+	switch {
+	case c.Name == "Math.multiply" && c.Args == 2:
+		d.push(binary{operator: "*", left: args[0], right: args[1]})
+		return nil
+
+	case c.Name == "Math.divide" && c.Args == 2:
+		d.push(binary{operator: "/", left: args[0], right: args[1]})
+		return nil
+
+	case c.Name == "String.new" && c.Args == 1:
+		if length, ok := args[0].(intConst); ok {
+			d.push(stringConst{length: length.value})
+			return nil
+		}
+
+	case c.Name == "String.appendChar" && c.Args == 2:
+		s, isString := args[0].(stringConst)
+		char, isChar := args[1].(intConst)
+		if isString && isChar && !s.complete() && printable(char.value) {
+			d.push(stringConst{length: s.length, chars: append(append([]int{}, s.chars...), char.value)})
+			return nil
+		}
+	}
+
+	class, name := c.Name, c.Name
+	if i := strings.Index(c.Name, "."); i != -1 {
+		class, name = c.Name[:i], c.Name[i+1:]
+	}
+
+	_, isThis := firstArg(args).(thisConst)
+	implicit := class == d.class && d.kinds[c.Name] == method && isThis
+
+	d.push(call{class: class, name: name, args: args, implicit: implicit})
+	return nil
+}
+
+func firstArg(args []expression) expression {
+	if len(args) == 0 {
+		return nil
+	}
+	return args[0]
+}
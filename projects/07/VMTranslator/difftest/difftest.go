@@ -0,0 +1,350 @@
+// Package difftest runs a VM program on the vm interpreter and, once translated, on the Hack CPU emulator,
+// comparing the two as they go to find where the translation behaves differently from the VM.
+package difftest
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/ChelseaDH/VMTranslator/command"
+	"github.com/ChelseaDH/VMTranslator/hack"
+	"github.com/ChelseaDH/VMTranslator/program"
+	"github.com/ChelseaDH/VMTranslator/translator"
+	"github.com/ChelseaDH/VMTranslator/vm"
+)
+
+const (
+	// HeapBase and HeapEnd bound the RAM the Jack OS allocates objects and arrays from.
+	HeapBase = 2048
+	HeapEnd  = 16384
+	// tempCount is the number of words in the temp segment.
+	tempCount = 8
+	// maxInstructions is the most instructions the translation of a single command may run,
+	// beyond which the CPU is taken to be stuck.
+	maxInstructions = 10000
+	// maxDifferences is the most differences listed by Divergence.String.
+	maxDifferences = 16
+)
+
+// commandLabel names the label written before the translation of each line. VM labels are uppercased,
+// so the lowercase name after the $ cannot clash with them.
+const commandLabel = "difftest$command."
+
+var pointerNames = [...]string{"SP", "LCL", "ARG", "THIS", "THAT"}
+
+// Program is a VM program translated to Hack machine code, keeping where the translation of each line starts.
+type Program struct {
+	Lines []program.Line
+	Hack  *hack.Program
+	// Starts holds the ROM address of the first instruction of each line, with one more entry for the end of the program.
+	Starts []uint16
+
+	// commands holds the index of the line each ROM address was translated from, or -1 for the bootstrap code
+	commands []int
+	statics  []static
+}
+
+// static is a static variable, named as the translator names it.
+type static struct {
+	file  string
+	index int
+	name  string
+}
+
+// Translate translates lines to Hack machine code using translator.Translator, starting with the bootstrap
+// code if the program declares Sys.init as vm.New does.
+func Translate(lines []program.Line) (*Program, error) {
+	var asm bytes.Buffer
+	t := &translator.Translator{Output: &asm}
+
+	if declaresSysInit(lines) {
+		err := t.Initialise()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		asm.WriteString("@" + strconv.Itoa(vm.StackBase) + "\nD=A\n@SP\nM=D\n")
+	}
+
+	p := &Program{Lines: lines}
+	seen := make(map[string]bool)
+	file := ""
+	for i, line := range lines {
+		if i == 0 || line.File != file {
+			file = line.File
+			t.SetNamespace(namespace(file))
+		}
+
+		fmt.Fprintf(&asm, "(%s%d)\n", commandLabel, i)
+		err := t.Translate(line.Command)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", line.Position(), err)
+		}
+
+		if mac, ok := line.Command.(*command.MemoryAccessCommand); ok && mac.Segment == command.Static {
+			name := namespace(file) + "." + strconv.Itoa(mac.Index)
+			if !seen[name] {
+				seen[name] = true
+				p.statics = append(p.statics, static{file: file, index: mac.Index, name: name})
+			}
+		}
+	}
+	fmt.Fprintf(&asm, "(%s%d)\n", commandLabel, len(lines))
+	t.Terminate()
+
+	var err error
+	p.Hack, err = hack.Assemble(&asm)
+	if err != nil {
+		return nil, err
+	}
+
+	p.Starts = make([]uint16, len(lines)+1)
+	for i := range p.Starts {
+		p.Starts[i] = p.Hack.Labels[commandLabel+strconv.Itoa(i)]
+	}
+
+	p.commands = make([]int, len(p.Hack.ROM))
+	for address := range p.commands {
+		p.commands[address] = -1
+	}
+	for i := 0; i < len(lines); i++ {
+		for address := p.Starts[i]; address < p.Starts[i+1]; address++ {
+			p.commands[address] = i
+		}
+	}
+
+	return p, nil
+}
+
+func declaresSysInit(lines []program.Line) bool {
+	for _, line := range lines {
+		if fc, ok := line.Command.(*command.FunctionCommand); ok && fc.Type() == command.Function && fc.Name == "Sys.init" {
+			return true
+		}
+	}
+	return false
+}
+
+func namespace(file string) string {
+	return strings.TrimSuffix(path.Base(file), ".vm")
+}
+
+// Difference is a RAM address whose value the two machines disagree on.
+type Difference struct {
+	Address int
+	// Name describes the address, such as SP, temp 2, static Main.0 or heap 2050.
+	Name string
+	VM   int16
+	CPU  int16
+}
+
+func (d Difference) String() string {
+	return fmt.Sprintf("%s: VM %d, CPU %d", d.Name, d.VM, d.CPU)
+}
+
+// Divergence describes the first command after which the two machines disagree.
+type Divergence struct {
+	// Index is the index of the command in the program's lines, and Line the command itself.
+	Index    int
+	Line     program.Line
+	Function string
+	// Steps counts the commands the VM had run, including this one.
+	Steps uint64
+	// Reason is set if the CPU went somewhere other than the next command the VM ran, rather than
+	// reaching it with different memory.
+	Reason      string
+	Differences []Difference
+}
+
+func (d *Divergence) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "diverged after %s: %s in %s, command %d\n", d.Line.Position(), d.Line.Command, d.Function, d.Steps)
+	if d.Reason != "" {
+		fmt.Fprintf(&b, "  %s\n", d.Reason)
+	}
+
+	for i, difference := range d.Differences {
+		if i == maxDifferences {
+			fmt.Fprintf(&b, "  and %d more\n", len(d.Differences)-i)
+			break
+		}
+		fmt.Fprintf(&b, "  %s\n", difference)
+	}
+
+	return b.String()
+}
+
+// Compare runs p on both machines until the VM program finishes or halts, or until limit VM commands have
+// run if limit is not 0, returning the first command after which they disagree or nil if they never do.
+//
+// The machines are kept in step one command at a time, so that any jump of the translation to the wrong
+// command is found at once. Their memory is compared each time a function returns: the stack, the segment
+// pointers, temp, statics and the heap. If that finds a difference the program is run again comparing
+// after every command, to find the one that caused it.
+func Compare(p *Program, limit uint64) (*Divergence, error) {
+	divergence, err := run(p, limit, false)
+	if err != nil || divergence == nil || divergence.Reason != "" {
+		return divergence, err
+	}
+
+	first, err := run(p, divergence.Steps, true)
+	if err != nil || first == nil {
+		return divergence, err
+	}
+	return first, nil
+}
+
+func run(p *Program, limit uint64, everyCommand bool) (*Divergence, error) {
+	m, err := vm.New(p.Lines)
+	if err != nil {
+		return nil, err
+	}
+	cpu := hack.NewCPU(p.Hack)
+
+	// The bootstrap code has no line of its own, so runs until it reaches the first command the VM runs
+	for steps := 0; cpu.PC != p.Starts[m.PC]; steps++ {
+		if steps == maxInstructions || p.command(cpu.PC) != -1 {
+			return nil, fmt.Errorf("the bootstrap code did not reach %s", p.Lines[m.PC].Position())
+		}
+		_, err = cpu.Step()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for limit == 0 || m.Steps < limit {
+		if m.Finished() || m.Halted() {
+			return nil, nil
+		}
+
+		index := m.PC
+		err = m.Step()
+		if err != nil {
+			return nil, err
+		}
+
+		divergence := &Divergence{
+			Index:    index,
+			Line:     p.Lines[index],
+			Function: m.Function(index),
+			Steps:    m.Steps,
+		}
+
+		for steps := 0; p.command(cpu.PC) == index; steps++ {
+			if steps == maxInstructions {
+				divergence.Reason = fmt.Sprintf("the CPU was still running the command after %d instructions", steps)
+				return divergence, nil
+			}
+			_, err = cpu.Step()
+			if err != nil {
+				divergence.Reason = err.Error()
+				return divergence, nil
+			}
+		}
+
+		// Running the first function's return leaves the VM past its last command, where the CPU has no line to compare
+		if m.Finished() {
+			return nil, nil
+		}
+
+		if cpu.PC != p.Starts[m.PC] {
+			divergence.Reason = fmt.Sprintf("the CPU went to ROM %d rather than %s", cpu.PC, p.describe(m.PC))
+			return divergence, nil
+		}
+
+		if everyCommand || p.Lines[index].Command.Type() == command.Return {
+			divergence.Differences = p.compare(m, cpu)
+			if len(divergence.Differences) > 0 {
+				return divergence, nil
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// command returns the index of the line the instruction at address was translated from,
+// -1 for the bootstrap code or -2 for an address past the end of the program.
+func (p *Program) command(address uint16) int {
+	if int(address) >= len(p.commands) {
+		return -2
+	}
+	return p.commands[address]
+}
+
+// describe names the line at index.
+func (p *Program) describe(index int) string {
+	if index >= len(p.Lines) {
+		return "the end of the program"
+	}
+	return fmt.Sprintf("%s: %s", p.Lines[index].Position(), p.Lines[index].Command)
+}
+
+// compare returns the differences between the memory of the two machines. The saved return addresses of
+// each frame are expected to differ, as the VM saves line indexes and the CPU ROM addresses, so are checked
+// to name the same command instead.
+func (p *Program) compare(m *vm.Machine, cpu *hack.CPU) []Difference {
+	var differences []Difference
+	add := func(name string, address int, vmValue int16, cpuValue int16) {
+		if vmValue != cpuValue {
+			differences = append(differences, Difference{Address: address, Name: name, VM: vmValue, CPU: cpuValue})
+		}
+	}
+
+	for address, name := range pointerNames {
+		add(name, address, m.RAM[address], cpu.RAM[address])
+	}
+	for i := 0; i < tempCount; i++ {
+		add("temp "+strconv.Itoa(i), vm.TempBase+i, m.RAM[vm.TempBase+i], cpu.RAM[vm.TempBase+i])
+	}
+
+	for _, s := range p.statics {
+		vmAddress, _ := m.StaticAddress(s.file, s.index)
+		cpuAddress, ok := p.Hack.Variables[s.name]
+		if !ok {
+			continue
+		}
+		add("static "+s.name, int(cpuAddress), m.RAM[vmAddress], cpu.RAM[cpuAddress])
+	}
+
+	returnAddresses := make(map[int]bool)
+	lcl := int(m.RAM[vm.LCL])
+	for frames := 0; lcl >= vm.StackBase+5 && lcl < HeapBase && frames < vm.StackBase; frames++ {
+		returnAddresses[lcl-5] = true
+		lcl = int(m.RAM[lcl-4])
+	}
+
+	sp := int(m.RAM[vm.SP])
+	for address := vm.StackBase; address < sp && address < HeapBase; address++ {
+		if !returnAddresses[address] {
+			add("stack "+strconv.Itoa(address), address, m.RAM[address], cpu.RAM[address])
+			continue
+		}
+
+		// The bootstrap call returns to code with no line of its own
+		ret := int(m.RAM[address])
+		if ret >= 0 && ret < len(p.Lines) && cpu.RAM[address] != int16(p.Starts[ret]) {
+			differences = append(differences, Difference{
+				Address: address,
+				Name:    "return address " + strconv.Itoa(address),
+				VM:      m.RAM[address],
+				CPU:     cpu.RAM[address],
+			})
+		}
+	}
+
+	if vmHeap, cpuHeap := m.RAM[HeapBase:HeapEnd], cpu.RAM[HeapBase:HeapEnd]; !equal(vmHeap, cpuHeap) {
+		for i := range vmHeap {
+			add("heap "+strconv.Itoa(HeapBase+i), HeapBase+i, vmHeap[i], cpuHeap[i])
+		}
+	}
+
+	return differences
+}
+
+func equal(a []int16, b []int16) bool {
+	return *(*[HeapEnd - HeapBase]int16)(a) == *(*[HeapEnd - HeapBase]int16)(b)
+}
@@ -0,0 +1,171 @@
+package difftest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ChelseaDH/VMTranslator/program"
+)
+
+const factorial = `function Main.fact 0
+push argument 0
+push constant 1
+gt
+if-goto rec
+push constant 1
+return
+label rec
+push argument 0
+push argument 0
+push constant 1
+sub
+call Main.fact 1
+call Math.multiply 2
+return
+function Math.multiply 1
+label loop
+push argument 1
+push constant 0
+eq
+if-goto end
+push local 0
+push argument 0
+add
+pop local 0
+push argument 1
+push constant 1
+sub
+pop argument 1
+goto loop
+label end
+push local 0
+return
+function Sys.init 0
+push constant 5
+call Main.fact 1
+pop static 0
+call Main.array 0
+pop temp 1
+call Sys.halt 0
+function Main.array 1
+push constant 2048
+pop local 0
+push constant 9
+push local 0
+push constant 3
+add
+pop pointer 1
+pop that 0
+push constant 4
+pop temp 0
+push constant 1
+neg
+pop local 0
+push temp 0
+return
+function Sys.halt 0
+label loop
+goto loop`
+
+func read(t *testing.T, input string) *Program {
+	lines, err := program.Read("Main.vm", strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := Translate(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+type compareTest struct {
+	input string
+	// corrupt, if set, changes the translated program to give the CPU something to disagree with
+	corrupt     func(p *Program)
+	expIndex    int
+	expNames    []string
+	expReason   bool
+	expDiverges bool
+}
+
+var compareTests = []compareTest{
+	{input: factorial},
+	// Without Sys.init the program starts at its first command
+	{input: "push constant 7\npush constant 8\nlt\npop temp 2\nlabel END\ngoto END"},
+	{
+		input: factorial,
+		// Pushing 2049 rather than 2048 in Main.array is found before the heap is written, straight after the push
+		corrupt: func(p *Program) {
+			p.Hack.ROM[p.Starts[41]]++
+		},
+		expIndex:    41,
+		expNames:    []string{"stack 267"},
+		expDiverges: true,
+	},
+	{
+		input: factorial,
+		// Making the pop to temp 0 write temp 1 instead
+		corrupt: func(p *Program) {
+			p.Hack.ROM[p.Starts[50]+3]++
+		},
+		expIndex:    50,
+		expNames:    []string{"temp 0", "temp 1"},
+		expDiverges: true,
+	},
+	{
+		input: factorial,
+		// Making the goto in Math.multiply jump to the function's first command instead of its loop label
+		corrupt: func(p *Program) {
+			p.Hack.ROM[p.Starts[29]] = p.Starts[15]
+		},
+		expIndex:    29,
+		expReason:   true,
+		expDiverges: true,
+	},
+}
+
+func TestCompare(t *testing.T) {
+	for _, test := range compareTests {
+		p := read(t, test.input)
+		if test.corrupt != nil {
+			test.corrupt(p)
+		}
+
+		divergence, err := Compare(p, 100000)
+		if err != nil {
+			t.Errorf("did not expect an error, but %q returned", err)
+			continue
+		}
+
+		if !test.expDiverges {
+			if divergence != nil {
+				t.Errorf("expected no divergence, got %s", divergence)
+			}
+			continue
+		}
+
+		if divergence == nil {
+			t.Errorf("expected a divergence at command %d but none returned", test.expIndex)
+			continue
+		}
+
+		if divergence.Index != test.expIndex {
+			t.Errorf("expected a divergence at command %d, got %s", test.expIndex, divergence)
+		}
+
+		if test.expReason != (divergence.Reason != "") {
+			t.Errorf("expected a reason to be given %t, got %s", test.expReason, divergence)
+		}
+
+		var names []string
+		for _, difference := range divergence.Differences {
+			names = append(names, difference.Name)
+		}
+		if strings.Join(names, ", ") != strings.Join(test.expNames, ", ") {
+			t.Errorf("expected differences in %v, got %v", test.expNames, names)
+		}
+	}
+}
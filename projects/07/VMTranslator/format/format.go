@@ -0,0 +1,109 @@
+package format
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ChelseaDH/VMTranslator/cfg"
+	"github.com/ChelseaDH/VMTranslator/command"
+	"github.com/ChelseaDH/VMTranslator/parser"
+)
+
+// Options control how a program is rewritten.
+type Options struct {
+	// StripComments drops every comment rather than keeping them on their own line or after their command.
+	StripComments bool
+	// RenumberLabels renames the labels of each function to L0, L1, ... in the order they first appear.
+	RenumberLabels bool
+}
+
+// line is either a command, a comment on its own line, or a command followed by a comment.
+type line struct {
+	command command.Command
+	comment string
+}
+
+// Format rewrites the VM program read from r into its canonical form: one command per line,
+// separated by single spaces, with keywords in lowercase, labels in uppercase and no blank lines.
+// Errors are reported against file.
+func Format(file string, r io.Reader, w io.Writer, options Options) error {
+	var lines []line
+
+	scanner := bufio.NewScanner(r)
+	number := 0
+	for scanner.Scan() {
+		number++
+		text := scanner.Text()
+
+		comment := ""
+		if i := strings.Index(text, "//"); i != -1 {
+			comment = strings.TrimSpace(text[i+2:])
+			text = text[:i]
+		}
+
+		c, err := parser.Parse(text)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %s", file, number, err)
+		}
+
+		if options.StripComments {
+			comment = ""
+		}
+
+		if c != nil || comment != "" {
+			lines = append(lines, line{command: c, comment: comment})
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if options.RenumberLabels {
+		renumberLabels(lines)
+	}
+
+	out := bufio.NewWriter(w)
+	for _, l := range lines {
+		switch {
+		case l.command == nil:
+			fmt.Fprintf(out, "// %s\n", l.comment)
+		case l.comment == "":
+			fmt.Fprintf(out, "%s\n", l.command)
+		default:
+			fmt.Fprintf(out, "%s // %s\n", l.command, l.comment)
+		}
+	}
+
+	return out.Flush()
+}
+
+// renumberLabels renames labels so that functions using the same control flow use the same labels,
+// whichever compiler produced them. Labels are scoped to their function, so numbering restarts at each declaration.
+func renumberLabels(lines []line) {
+	names := make(map[string]string)
+
+	for i, l := range lines {
+		switch c := l.command.(type) {
+		case *command.FunctionCommand:
+			if c.Type() == command.Function {
+				names = make(map[string]string)
+			}
+
+		case *command.BranchingCommand:
+			key := cfg.LabelKey(c.Label)
+			name, ok := names[key]
+			if !ok {
+				name = fmt.Sprintf("L%d", len(names))
+				names[key] = name
+			}
+
+			// Copy the command rather than renaming through the pointer, which may be shared with the caller
+			renamed := *c
+			renamed.Label = name
+			lines[i].command = &renamed
+		}
+	}
+}
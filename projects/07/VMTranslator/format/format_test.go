@@ -0,0 +1,79 @@
+package format
+
+import (
+	"strings"
+	"testing"
+)
+
+type formatTest struct {
+	input     string
+	options   Options
+	expOutput string
+	expectErr bool
+}
+
+var formatTests = []formatTest{
+	{
+		input:     "// Adds two numbers\n\n  push   constant 1\t// first\npush constant 2\n\nadd\n",
+		expOutput: "// Adds two numbers\npush constant 1 // first\npush constant 2\nadd\n",
+	},
+	{
+		input:     "// Adds two numbers\npush constant 1 // first\n",
+		options:   Options{StripComments: true},
+		expOutput: "push constant 1\n",
+	},
+	{
+		input:     "function Main.a 0\nlabel loop\ngoto Loop\nif-goto END\nlabel end\nfunction Main.b 0\ngoto other\nlabel OTHER\n",
+		expOutput: "function Main.a 0\nlabel LOOP\ngoto LOOP\nif-goto END\nlabel END\nfunction Main.b 0\ngoto OTHER\nlabel OTHER\n",
+	},
+	{
+		input:     "function Main.a 0\nlabel loop\ngoto Loop\nif-goto END\nlabel end\nfunction Main.b 0\ngoto other\nlabel OTHER\n",
+		options:   Options{RenumberLabels: true},
+		expOutput: "function Main.a 0\nlabel L0\ngoto L0\nif-goto L1\nlabel L1\nfunction Main.b 0\ngoto L0\nlabel L0\n",
+	},
+	{
+		input:     "push constant 1\npush nowhere 2\n",
+		expectErr: true,
+	},
+}
+
+func TestFormat(t *testing.T) {
+	for _, test := range formatTests {
+		var output strings.Builder
+		err := Format("test.vm", strings.NewReader(test.input), &output, test.options)
+
+		if test.expectErr {
+			if err == nil {
+				t.Errorf("expected an error but none returned for %q", test.input)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("did not expect an error, but %q returned for %q", err, test.input)
+			continue
+		}
+
+		if output.String() != test.expOutput {
+			t.Errorf("expected output %q but got %q", test.expOutput, output.String())
+		}
+	}
+}
+
+func TestFormat_Idempotent(t *testing.T) {
+	for _, test := range formatTests {
+		if test.expectErr {
+			continue
+		}
+
+		var output strings.Builder
+		err := Format("test.vm", strings.NewReader(test.expOutput), &output, test.options)
+		if err != nil {
+			t.Fatalf("did not expect an error, but %q returned for %q", err, test.expOutput)
+		}
+
+		if output.String() != test.expOutput {
+			t.Errorf("formatting %q again gave %q", test.expOutput, output.String())
+		}
+	}
+}
@@ -0,0 +1,344 @@
+// Package golden runs a Hack program on the CPU emulator, or a VM program on the VM interpreter, until it halts,
+// then checks the memory and screen it leaves behind against golden files stored next to the program.
+//
+// For a program Name.asm, Name.hack or Name.vm, or a directory Name of .vm files, the golden files are:
+//
+//	Name.golden.ram  RAM ranges, one per line as "ADDRESS: VALUE VALUE ...", where the address is a number,
+//	                 predefined symbol or variable of the program and each value is at the next address.
+//	                 The static variables of a VM program are named File.index, as the translator names them
+//	Name.golden.txt  the text the Jack OS Output class printed on the screen, as screen.Text reads it
+//	                 back
+//	Name.golden.pbm  an image of the screen, as screen.WritePBM writes it
+//
+// The golden files of a directory are kept inside it. A program is only checked against the golden files that
+// exist, and needs at least one. A program that takes a long time to reach the state being tested can start from
+// Name.snapshot instead of from reset, a snapshot saved by hackdbg or jackdbg.
+package golden
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/ChelseaDH/VMTranslator/command"
+	"github.com/ChelseaDH/VMTranslator/hack"
+	"github.com/ChelseaDH/VMTranslator/screen"
+	"github.com/ChelseaDH/VMTranslator/vm"
+)
+
+// The extensions of the golden files, added to the program's path without its own extension.
+const (
+	RAMExt   = ".golden.ram"
+	TextExt  = ".golden.txt"
+	ImageExt = ".golden.pbm"
+
+	// SnapshotExt is the extension of a snapshot to start the program from.
+	SnapshotExt = ".snapshot"
+)
+
+// Run runs p from reset until it reaches a loop that jumps to itself or calls Sys.halt, returning the CPU
+// as it halted. It returns an error if the program has not halted once limit instructions have run.
+func Run(p *hack.Program, limit uint64) (*hack.CPU, error) {
+	cpu := hack.NewCPU(p)
+	return cpu, Resume(p, cpu, limit)
+}
+
+// Resume runs cpu, such as one loaded from a snapshot of p, until it halts in the same way as Run. It returns an
+// error if the program has not halted once a further limit instructions have run.
+func Resume(p *hack.Program, cpu *hack.CPU, limit uint64) error {
+	// A CPU loaded from a snapshot does not know where p's Sys.halt is
+	cpu.Halt, cpu.HasHalt = p.Labels[hack.HaltFunction]
+
+	for start := cpu.Cycles; cpu.Cycles-start < limit; {
+		if cpu.Halted() {
+			return nil
+		}
+
+		_, err := cpu.Step()
+		if err != nil {
+			return err
+		}
+	}
+
+	return fmt.Errorf("the program did not halt within %d instructions", limit)
+}
+
+// ResumeVM runs m until it halts, as vm.Machine.Halted reports when it reaches Sys.halt or a goto to the label just
+// before it. It returns an error if the program finishes without halting, or has not halted once a further limit
+// commands have run.
+func ResumeVM(m *vm.Machine, limit uint64) error {
+	for start := m.Steps; m.Steps-start < limit; {
+		if m.Halted() {
+			return nil
+		}
+		if m.Finished() {
+			return errors.New("the program finished without halting")
+		}
+
+		err := m.Step()
+		if err != nil {
+			return err
+		}
+	}
+
+	return fmt.Errorf("the program did not halt within %d commands", limit)
+}
+
+// Base returns the path the golden files of the program file or directory name are named from.
+func Base(name string) string {
+	if info, err := os.Stat(name); err == nil && info.IsDir() {
+		return path.Join(name, path.Base(name))
+	}
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+// State is the memory a program halted with, and the names of its variables.
+type State struct {
+	RAM []int16
+	// Variables maps the names of the program's variables to their addresses. Predefined symbols are always known.
+	Variables map[string]uint16
+}
+
+// CPUState returns the state of cpu, running p.
+func CPUState(p *hack.Program, cpu *hack.CPU) State {
+	return State{RAM: cpu.RAM[:], Variables: p.Variables}
+}
+
+// VMState returns the state of m, naming its static variables File.index.
+func VMState(m *vm.Machine) State {
+	state := State{RAM: m.RAM[:], Variables: make(map[string]uint16)}
+	for _, line := range m.Lines {
+		mac, ok := line.Command.(*command.MemoryAccessCommand)
+		if !ok || mac.Segment != command.Static {
+			continue
+		}
+		if address, ok := m.StaticAddress(line.File, mac.Index); ok {
+			name := fmt.Sprintf("%s.%d", strings.TrimSuffix(path.Base(line.File), ".vm"), mac.Index)
+			state.Variables[name] = uint16(address)
+		}
+	}
+	return state
+}
+
+// Mismatch is a golden file that the state of a halted program does not match.
+type Mismatch struct {
+	File        string
+	Differences []string
+}
+
+func (m Mismatch) String() string {
+	return m.File + ":\n  " + strings.Join(m.Differences, "\n  ")
+}
+
+// Check compares the state a program halted in with the golden files of the program file or directory name,
+// returning each file that does not match. If update is set, the files that do not match are rewritten with the
+// state instead.
+func Check(state State, name string, update bool) ([]Mismatch, error) {
+	base := Base(name)
+	checks := []struct {
+		ext   string
+		check func(state State, golden []byte) ([]string, []byte, error)
+	}{
+		{RAMExt, checkRAM},
+		{TextExt, checkText},
+		{ImageExt, checkImage},
+	}
+
+	var mismatches []Mismatch
+	found := false
+	for _, c := range checks {
+		file := base + c.ext
+		golden, err := os.ReadFile(file)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		found = true
+
+		differences, actual, err := c.check(state, golden)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", file, err)
+		}
+		if len(differences) == 0 {
+			continue
+		}
+
+		if update {
+			err = os.WriteFile(file, actual, 0644)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		mismatches = append(mismatches, Mismatch{File: file, Differences: differences})
+	}
+
+	if !found {
+		return nil, fmt.Errorf("no golden files found for %s", name)
+	}
+	return mismatches, nil
+}
+
+// Range is a run of consecutive RAM words listed in a golden RAM file.
+type Range struct {
+	// Name is the address as the file gives it, which may be a symbol.
+	Name    string
+	Address uint16
+	Values  []int16
+}
+
+// ReadRAM reads the ranges of a golden RAM file, resolving the names of variables. Blank lines and lines
+// starting // are ignored.
+func ReadRAM(r io.Reader, variables map[string]uint16) ([]Range, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var ranges []Range
+	for number, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		colon := strings.Index(line, ":")
+		if colon < 0 {
+			return nil, fmt.Errorf("line %d: expected an address and values, got %q", number+1, line)
+		}
+
+		rng := Range{Name: strings.TrimSpace(line[:colon])}
+		rng.Address, err = address(rng.Name, variables)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", number+1, err)
+		}
+
+		for _, field := range strings.Fields(line[colon+1:]) {
+			value, err := strconv.ParseInt(field, 10, 16)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid value %s", number+1, field)
+			}
+			rng.Values = append(rng.Values, int16(value))
+		}
+		if len(rng.Values) == 0 || int(rng.Address)+len(rng.Values) > hack.RAMSize {
+			return nil, fmt.Errorf("line %d: expected between 1 and %d values", number+1, hack.RAMSize-int(rng.Address))
+		}
+
+		ranges = append(ranges, rng)
+	}
+
+	return ranges, nil
+}
+
+// address resolves a RAM address given as a number, predefined symbol or variable.
+func address(name string, variables map[string]uint16) (uint16, error) {
+	if a, ok := hack.PredefinedSymbols[name]; ok {
+		return a, nil
+	}
+	if a, ok := variables[name]; ok {
+		return a, nil
+	}
+
+	a, err := strconv.ParseUint(name, 10, 15)
+	if err != nil {
+		return 0, fmt.Errorf("unknown address %s", name)
+	}
+	return uint16(a), nil
+}
+
+// WriteRAM writes ranges in the format ReadRAM reads.
+func WriteRAM(w io.Writer, ranges []Range) error {
+	for _, rng := range ranges {
+		values := make([]string, len(rng.Values))
+		for i, value := range rng.Values {
+			values[i] = strconv.Itoa(int(value))
+		}
+
+		_, err := fmt.Fprintf(w, "%s: %s\n", rng.Name, strings.Join(values, " "))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkRAM compares each range of the golden RAM file with the RAM. The updated file lists the same ranges,
+// without any comments.
+func checkRAM(state State, golden []byte) ([]string, []byte, error) {
+	ranges, err := ReadRAM(bytes.NewReader(golden), state.Variables)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var differences []string
+	for i := range ranges {
+		rng := &ranges[i]
+		for j, expected := range rng.Values {
+			address := int(rng.Address) + j
+			if actual := state.RAM[address]; actual != expected {
+				differences = append(differences, fmt.Sprintf("RAM[%d]: expected %d, got %d", address, expected, actual))
+				rng.Values[j] = actual
+			}
+		}
+	}
+
+	var actual bytes.Buffer
+	err = WriteRAM(&actual, ranges)
+	return differences, actual.Bytes(), err
+}
+
+// checkText compares the golden text with the text on the screen, line by line.
+func checkText(state State, golden []byte) ([]string, []byte, error) {
+	text, err := screen.Text(state.RAM[screen.Base : screen.Base+screen.Words])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	expected := strings.Split(strings.TrimSuffix(string(golden), "\n"), "\n")
+	actual := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	var differences []string
+	for i := 0; i < len(expected) || i < len(actual); i++ {
+		var e, a string
+		if i < len(expected) {
+			e = expected[i]
+		}
+		if i < len(actual) {
+			a = actual[i]
+		}
+		if e != a {
+			differences = append(differences, fmt.Sprintf("row %d: expected %q, got %q", i, e, a))
+		}
+	}
+
+	return differences, []byte(text), nil
+}
+
+// checkImage compares the golden image with the screen, counting the pixels that differ.
+func checkImage(state State, golden []byte) ([]string, []byte, error) {
+	var actual bytes.Buffer
+	err := screen.WritePBM(&actual, state.RAM[screen.Base:screen.Base+screen.Words])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if bytes.Equal(golden, actual.Bytes()) {
+		return nil, nil, nil
+	}
+	if len(golden) != actual.Len() || !bytes.HasPrefix(golden, actual.Bytes()[:actual.Len()-screen.Words*2]) {
+		return []string{fmt.Sprintf("expected a %dx%d binary PBM image", screen.Width, screen.Height)}, actual.Bytes(), nil
+	}
+
+	pixels := 0
+	for i, b := range golden {
+		pixels += bits.OnesCount8(b ^ actual.Bytes()[i])
+	}
+	return []string{fmt.Sprintf("%d pixels differ", pixels)}, actual.Bytes(), nil
+}
@@ -0,0 +1,254 @@
+package golden
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ChelseaDH/VMTranslator/hack"
+	"github.com/ChelseaDH/VMTranslator/program"
+	"github.com/ChelseaDH/VMTranslator/screen"
+	"github.com/ChelseaDH/VMTranslator/vm"
+)
+
+func assemble(t *testing.T, source string) *hack.Program {
+	p, err := hack.Assemble(strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+type runTest struct {
+	input     string
+	expCycles uint64
+	expErrors bool
+}
+
+var runTests = []runTest{
+	{input: "@7\nD=A\n@total\nM=D\n(END)\n@END\n0;JMP", expCycles: 4},
+	// Sys.halt stops the program even though it is not a loop that jumps to itself
+	{input: "@Sys.halt\n0;JMP\n(Sys.halt)\n@SCREEN\nM=-1\n@Sys.halt\n0;JMP", expCycles: 2},
+	{input: "(LOOP)\n@total\nM=M+1\n@LOOP\n0;JMP", expErrors: true},
+}
+
+func TestRun(t *testing.T) {
+	for _, test := range runTests {
+		cpu, err := Run(assemble(t, test.input), 1000)
+		if test.expErrors {
+			if err == nil {
+				t.Errorf("expected an error running %q, but none returned", test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("did not expect an error, but %q returned for %q", err, test.input)
+			continue
+		}
+
+		if cpu.Cycles != test.expCycles {
+			t.Errorf("expected %q to halt after %d instructions, got %d", test.input, test.expCycles, cpu.Cycles)
+		}
+	}
+}
+
+func TestResume(t *testing.T) {
+	// The loop runs 3000 instructions, more than the limit, but the limit only counts those run after resuming
+	p := assemble(t, "@1000\nD=A\n(LOOP)\nD=D-1\n@LOOP\nD;JGT\n(END)\n@END\n0;JMP")
+	cpu := hack.NewCPU(p)
+	err := cpu.Run(2000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Resume(p, cpu, 1500)
+	if err != nil {
+		t.Fatalf("did not expect an error, but %q returned", err)
+	}
+	if cpu.Cycles != 3002 || cpu.D != 0 {
+		t.Errorf("expected to halt after 3002 instructions with D at 0, got %d after %d", cpu.D, cpu.Cycles)
+	}
+}
+
+const asmProgram = "@7\nD=A\n@total\nM=D\n@SCREEN\nM=1\n(END)\n@END\n0;JMP"
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "Prog.asm")
+	p := assemble(t, asmProgram)
+	cpu, err := Run(p, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Check(CPUState(p, cpu), name, false); err == nil {
+		t.Errorf("expected an error checking a program without golden files, but none returned")
+	}
+
+	var image bytes.Buffer
+	err = screen.WritePBM(&image, cpu.RAM[screen.Base:screen.Base+screen.Words])
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		RAMExt: "// The result and the registers around it\ntotal: 7\nR15: 0 7 0\n",
+		// The single pixel is not a character
+		TextExt:  "�\n",
+		ImageExt: image.String(),
+	}
+	for ext, content := range files {
+		err = os.WriteFile(filepath.Join(dir, "Prog"+ext), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mismatches, err := Check(CPUState(p, cpu), name, false)
+	if err != nil {
+		t.Fatalf("did not expect an error, but %q returned", err)
+	}
+	if len(mismatches) != 0 {
+		t.Errorf("expected no mismatches, got %v", mismatches)
+	}
+
+	// Changing the result and the screen breaks every golden file
+	cpu.RAM[16] = 8
+	cpu.RAM[screen.Base+1] = 1
+	mismatches, err = Check(CPUState(p, cpu), name, false)
+	if err != nil {
+		t.Fatalf("did not expect an error, but %q returned", err)
+	}
+
+	expMismatches := []string{
+		filepath.Join(dir, "Prog.golden.ram") + ":\n  RAM[16]: expected 7, got 8\n  RAM[16]: expected 7, got 8",
+		filepath.Join(dir, "Prog.golden.txt") + ":\n  row 0: expected \"�\", got \"� �\"",
+		filepath.Join(dir, "Prog.golden.pbm") + ":\n  1 pixels differ",
+	}
+	if len(mismatches) != len(expMismatches) {
+		t.Fatalf("expected %d mismatches, got %v", len(expMismatches), mismatches)
+	}
+	for i, m := range mismatches {
+		if m.String() != expMismatches[i] {
+			t.Errorf("expected mismatch %q, got %q", expMismatches[i], m)
+		}
+	}
+
+	_, err = Check(CPUState(p, cpu), name, true)
+	if err != nil {
+		t.Fatalf("did not expect an error, but %q returned", err)
+	}
+	ram, err := os.ReadFile(filepath.Join(dir, "Prog"+RAMExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expRAM := "total: 8\nR15: 0 8 0\n"; string(ram) != expRAM {
+		t.Errorf("expected the updated RAM file to be %q, got %q", expRAM, ram)
+	}
+
+	mismatches, err = Check(CPUState(p, cpu), name, false)
+	if err != nil || len(mismatches) != 0 {
+		t.Errorf("expected no mismatches after updating, got %v, %v", mismatches, err)
+	}
+}
+
+func TestReadRAM(t *testing.T) {
+	p := assemble(t, asmProgram)
+	for _, input := range []string{"total 7", "nothing: 7", "total:", "total: 40000", "32767: 1 2"} {
+		if _, err := ReadRAM(strings.NewReader(input), p.Variables); err == nil {
+			t.Errorf("expected an error reading %q, but none returned", input)
+		}
+	}
+}
+
+// osFiles are the Jack OS classes compiled in the benchmark program.
+var osFiles = []string{"Array", "Keyboard", "Math", "Memory", "Output", "Screen", "String", "Sys"}
+
+// printString returns the VM commands that print s with the Jack OS.
+func printString(s string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "push constant %d\ncall String.new 1\n", len(s))
+	for _, c := range s {
+		fmt.Fprintf(&b, "push constant %d\ncall String.appendChar 2\n", c)
+	}
+	b.WriteString("call Output.printString 1\npop temp 0\n")
+	return b.String()
+}
+
+func TestCheck_VM(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "Hello")
+	err := os.Mkdir(dir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, class := range osFiles {
+		data, err := os.ReadFile(filepath.Join("..", "testfiles", "benchmark", class+".vm"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(filepath.Join(dir, class+".vm"), data, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	main := "function Main.main 0\n" + printString("Hello, World! ") +
+		"push constant 123\ncall Output.printInt 1\npop temp 0\ncall Output.println 0\npop temp 0\n" +
+		"push constant 42\nneg\ncall Output.printInt 1\npop temp 0\n" +
+		"push constant 5\npop static 0\npush constant 0\nreturn\n"
+	files := map[string]string{
+		"Main.vm":         main,
+		"Hello" + TextExt: "Hello, World! 123\n-42\n",
+		"Hello" + RAMExt:  "Main.0: 5\n",
+	}
+	for name, content := range files {
+		err = os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lines, err := program.ReadPath(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := vm.New(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ResumeVM(m, 10000000)
+	if err != nil {
+		t.Fatalf("did not expect an error, but %q returned", err)
+	}
+	if m.Function(m.PC) != "Sys.halt" {
+		t.Errorf("expected the program to halt in Sys.halt, got %s", m.Function(m.PC))
+	}
+
+	mismatches, err := Check(VMState(m), dir, false)
+	if err != nil {
+		t.Fatalf("did not expect an error, but %q returned", err)
+	}
+	if len(mismatches) != 0 {
+		t.Errorf("expected no mismatches, got %v", mismatches)
+	}
+
+	// A program that finishes without halting, and one that never halts
+	for source, expErr := range map[string]string{
+		"push constant 1\npop temp 0":                  "the program finished without halting",
+		"label L\npush constant 1\npop temp 0\ngoto L": "the program did not halt within 100 commands",
+	} {
+		lines, err := program.Read("Loop.vm", strings.NewReader(source))
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := vm.New(lines)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ResumeVM(m, 100); err == nil || err.Error() != expErr {
+			t.Errorf("expected %q, got %v for %q", expErr, err, source)
+		}
+	}
+}
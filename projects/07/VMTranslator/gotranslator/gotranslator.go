@@ -0,0 +1,550 @@
+package gotranslator
+
+import (
+	"fmt"
+	"go/format"
+	"io"
+	"log"
+	"strings"
+	"unicode"
+
+	"github.com/ChelseaDH/VMTranslator/command"
+)
+
+const (
+	tempIndex   = 5
+	staticIndex = 16
+)
+
+// Translator converts a stream of VM commands into a self-contained Go package exposing a Machine type.
+// Every VM function becomes a labelled block inside Machine.Run, with calls and returns implemented
+// through an explicit return address dispatch table.
+// The program is buffered until Terminate, as calls may refer to functions defined later.
+type Translator struct {
+	Output    io.Writer
+	Namespace string
+	// Package is the name of the generated package. If it is not set, a name is derived from Program.
+	Package string
+	// Program is the name of the file or directory being translated.
+	Program     string
+	currentFunc string
+	body        []string
+	bootstrap   bool
+	functions   map[string]int
+	defined     map[string]bool
+	funcOrder   []string
+	labels      map[string]int
+	usedLabels  map[string]bool
+	statics     map[string]int
+	returnCount int
+	returns     bool
+}
+
+func (t *Translator) SetNamespace(namespace string) {
+	t.Namespace = namespace
+}
+
+func (t *Translator) Translate(c command.Command) error {
+	// Labels are written ahead of the step count, so that jumping to them counts the command
+	switch c.Type() {
+	case command.Label:
+		t.defineLabel(t.label(c.(*command.BranchingCommand)))
+	case command.Function:
+		t.defineLabel(t.functionLabel(c.(*command.FunctionCommand).Name))
+	}
+	t.write(fmt.Sprintf("// %s\nm.Steps++\n", c.String()))
+
+	switch c.Type() {
+	case command.Add:
+		t.translateBinaryExpression("x + y")
+		return nil
+
+	case command.Sub:
+		t.translateBinaryExpression("x - y")
+		return nil
+
+	case command.Eq:
+		t.translateBinaryExpression("boolean(x == y)")
+		return nil
+
+	case command.Gt:
+		t.translateBinaryExpression("boolean(x > y)")
+		return nil
+
+	case command.Lt:
+		t.translateBinaryExpression("boolean(x < y)")
+		return nil
+
+	case command.And:
+		t.translateBinaryExpression("x & y")
+		return nil
+
+	case command.Or:
+		t.translateBinaryExpression("x | y")
+		return nil
+
+	case command.Neg:
+		t.write("m.push(-m.pop())\n")
+		return nil
+
+	case command.Not:
+		t.write("m.push(^m.pop())\n")
+		return nil
+
+	case command.Pop:
+		mac := c.(*command.MemoryAccessCommand)
+		return t.translatePop(mac)
+
+	case command.Push:
+		mac := c.(*command.MemoryAccessCommand)
+		return t.translatePush(mac)
+
+	case command.Label:
+		return nil
+
+	case command.Goto:
+		bc := c.(*command.BranchingCommand)
+		t.write(fmt.Sprintf("if m.exceeded() {\nreturn ErrStepLimit\n}\ngoto %s\n", t.useLabel(t.label(bc))))
+		return nil
+
+	case command.IfGoto:
+		bc := c.(*command.BranchingCommand)
+		t.write(fmt.Sprintf("if m.pop() != 0 {\nif m.exceeded() {\nreturn ErrStepLimit\n}\ngoto %s\n}\n", t.useLabel(t.label(bc))))
+		return nil
+
+	case command.Function:
+		fc := c.(*command.FunctionCommand)
+		t.currentFunc = fc.Name
+		return t.defineFunction(fc)
+
+	case command.Call:
+		fc := c.(*command.FunctionCommand)
+		t.callFunction(fc)
+		return nil
+
+	case command.Return:
+		t.write("ret = m.frameReturn()\ngoto dispatch\n")
+		t.returns = true
+		return nil
+
+	default:
+		return fmt.Errorf("translation not yet implemented for command of type %s", c.Type())
+	}
+}
+
+func (t *Translator) write(input string) {
+	t.body = append(t.body, input)
+}
+
+// Initialise mirrors the Hack bootstrap code: the stack pointer is set to 256 and Sys.init is called.
+// Run returns should Sys.init ever return.
+func (t *Translator) Initialise() error {
+	t.bootstrap = true
+	err := t.Translate(&command.FunctionCommand{
+		RawCommand: command.RawCommand{Typ: command.Call},
+		Name:       "Sys.init",
+		Args:       0,
+	})
+	if err != nil {
+		return err
+	}
+
+	t.write("return nil\n")
+	return nil
+}
+
+// Terminate writes the complete, formatted Go source file to Output.
+func (t *Translator) Terminate() {
+	var b strings.Builder
+
+	b.WriteString("// Code generated by VMTranslator. DO NOT EDIT.\n\n")
+	b.WriteString(fmt.Sprintf("package %s\n", t.packageName()))
+
+	body, used := t.reachable()
+
+	// fmt is only used to report bad return addresses and calls to undefined functions
+	usesFmt := used["dispatch"]
+	for _, name := range t.funcOrder {
+		usesFmt = usesFmt || (!t.defined[name] && used[t.functionLabel(name)])
+	}
+	if usesFmt {
+		b.WriteString(prelude)
+	} else {
+		b.WriteString(strings.Replace(prelude, "\t\"fmt\"\n", "", 1))
+	}
+
+	b.WriteString("// Run executes the program from the start until it returns or calls Sys.halt.\n")
+	b.WriteString("func (m *Machine) Run() error {\nvar x, y, ret int16\n_, _, _ = x, y, ret\n\nm.RAM[0] = 256\n")
+	live := true
+	for _, s := range body {
+		b.WriteString(s)
+		live = !terminates(s)
+	}
+	if live {
+		b.WriteString("return nil\n")
+	}
+	b.WriteString("\n")
+
+	// Calls to functions that were never defined fail when called rather than at compile time,
+	// so that programs only using a subset of the OS can still be built.
+	for _, name := range t.funcOrder {
+		if label := t.functionLabel(name); !t.defined[name] && used[label] {
+			b.WriteString(fmt.Sprintf("%s:\nreturn fmt.Errorf(\"call to undefined function %%s\", %q)\n", label, name))
+		}
+	}
+
+	if used["dispatch"] {
+		b.WriteString("\ndispatch:\nswitch ret {\n")
+		for i := 0; i < t.returnCount; i++ {
+			b.WriteString(fmt.Sprintf("case %d:\ngoto R%d\n", i, i))
+		}
+		b.WriteString("}\nreturn fmt.Errorf(\"invalid return address %d\", ret)\n")
+	}
+	b.WriteString("}\n")
+
+	source, err := format.Source([]byte(b.String()))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = t.Output.Write(source)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// reachable returns the code of Run that can be reached, along with the labels it jumps to.
+//
+// Go refuses to compile unused labels and go vet rejects code that can never run, which is any code after a goto
+// or return up to the next label that is jumped to, such as a function that is never called. Leaving that code
+// out can leave a label only jumped to from it unused in turn, so the code is pruned until nothing more changes.
+func (t *Translator) reachable() ([]string, map[string]bool) {
+	body := t.body
+	used := make(map[string]bool)
+	for label := range t.usedLabels {
+		used[label] = true
+	}
+	if t.returns {
+		used["dispatch"] = true
+		for i := 0; i < t.returnCount; i++ {
+			used[fmt.Sprintf("R%d", i)] = true
+		}
+	}
+
+	for {
+		var kept []string
+		keptUsed := make(map[string]bool)
+		live := true
+		for _, s := range body {
+			if strings.HasSuffix(s, ":\n") {
+				if !used[strings.TrimSuffix(s, ":\n")] {
+					continue
+				}
+				live = true
+			}
+			if !live {
+				continue
+			}
+
+			kept = append(kept, s)
+			for _, line := range strings.Split(s, "\n") {
+				if label := strings.TrimPrefix(line, "goto "); label != line {
+					keptUsed[label] = true
+				}
+			}
+			live = !terminates(s)
+		}
+
+		// The return addresses are only jumped to from the dispatch table, which is left out if nothing returns
+		if keptUsed["dispatch"] {
+			for i := 0; i < t.returnCount; i++ {
+				keptUsed[fmt.Sprintf("R%d", i)] = true
+			}
+		}
+
+		if len(kept) == len(body) && len(keptUsed) == len(used) {
+			return kept, used
+		}
+		body, used = kept, keptUsed
+	}
+}
+
+// terminates reports whether the code s ends by jumping away or returning, so the code after it only runs if jumped to.
+func terminates(s string) bool {
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	last := lines[len(lines)-1]
+	return strings.HasPrefix(last, "goto ") || strings.HasPrefix(last, "return ")
+}
+
+// packageName returns Package if set, otherwise a valid package name derived from the program name.
+func (t *Translator) packageName() string {
+	if t.Package != "" {
+		return t.Package
+	}
+
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, t.Program)
+
+	if name == "" || !unicode.IsLetter(rune(name[0])) {
+		name = "vm" + name
+	}
+
+	return name
+}
+
+func (t *Translator) translateBinaryExpression(expression string) {
+	t.write(fmt.Sprintf("y = m.pop()\nx = m.pop()\nm.push(%s)\n", expression))
+}
+
+func (t *Translator) translatePop(c *command.MemoryAccessCommand) error {
+	switch c.Segment {
+	case command.Local, command.Argument, command.This, command.That:
+		t.write(fmt.Sprintf("m.write(m.RAM[%d]+%d, m.pop())\n", segmentAddress(c.Segment), c.Index))
+		return nil
+
+	case command.Constant:
+		return fmt.Errorf("%s is not a valid segment type for pop", c.Segment.String())
+
+	default:
+		t.write(fmt.Sprintf("m.RAM[%d] = m.pop()\n", t.address(c)))
+		return nil
+	}
+}
+
+func (t *Translator) translatePush(c *command.MemoryAccessCommand) error {
+	switch c.Segment {
+	case command.Local, command.Argument, command.This, command.That:
+		t.write(fmt.Sprintf("m.push(m.read(m.RAM[%d] + %d))\n", segmentAddress(c.Segment), c.Index))
+
+	case command.Constant:
+		t.write(fmt.Sprintf("m.push(%d)\n", c.Index))
+
+	default:
+		t.write(fmt.Sprintf("m.push(m.RAM[%d])\n", t.address(c)))
+	}
+
+	return nil
+}
+
+// address returns the fixed RAM address of a static, temp or pointer segment cell.
+func (t *Translator) address(c *command.MemoryAccessCommand) int {
+	switch c.Segment {
+	case command.Static:
+		return t.static(c.Index)
+	case command.Temp:
+		return tempIndex + c.Index
+	default:
+		return segmentAddress(command.This) + c.Index
+	}
+}
+
+func segmentAddress(s command.Segment) int {
+	switch s {
+	case command.Local:
+		return 1
+	case command.Argument:
+		return 2
+	case command.This:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// static allocates static variables from RAM[16] in order of first use, as the Hack assembler does.
+func (t *Translator) static(index int) int {
+	if t.statics == nil {
+		t.statics = make(map[string]int)
+	}
+
+	name := fmt.Sprintf("%s.%d", t.Namespace, index)
+	address, ok := t.statics[name]
+	if !ok {
+		address = staticIndex + len(t.statics)
+		t.statics[name] = address
+	}
+
+	return address
+}
+
+func (t *Translator) label(bc *command.BranchingCommand) string {
+	if t.labels == nil {
+		t.labels = make(map[string]int)
+	}
+
+	name := fmt.Sprintf("%s$%s", t.currentFunc, strings.ToUpper(bc.Label))
+	id, ok := t.labels[name]
+	if !ok {
+		id = len(t.labels)
+		t.labels[name] = id
+	}
+
+	return fmt.Sprintf("L%d", id)
+}
+
+// Go refuses to compile unused labels, so only labels that are jumped to are written by Terminate.
+func (t *Translator) defineLabel(label string) {
+	t.write(fmt.Sprintf("%s:\n", label))
+}
+
+func (t *Translator) useLabel(label string) string {
+	if t.usedLabels == nil {
+		t.usedLabels = make(map[string]bool)
+	}
+
+	t.usedLabels[label] = true
+	return label
+}
+
+func (t *Translator) functionLabel(name string) string {
+	if t.functions == nil {
+		t.functions = make(map[string]int)
+	}
+
+	id, ok := t.functions[name]
+	if !ok {
+		id = len(t.functions)
+		t.functions[name] = id
+		t.funcOrder = append(t.funcOrder, name)
+	}
+
+	return fmt.Sprintf("F%d", id)
+}
+
+func (t *Translator) defineFunction(fc *command.FunctionCommand) error {
+	if t.defined == nil {
+		t.defined = make(map[string]bool)
+	}
+
+	if t.defined[fc.Name] {
+		return fmt.Errorf("function %s defined more than once", fc.Name)
+	}
+	t.defined[fc.Name] = true
+
+	for i := 0; i < fc.Args; i++ {
+		t.write("m.push(0)\n")
+	}
+	return nil
+}
+
+func (t *Translator) callFunction(fc *command.FunctionCommand) {
+	// Sys.halt never returns, so stop the machine rather than spinning forever
+	if fc.Name == "Sys.halt" {
+		t.write("return nil\n")
+		return
+	}
+
+	t.write("if m.exceeded() {\nreturn ErrStepLimit\n}\n")
+	t.write(fmt.Sprintf("m.frameCall(%d, %d)\ngoto %s\n", t.returnCount, fc.Args, t.useLabel(t.functionLabel(fc.Name))))
+	t.defineLabel(fmt.Sprintf("R%d", t.returnCount))
+	t.returnCount++
+}
+
+const prelude = `
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// Screen is the base address of the memory map of the 512x256 monochrome screen.
+	Screen = 16384
+	// ScreenSize is the number of words in the screen memory map.
+	ScreenSize = 8192
+	// Keyboard is the address of the memory map of the keyboard.
+	Keyboard = 24576
+)
+
+// ErrStepLimit is returned by Run when the machine executes more than Limit VM commands.
+var ErrStepLimit = errors.New("step limit exceeded")
+
+// Machine holds the state of the Hack RAM while running the translated program.
+type Machine struct {
+	RAM [32768]int16
+
+	// Steps counts the VM commands executed so far.
+	Steps uint64
+	// Limit, if non-zero, stops Run with ErrStepLimit once Steps exceeds it.
+	Limit uint64
+
+	// Tick, if set, is called once at least Interval VM commands have run since it was last called,
+	// e.g. to take a snapshot of the screen. It is only checked at jumps and calls, so may run a few commands late.
+	Tick     func()
+	Interval uint64
+	nextTick uint64
+
+	// KeyboardInput, if set, is called to read the keyboard register instead of RAM[Keyboard].
+	KeyboardInput func() int16
+	// ScreenOutput, if set, is called after every write to the screen memory map.
+	ScreenOutput func(address int, value int16)
+}
+
+func (m *Machine) push(value int16) {
+	m.RAM[uint16(m.RAM[0])&0x7fff] = value
+	m.RAM[0]++
+}
+
+func (m *Machine) pop() int16 {
+	m.RAM[0]--
+	return m.RAM[uint16(m.RAM[0])&0x7fff]
+}
+
+func (m *Machine) read(address int16) int16 {
+	a := int(uint16(address) & 0x7fff)
+	if a == Keyboard && m.KeyboardInput != nil {
+		return m.KeyboardInput()
+	}
+	return m.RAM[a]
+}
+
+func (m *Machine) write(address int16, value int16) {
+	a := int(uint16(address) & 0x7fff)
+	m.RAM[a] = value
+	if a >= Screen && a < Screen+ScreenSize && m.ScreenOutput != nil {
+		m.ScreenOutput(a, value)
+	}
+}
+
+func (m *Machine) exceeded() bool {
+	if m.Tick != nil && m.Steps >= m.nextTick {
+		m.nextTick = m.Steps + m.Interval
+		m.Tick()
+	}
+	return m.Limit != 0 && m.Steps > m.Limit
+}
+
+func (m *Machine) frameCall(ret int16, args int16) {
+	m.push(ret)
+	m.push(m.RAM[1])
+	m.push(m.RAM[2])
+	m.push(m.RAM[3])
+	m.push(m.RAM[4])
+	m.RAM[2] = m.RAM[0] - 5 - args
+	m.RAM[1] = m.RAM[0]
+}
+
+func (m *Machine) frameReturn() int16 {
+	frame := m.RAM[1]
+	ret := m.read(frame - 5)
+
+	m.write(m.RAM[2], m.pop())
+	m.RAM[0] = m.RAM[2] + 1
+	m.RAM[4] = m.read(frame - 1)
+	m.RAM[3] = m.read(frame - 2)
+	m.RAM[2] = m.read(frame - 3)
+	m.RAM[1] = m.read(frame - 4)
+	return ret
+}
+
+func boolean(b bool) int16 {
+	if b {
+		return -1
+	}
+	return 0
+}
+
+`
@@ -0,0 +1,100 @@
+package gotranslator
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ChelseaDH/VMTranslator/parser"
+)
+
+type translateTest struct {
+	input     []string
+	expOutput []string
+	// expAbsent are parts of the output that must not be written
+	expAbsent []string
+	expectErr bool
+}
+
+var translateTests = []translateTest{
+	{
+		input:     []string{"push constant 7", "push static 3", "add", "pop temp 2"},
+		expOutput: []string{"package test\n", "m.push(7)", "m.push(m.RAM[16])", "x = m.pop()\n\tm.push(x + y)", "m.RAM[7] = m.pop()"},
+	},
+	{
+		input:     []string{"function Test.f 2", "label loop", "label unused", "push local 1", "if-goto loop", "call Test.g 1", "return"},
+		expOutput: []string{"m.push(0)\n\tm.push(0)", "L0:\n", "goto L0", "m.frameCall(0, 1)\n\tgoto F1\nR0:", "F1:\n\treturn fmt.Errorf(\"call to undefined function %s\", \"Test.g\")"},
+	},
+	{
+		// Without a return there is no dispatch table, so it, the return addresses and fmt are left out, as Go
+		// refuses to compile unused labels and imports
+		input:     []string{"function Test.f 0", "call Test.g 0", "label END", "goto END", "function Test.g 0", "push constant 1"},
+		expOutput: []string{"m.frameCall(0, 0)\n\tgoto F1\n"},
+		expAbsent: []string{"dispatch", "R0:", `"fmt"`},
+	},
+	{
+		// The top of the stack is read through pop and push, which keep a bad stack pointer inside the RAM
+		input:     []string{"push constant 1", "neg", "not"},
+		expOutput: []string{"m.push(-m.pop())", "m.push(^m.pop())"},
+		expAbsent: []string{"m.RAM[m.RAM[0]-1]"},
+	},
+	{
+		// go vet rejects code that can never run, such as after a goto or a function that is never called
+		input:     []string{"function Test.f 0", "goto END", "push constant 1", "label END", "goto END", "function Test.g 0", "push constant 2"},
+		expOutput: []string{"L0:\n"},
+		expAbsent: []string{"m.push(1)", "m.push(2)", "F1:"},
+	},
+	{
+		input:     []string{"function Test.f 0", "function Test.f 0"},
+		expectErr: true,
+	},
+}
+
+func TestTranslator_Translate(t *testing.T) {
+	for _, test := range translateTests {
+		var output bytes.Buffer
+		translator := Translator{Output: &output, Namespace: "Test", Program: "Test"}
+
+		var err error
+		for _, line := range test.input {
+			c, parseErr := parser.Parse(line)
+			if parseErr != nil {
+				t.Fatalf("unexpected parse error %q for %s", parseErr, line)
+			}
+
+			err = translator.Translate(c)
+			if err != nil {
+				break
+			}
+		}
+
+		if err == nil && test.expectErr {
+			t.Errorf("expected an error but none returned for %v", test.input)
+		}
+
+		if err != nil && !test.expectErr {
+			t.Errorf("did not expect an error, but %q returned for %v", err, test.input)
+		}
+
+		if err != nil {
+			continue
+		}
+
+		translator.Terminate()
+		for _, exp := range test.expOutput {
+			if !strings.Contains(output.String(), exp) {
+				t.Errorf("expected output to contain %q for %v", exp, test.input)
+			}
+		}
+
+		for _, absent := range test.expAbsent {
+			if strings.Contains(output.String(), absent) {
+				t.Errorf("expected output not to contain %q for %v", absent, test.input)
+			}
+		}
+
+		if strings.Contains(output.String(), "L1:") {
+			t.Errorf("expected unused label to be omitted for %v", test.input)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+// Package hack assembles and runs programs for the Hack computer.
+//
+// Assemble takes plain Hack assembly only. Programs using the includes, constants, macros and pseudo-instructions
+// of projects/06/HackAssembler are assembled with it instead, run with -s, and the .hack file loaded with Load
+// along with the .sym file written next to it, which ReadSymbols adds.
+package hack
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// VariableBase is the first RAM address allocated to variables.
+	VariableBase = 16
+	// ROMSize and RAMSize are the number of words of each memory.
+	ROMSize = 32768
+	RAMSize = 32768
+)
+
+// preprocessed are the directives and pseudo-instructions that HackAssembler expands into plain assembly.
+var preprocessed = map[string]bool{
+	".include": true, ".equ": true, ".macro": true, ".endm": true, "LDI": true, "HALT": true,
+	"JMP": true, "JGT": true, "JEQ": true, "JGE": true, "JLT": true, "JNE": true, "JLE": true,
+}
+
+// PredefinedSymbols are the symbols every Hack assembly program can use without declaring them.
+var PredefinedSymbols = map[string]uint16{
+	"SP": 0, "LCL": 1, "ARG": 2, "THIS": 3, "THAT": 4,
+	"R0": 0, "R1": 1, "R2": 2, "R3": 3, "R4": 4, "R5": 5, "R6": 6, "R7": 7,
+	"R8": 8, "R9": 9, "R10": 10, "R11": 11, "R12": 12, "R13": 13, "R14": 14, "R15": 15,
+	"SCREEN": 16384, "KBD": 24576,
+}
+
+// compCodes gives the a-bit and c-bits of each computation, including the operand orders other assemblers accept.
+var compCodes = map[string]uint16{
+	"0": 0b0101010, "1": 0b0111111, "-1": 0b0111010,
+	"D": 0b0001100, "A": 0b0110000, "M": 0b1110000,
+	"!D": 0b0001101, "!A": 0b0110001, "!M": 0b1110001,
+	"-D": 0b0001111, "-A": 0b0110011, "-M": 0b1110011,
+	"D+1": 0b0011111, "A+1": 0b0110111, "M+1": 0b1110111,
+	"1+D": 0b0011111, "1+A": 0b0110111, "1+M": 0b1110111,
+	"D-1": 0b0001110, "A-1": 0b0110010, "M-1": 0b1110010,
+	"D+A": 0b0000010, "D+M": 0b1000010, "A+D": 0b0000010, "M+D": 0b1000010,
+	"D-A": 0b0010011, "D-M": 0b1010011, "A-D": 0b0000111, "M-D": 0b1000111,
+	"D&A": 0b0000000, "D&M": 0b1000000, "A&D": 0b0000000, "M&D": 0b1000000,
+	"D|A": 0b0010101, "D|M": 0b1010101, "A|D": 0b0010101, "M|D": 0b1010101,
+}
+
+// compMnemonics gives the canonical mnemonic of each computation, indexed by its a-bit and c-bits.
+var compMnemonics = map[uint16]string{}
+
+var jumpCodes = map[string]uint16{"": 0, "JGT": 1, "JEQ": 2, "JGE": 3, "JLT": 4, "JNE": 5, "JLE": 6, "JMP": 7}
+
+var jumpMnemonics = [8]string{"", "JGT", "JEQ", "JGE", "JLT", "JNE", "JLE", "JMP"}
+
+func init() {
+	for _, mnemonic := range []string{
+		"0", "1", "-1", "D", "A", "M", "!D", "!A", "!M", "-D", "-A", "-M", "D+1", "A+1", "M+1", "D-1", "A-1", "M-1",
+		"D+A", "D+M", "D-A", "D-M", "A-D", "M-D", "D&A", "D&M", "D|A", "D|M",
+	} {
+		compMnemonics[compCodes[mnemonic]] = mnemonic
+	}
+}
+
+// Line is a line of the assembly source that produced an instruction.
+type Line struct {
+	Number int
+	Text   string
+}
+
+// Program is an assembled Hack program along with the information needed to relate it back to its source.
+type Program struct {
+	ROM []uint16
+	// Source holds the source line of each instruction in ROM.
+	Source []Line
+	// Labels and Variables map the symbols declared by the program to their ROM and RAM addresses.
+	Labels    map[string]uint16
+	Variables map[string]uint16
+	// VariableOrder lists the variables in the order they were allocated.
+	VariableOrder []string
+}
+
+// Assemble translates Hack assembly into machine code.
+func Assemble(r io.Reader) (*Program, error) {
+	type instruction struct {
+		text string
+		line Line
+	}
+
+	p := &Program{Labels: make(map[string]uint16), Variables: make(map[string]uint16)}
+	var instructions []instruction
+
+	scanner := bufio.NewScanner(r)
+	number := 0
+	for scanner.Scan() {
+		number++
+		text := scanner.Text()
+		if i := strings.Index(text, "//"); i != -1 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if preprocessed[fields[0]] {
+			return nil, fmt.Errorf("line %d: %s needs HackAssembler, assemble the file with it and load the .hack file", number, fields[0])
+		}
+		text = strings.Join(fields, "")
+
+		if strings.HasPrefix(text, "(") {
+			if !strings.HasSuffix(text, ")") || len(text) < 3 {
+				return nil, fmt.Errorf("line %d: invalid label declaration %s", number, text)
+			}
+
+			label := text[1 : len(text)-1]
+			if _, ok := p.Labels[label]; ok {
+				return nil, fmt.Errorf("line %d: label %s already declared", number, label)
+			}
+			p.Labels[label] = uint16(len(instructions))
+			continue
+		}
+
+		instructions = append(instructions, instruction{text: text, line: Line{Number: number, Text: strings.TrimSpace(scanner.Text())}})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(instructions) > ROMSize {
+		return nil, fmt.Errorf("program is %d instructions long, but the ROM only holds %d", len(instructions), ROMSize)
+	}
+
+	for _, in := range instructions {
+		var word uint16
+		var err error
+		if strings.HasPrefix(in.text, "@") {
+			word, err = p.aInstruction(in.text[1:])
+		} else {
+			word, err = cInstruction(in.text)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", in.line.Number, err)
+		}
+
+		p.ROM = append(p.ROM, word)
+		p.Source = append(p.Source, in.line)
+	}
+
+	return p, nil
+}
+
+func (p *Program) aInstruction(symbol string) (uint16, error) {
+	if symbol == "" {
+		return 0, fmt.Errorf("missing A-instruction value")
+	}
+
+	if symbol[0] >= '0' && symbol[0] <= '9' {
+		value, err := strconv.ParseUint(symbol, 10, 15)
+		if err != nil {
+			return 0, fmt.Errorf("invalid A-instruction value %s", symbol)
+		}
+		return uint16(value), nil
+	}
+
+	if address, ok := PredefinedSymbols[symbol]; ok {
+		return address, nil
+	}
+
+	if address, ok := p.Labels[symbol]; ok {
+		return address, nil
+	}
+
+	address, ok := p.Variables[symbol]
+	if !ok {
+		address = uint16(VariableBase + len(p.Variables))
+		p.Variables[symbol] = address
+		p.VariableOrder = append(p.VariableOrder, symbol)
+	}
+
+	return address, nil
+}
+
+func cInstruction(text string) (uint16, error) {
+	dest, jump := "", ""
+	if i := strings.Index(text, "="); i != -1 {
+		dest, text = text[:i], text[i+1:]
+	}
+	if i := strings.Index(text, ";"); i != -1 {
+		text, jump = text[:i], text[i+1:]
+	}
+
+	comp, ok := compCodes[text]
+	if !ok {
+		return 0, fmt.Errorf("invalid computation %s", text)
+	}
+
+	jumpCode, ok := jumpCodes[jump]
+	if !ok {
+		return 0, fmt.Errorf("invalid jump %s", jump)
+	}
+
+	destCode := uint16(0)
+	for _, d := range dest {
+		bit := strings.IndexRune("MDA", d)
+		if bit == -1 || destCode&(1<<bit) != 0 {
+			return 0, fmt.Errorf("invalid destination %s", dest)
+		}
+		destCode |= 1 << bit
+	}
+
+	return 0b111<<13 | comp<<6 | destCode<<3 | jumpCode, nil
+}
+
+// Load reads a .hack file of 16 character binary words, one per line.
+func Load(r io.Reader) (*Program, error) {
+	p := &Program{Labels: make(map[string]uint16), Variables: make(map[string]uint16)}
+
+	scanner := bufio.NewScanner(r)
+	number := 0
+	for scanner.Scan() {
+		number++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		word, err := strconv.ParseUint(text, 2, 16)
+		if err != nil || len(text) != 16 {
+			return nil, fmt.Errorf("line %d: expected a 16 bit binary word, got %q", number, text)
+		}
+
+		p.ROM = append(p.ROM, uint16(word))
+		p.Source = append(p.Source, Line{Number: number, Text: text})
+	}
+
+	return p, scanner.Err()
+}
@@ -0,0 +1,179 @@
+package hack
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Keyboard is the RAM address of the keyboard register.
+const Keyboard = 24576
+
+// ErrHalted is returned by Run when the program reaches a loop that jumps to itself, such as (END) @END 0;JMP,
+// or calls Sys.halt.
+var ErrHalted = errors.New("program halted")
+
+// HaltFunction is the Jack OS function that programs call to stop, which loops forever rather than jumping to itself.
+const HaltFunction = "Sys.halt"
+
+// CPU runs a Hack program, one instruction per Step.
+type CPU struct {
+	ROM []uint16
+	RAM [RAMSize]int16
+	A   int16
+	D   int16
+	PC  uint16
+	// Cycles counts the instructions executed so far.
+	Cycles uint64
+
+	// KeyboardInput, if set, is called to read the keyboard register instead of RAM[Keyboard].
+	KeyboardInput func() int16
+
+	// Halt is the ROM address of Sys.halt if HasHalt is set, as NewCPU finds it from the program's labels.
+	Halt    uint16
+	HasHalt bool
+}
+
+// Change records the state an instruction overwrote, so that it can be undone.
+type Change struct {
+	PC   uint16
+	A, D int16
+	// Wrote is set if the instruction wrote Old at RAM[Address].
+	Wrote   bool
+	Address uint16
+	Old     int16
+}
+
+// NewCPU returns a CPU with p loaded into its ROM.
+func NewCPU(p *Program) *CPU {
+	halt, hasHalt := p.Labels[HaltFunction]
+	return &CPU{ROM: p.ROM, Halt: halt, HasHalt: hasHalt}
+}
+
+func (c *CPU) read(address uint16) int16 {
+	if address == Keyboard && c.KeyboardInput != nil {
+		return c.KeyboardInput()
+	}
+	return c.RAM[address]
+}
+
+// Step executes the instruction at PC.
+func (c *CPU) Step() (Change, error) {
+	change := Change{PC: c.PC, A: c.A, D: c.D}
+	if int(c.PC) >= len(c.ROM) {
+		return change, fmt.Errorf("PC %d is past the end of the program", c.PC)
+	}
+
+	word := c.ROM[c.PC]
+	c.Cycles++
+
+	if word&0x8000 == 0 {
+		c.A = int16(word)
+		c.PC++
+		return change, nil
+	}
+
+	address := uint16(c.A) & 0x7fff
+	y := c.A
+	if word&0x1000 != 0 {
+		y = c.read(address)
+	}
+	out := alu(c.D, y, word>>6&0x3f)
+
+	if word&0x08 != 0 {
+		change.Wrote, change.Address, change.Old = true, address, c.RAM[address]
+		c.RAM[address] = out
+	}
+
+	target := uint16(c.A)
+	if word&0x20 != 0 {
+		c.A = out
+	}
+	if word&0x10 != 0 {
+		c.D = out
+	}
+
+	if jumps(out, word&0x7) {
+		c.PC = target & 0x7fff
+	} else {
+		c.PC++
+	}
+
+	return change, nil
+}
+
+// Undo restores the state from before the instruction that made change.
+func (c *CPU) Undo(change Change) {
+	c.PC, c.A, c.D = change.PC, change.A, change.D
+	if change.Wrote {
+		c.RAM[change.Address] = change.Old
+	}
+	c.Cycles--
+}
+
+// Halted reports whether the program is stuck in a loop that jumps to itself, which is how Hack programs end,
+// or has reached Sys.halt, which is how Jack programs end.
+func (c *CPU) Halted() bool {
+	if c.HasHalt && c.PC == c.Halt {
+		return true
+	}
+	if int(c.PC) >= len(c.ROM) {
+		return false
+	}
+
+	word := c.ROM[c.PC]
+	// 0;JMP with A already pointing at it
+	if word == 0b1110101010000111 && uint16(c.A) == c.PC {
+		return true
+	}
+
+	// @PC followed by 0;JMP
+	return word == c.PC && int(c.PC)+1 < len(c.ROM) && c.ROM[c.PC+1] == 0b1110101010000111
+}
+
+// Run steps until the program halts, returning ErrHalted, or until limit instructions have run if limit is not zero.
+func (c *CPU) Run(limit uint64) error {
+	for limit == 0 || c.Cycles < limit {
+		if c.Halted() {
+			return ErrHalted
+		}
+
+		_, err := c.Step()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// alu computes the output of the Hack ALU for the control bits zx, nx, zy, ny, f and no.
+func alu(x int16, y int16, control uint16) int16 {
+	if control&0x20 != 0 {
+		x = 0
+	}
+	if control&0x10 != 0 {
+		x = ^x
+	}
+	if control&0x08 != 0 {
+		y = 0
+	}
+	if control&0x04 != 0 {
+		y = ^y
+	}
+
+	var out int16
+	if control&0x02 != 0 {
+		out = x + y
+	} else {
+		out = x & y
+	}
+
+	if control&0x01 != 0 {
+		out = ^out
+	}
+	return out
+}
+
+func jumps(out int16, jump uint16) bool {
+	return (jump&0x4 != 0 && out < 0) || (jump&0x2 != 0 && out == 0) || (jump&0x1 != 0 && out > 0)
+}
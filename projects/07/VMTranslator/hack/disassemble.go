@@ -0,0 +1,205 @@
+package hack
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// destMnemonics gives the destination of each combination of the A, D and M bits, in the order the course uses.
+var destMnemonics = [8]string{"", "M", "D", "MD", "A", "AM", "AD", "AMD"}
+
+// registerNames names the predefined RAM addresses. R0 to R4 are left as registers rather than SP to THAT,
+// as only VM translators use them as segment pointers.
+var registerNames = map[uint16]string{
+	0: "R0", 1: "R1", 2: "R2", 3: "R3", 4: "R4", 5: "R5", 6: "R6", 7: "R7",
+	8: "R8", 9: "R9", 10: "R10", 11: "R11", 12: "R12", 13: "R13", 14: "R14", 15: "R15",
+	16384: "SCREEN", 24576: "KBD",
+}
+
+// Decode returns the assembly for a single instruction, or false if word is not a valid C-instruction.
+// Words with bits 13 and 14 clear are accepted by the CPU but never written by an assembler, so are invalid too.
+func Decode(word uint16) (string, bool) {
+	if word&0x8000 == 0 {
+		return "@" + strconv.Itoa(int(word)), true
+	}
+
+	comp, ok := compMnemonics[word>>6&0x7f]
+	if !ok || word&0x6000 != 0x6000 {
+		return "", false
+	}
+
+	text := comp
+	if dest := destMnemonics[word>>3&0x7]; dest != "" {
+		text = dest + "=" + text
+	}
+	if jump := jumpMnemonics[word&0x7]; jump != "" {
+		text += ";" + jump
+	}
+
+	return text, true
+}
+
+// usesM reports whether word is a C-instruction that reads or writes RAM[A].
+func usesM(word uint16) bool {
+	return word&0x8000 != 0 && (word&0x1000 != 0 || word&0x8 != 0)
+}
+
+// isJump reports whether word is a C-instruction that can jump to A.
+func isJump(word uint16) bool {
+	return word&0x8000 != 0 && word&0x7 != 0
+}
+
+// Disassembly is a program decoded back into assembly.
+type Disassembly struct {
+	// Instructions holds the assembly of each word of ROM, empty for words that are not valid instructions.
+	Instructions []string
+	ROM          []uint16
+	// Labels names the addresses jumped to, with the program's own labels where it has them.
+	Labels map[uint16]string
+	// Invalid lists the addresses of words that are not valid instructions.
+	Invalid []uint16
+}
+
+// Disassemble decodes the ROM of p. Any labels and variables p has, such as from a symbol file,
+// are used to name addresses; other jump targets within the program are given labels named after their address.
+//
+// An A-instruction is taken to be a jump target if the instruction after it jumps, and a RAM address
+// if the instruction after it reads or writes M. Only then are names given to its value, so that
+// constants loaded into D are left as numbers.
+func Disassemble(p *Program) *Disassembly {
+	d := &Disassembly{
+		Instructions: make([]string, len(p.ROM)),
+		ROM:          p.ROM,
+		Labels:       make(map[uint16]string),
+	}
+
+	labels := make(map[uint16]string)
+	for name, address := range p.Labels {
+		if existing, ok := labels[address]; !ok || name < existing {
+			labels[address] = name
+		}
+	}
+	variables := make(map[uint16]string)
+	for address, name := range registerNames {
+		variables[address] = name
+	}
+	for name, address := range p.Variables {
+		variables[address] = name
+	}
+
+	for i, word := range p.ROM {
+		text, ok := Decode(word)
+		if !ok {
+			d.Invalid = append(d.Invalid, uint16(i))
+			continue
+		}
+		d.Instructions[i] = text
+
+		if word&0x8000 != 0 || i+1 >= len(p.ROM) {
+			continue
+		}
+
+		next := p.ROM[i+1]
+		switch {
+		case isJump(next):
+			// A label can only be written for a target inside the program, or just past its end.
+			// Others are left as numbers, as an unknown name would reassemble as a variable.
+			name, ok := labels[word]
+			if !ok && int(word) <= len(p.ROM) {
+				name, ok = "L"+strconv.Itoa(int(word)), true
+			}
+			if ok {
+				d.Labels[word] = name
+				d.Instructions[i] = "@" + name
+			}
+
+		case usesM(next):
+			if name, ok := variables[word]; ok {
+				d.Instructions[i] = "@" + name
+			}
+		}
+	}
+
+	// Labels from the symbols are kept even when nothing jumps to them, such as return addresses
+	for address, name := range labels {
+		if _, ok := d.Labels[address]; !ok && int(address) <= len(p.ROM) {
+			d.Labels[address] = name
+		}
+	}
+
+	return d
+}
+
+// Write prints the disassembly, with the labels before the instructions they name.
+// If annotate is set each instruction is followed by a comment giving its address and binary word.
+// Invalid words are always written as comments, so the output will not reassemble to the same addresses.
+func (d *Disassembly) Write(w io.Writer, annotate bool) error {
+	var b strings.Builder
+
+	for i, text := range d.Instructions {
+		if label, ok := d.Labels[uint16(i)]; ok {
+			b.WriteString("(" + label + ")\n")
+		}
+
+		if text == "" {
+			b.WriteString(fmt.Sprintf("// %d: %016b is not a valid instruction\n", i, d.ROM[i]))
+			continue
+		}
+
+		if annotate {
+			b.WriteString(fmt.Sprintf("%-24s // %5d: %016b\n", text, i, d.ROM[i]))
+		} else {
+			b.WriteString(text + "\n")
+		}
+	}
+
+	// A label can name the address just past the end of the program
+	if label, ok := d.Labels[uint16(len(d.Instructions))]; ok {
+		b.WriteString("(" + label + ")\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ReadSymbols adds the labels and variables listed in a symbol file to p. Each line of the file is
+// either "label NAME ADDRESS" or "variable NAME ADDRESS", and lines starting // are comments.
+func (p *Program) ReadSymbols(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	for number, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return fmt.Errorf("line %d: expected a kind, name and address, got %q", number+1, line)
+		}
+
+		address, err := strconv.ParseUint(fields[2], 10, 15)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid address %s", number+1, fields[2])
+		}
+
+		switch fields[0] {
+		case "label":
+			p.Labels[fields[1]] = uint16(address)
+		case "variable":
+			if _, ok := p.Variables[fields[1]]; !ok {
+				p.VariableOrder = append(p.VariableOrder, fields[1])
+			}
+			p.Variables[fields[1]] = uint16(address)
+		default:
+			return fmt.Errorf("line %d: unknown symbol kind %s", number+1, fields[0])
+		}
+	}
+
+	return nil
+}
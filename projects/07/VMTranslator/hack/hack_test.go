@@ -0,0 +1,285 @@
+package hack
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var courseFiles = []string{"add/Add", "max/Max", "max/MaxL", "rect/Rect", "rect/RectL", "pong/Pong", "pong/PongL"}
+
+func TestAssemble_CourseFiles(t *testing.T) {
+	for _, name := range courseFiles {
+		source, err := os.Open("../../../06/" + name + ".asm")
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := Assemble(source)
+		source.Close()
+		if err != nil {
+			t.Fatalf("did not expect an error, but %q returned for %s", err, name)
+		}
+
+		// The L files are the label-free versions of the same programs
+		expected, err := os.Open("../../../06/" + strings.TrimSuffix(name, "L") + ".hack")
+		if err != nil {
+			t.Fatal(err)
+		}
+		binary, err := Load(expected)
+		expected.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(p.ROM) != len(binary.ROM) {
+			t.Fatalf("expected %d instructions but got %d for %s", len(binary.ROM), len(p.ROM), name)
+		}
+		for i := range p.ROM {
+			if p.ROM[i] != binary.ROM[i] {
+				t.Errorf("expected %016b but got %016b at %d for %s (%s)", binary.ROM[i], p.ROM[i], i, name, p.Source[i].Text)
+				break
+			}
+		}
+	}
+}
+
+type assembleTest struct {
+	input     string
+	expectErr bool
+}
+
+var assembleTests = []assembleTest{
+	{input: "@x\nM=-M\nAMD=A+D;JMP\n(LOOP)\n@LOOP\n0;JMP"},
+	{input: "D=D*A", expectErr: true},
+	{input: "D=A;JUMP", expectErr: true},
+	{input: "X=A", expectErr: true},
+	{input: "MM=A", expectErr: true},
+	{input: "@32768", expectErr: true},
+	{input: "(A)\n(A)", expectErr: true},
+}
+
+func TestAssemble(t *testing.T) {
+	for _, test := range assembleTests {
+		_, err := Assemble(strings.NewReader(test.input))
+
+		if err == nil && test.expectErr {
+			t.Errorf("expected an error but none returned for %q", test.input)
+		}
+
+		if err != nil && !test.expectErr {
+			t.Errorf("did not expect an error, but %q returned for %q", err, test.input)
+		}
+	}
+}
+
+func TestAssemble_Preprocessed(t *testing.T) {
+	for _, input := range []string{`.include "lib.asm"`, ".equ LIMIT 10", ".macro INC x", "LDI D, 5", "JMP LOOP", "HALT"} {
+		_, err := Assemble(strings.NewReader("// comment\n" + input))
+		if err == nil || !strings.Contains(err.Error(), "line 2:") || !strings.Contains(err.Error(), "HackAssembler") {
+			t.Errorf("expected an error at line 2 pointing to HackAssembler for %s, got %v", input, err)
+		}
+	}
+}
+
+func TestCPU_Run(t *testing.T) {
+	source, err := os.Open("../../../06/max/Max.asm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	p, err := Assemble(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, values := range [][3]int16{{3, 5, 5}, {7, -2, 7}, {-4, -4, -4}} {
+		cpu := NewCPU(p)
+		cpu.RAM[0], cpu.RAM[1] = values[0], values[1]
+
+		err = cpu.Run(1000)
+		if err != ErrHalted {
+			t.Fatalf("expected the program to halt, got %v", err)
+		}
+
+		if cpu.RAM[2] != values[2] {
+			t.Errorf("expected max(%d, %d) to be %d but got %d", values[0], values[1], values[2], cpu.RAM[2])
+		}
+	}
+}
+
+func TestCPU_RunSysHalt(t *testing.T) {
+	// Sys.halt loops through a comparison, as the compiled while (true) of the Jack OS does, rather than jumping to itself
+	p, err := Assemble(strings.NewReader("@Sys.halt\n0;JMP\n(Sys.halt)\n(Sys.halt$WHILE)\nD=-1\n@Sys.halt$WHILE\nD;JNE\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cpu := NewCPU(p)
+	err = cpu.Run(1000)
+	if err != ErrHalted || cpu.PC != 2 {
+		t.Errorf("expected the program to halt at Sys.halt, got %v at PC %d", err, cpu.PC)
+	}
+}
+
+func TestCPU_Undo(t *testing.T) {
+	p, err := Assemble(strings.NewReader("@5\nD=A\n@20\nM=D+1\nAM=M+1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cpu := NewCPU(p)
+	var changes []Change
+	for i := 0; i < 5; i++ {
+		change, err := cpu.Step()
+		if err != nil {
+			t.Fatal(err)
+		}
+		changes = append(changes, change)
+	}
+
+	// AM=M+1 writes to RAM[20], the address in A before the instruction changed it
+	if cpu.A != 7 || cpu.D != 5 || cpu.RAM[20] != 7 || cpu.RAM[7] != 0 {
+		t.Fatalf("unexpected state A=%d D=%d RAM[20]=%d RAM[7]=%d", cpu.A, cpu.D, cpu.RAM[20], cpu.RAM[7])
+	}
+
+	for i := len(changes) - 1; i >= 0; i-- {
+		cpu.Undo(changes[i])
+	}
+
+	if cpu.A != 0 || cpu.D != 0 || cpu.PC != 0 || cpu.RAM[20] != 0 || cpu.Cycles != 0 {
+		t.Errorf("expected undoing every step to restore the initial state, got A=%d D=%d PC=%d RAM[20]=%d", cpu.A, cpu.D, cpu.PC, cpu.RAM[20])
+	}
+}
+
+func TestDisassemble_CourseFiles(t *testing.T) {
+	for _, name := range []string{"add/Add", "max/Max", "rect/Rect", "pong/Pong"} {
+		binary, err := os.Open("../../../06/" + name + ".hack")
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := Load(binary)
+		binary.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var output strings.Builder
+		d := Disassemble(p)
+		err = d.Write(&output, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The annotated output must still reassemble to the same words
+		reassembled, err := Assemble(strings.NewReader(output.String()))
+		if err != nil {
+			t.Fatalf("did not expect an error, but %q returned reassembling %s", err, name)
+		}
+
+		if len(d.Invalid) != 0 || len(reassembled.ROM) != len(p.ROM) {
+			t.Fatalf("expected %d valid instructions but got %d with %d invalid for %s", len(p.ROM), len(reassembled.ROM), len(d.Invalid), name)
+		}
+		for i := range p.ROM {
+			if p.ROM[i] != reassembled.ROM[i] {
+				t.Errorf("expected %016b but got %016b at %d for %s (%s)", p.ROM[i], reassembled.ROM[i], i, name, d.Instructions[i])
+				break
+			}
+		}
+	}
+}
+
+type decodeTest struct {
+	word      uint16
+	expOutput string
+	expectErr bool
+}
+
+var decodeTests = []decodeTest{
+	{word: 0b0000000000010101, expOutput: "@21"},
+	{word: 0b1110101010000111, expOutput: "0;JMP"},
+	{word: 0b1111110111011000, expOutput: "MD=M+1"},
+	{word: 0b1110001100101011, expOutput: "AM=D;JGE"},
+	{word: 0b1110000111010000, expOutput: "D=A-D"},
+	// No computation has these control bits
+	{word: 0b1110000001010000, expectErr: true},
+	// Bits 13 and 14 must be set
+	{word: 0b1000110000010000, expectErr: true},
+}
+
+func TestDecode(t *testing.T) {
+	for _, test := range decodeTests {
+		output, ok := Decode(test.word)
+
+		if ok && test.expectErr {
+			t.Errorf("expected an error but none returned for %016b", test.word)
+		}
+
+		if !ok && !test.expectErr {
+			t.Errorf("did not expect an error for %016b", test.word)
+		}
+
+		if output != test.expOutput {
+			t.Errorf("expected %q but got %q for %016b", test.expOutput, output, test.word)
+		}
+	}
+}
+
+func TestDisassemble_Symbols(t *testing.T) {
+	p, err := Load(strings.NewReader("0000000000010000\n1110101010001000\n0000000000000100\n1110101010000111\n1110000001010000\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = p.ReadSymbols(strings.NewReader("// symbols\nvariable count 16\nlabel DONE 4\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var output strings.Builder
+	d := Disassemble(p)
+	err = d.Write(&output, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expOutput := "@count\nM=0\n@DONE\n0;JMP\n(DONE)\n// 4: 1110000001010000 is not a valid instruction\n"
+	if output.String() != expOutput {
+		t.Errorf("expected %q but got %q", expOutput, output.String())
+	}
+
+	if err := p.ReadSymbols(strings.NewReader("constant x 3")); err == nil {
+		t.Errorf("expected an error but none returned for an unknown symbol kind")
+	}
+}
+
+func TestDisassemble_Targets(t *testing.T) {
+	// Jumps to the end of the program and past it, where no label can be written
+	for source, expOutput := range map[string]string{
+		"@2\n0;JMP":   "@L2\n0;JMP\n(L2)\n",
+		"@100\n0;JMP": "@100\n0;JMP\n",
+	} {
+		p, err := Assemble(strings.NewReader(source))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var output strings.Builder
+		err = Disassemble(p).Write(&output, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if output.String() != expOutput {
+			t.Errorf("expected %q but got %q for %q", expOutput, output.String(), source)
+		}
+
+		reassembled, err := Assemble(strings.NewReader(output.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(reassembled.ROM, p.ROM) {
+			t.Errorf("expected %q to reassemble to the same program", output.String())
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package jackdebugger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ChelseaDH/VMTranslator/screen"
+	"github.com/ChelseaDH/VMTranslator/snapshot"
+)
+
+const help = `Commands:
+  step, s             run to the next statement, entering subroutine calls
+  next, n             run to the next statement, stepping over subroutine calls
+  finish, f           run until the current subroutine returns
+  continue, c         run until a breakpoint is reached or the program halts
+  break <loc>, b      set a breakpoint at Class.jack:line, Class:line or Class.subroutine
+  delete <loc>, d     remove a breakpoint
+  info, i             list breakpoints
+  backtrace, bt       show the subroutine calls in progress
+  print <var>, p      show a variable, an array element such as a[i], or a static as Class.name
+  locals              show the arguments, locals and fields of the current subroutine
+  list [n], l         show the n lines of Jack source around the current line (default 9)
+  screenshot <file>   write the screen as a PNG image, or PBM if the file name ends in .pbm
+  save <file>         save a snapshot of the VM
+  load <file>         restore a snapshot of the VM, saved while running this program
+  quit, q             exit
+`
+
+// Execute runs a single debugger command, writing its output to w. It returns true if the command was quit.
+func (d *Debugger) Execute(line string, w io.Writer) (bool, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false, nil
+	}
+
+	command, args := fields[0], fields[1:]
+	switch command {
+	case "step", "s", "next", "n", "finish", "f", "continue", "c":
+		run := map[string]func() (Reason, error){
+			"step": d.Step, "s": d.Step,
+			"next": d.Next, "n": d.Next,
+			"finish": d.Finish, "f": d.Finish,
+			"continue": d.Continue, "c": d.Continue,
+		}[command]
+
+		reason, err := run()
+		if err != nil {
+			return false, err
+		}
+		d.writeStop(w, reason)
+
+	case "break", "b", "delete", "d":
+		if len(args) != 1 {
+			return false, fmt.Errorf("expected a location such as Main.jack:12 or Main.main")
+		}
+
+		err := d.SetBreakpoint(args[0], command == "break" || command == "b")
+		if err != nil {
+			return false, err
+		}
+
+	case "info", "i":
+		for _, breakpoint := range d.Breakpoints() {
+			fmt.Fprintf(w, "breakpoint %s\n", breakpoint)
+		}
+
+	case "backtrace", "bt":
+		for i, location := range d.Backtrace() {
+			fmt.Fprintf(w, "#%d %s\n", i, location)
+		}
+
+	case "print", "p":
+		if len(args) != 1 {
+			return false, fmt.Errorf("expected a variable name")
+		}
+
+		value, err := d.print(args[0])
+		if err != nil {
+			return false, err
+		}
+		fmt.Fprintln(w, value)
+
+	case "locals":
+		values := d.Variables()
+		if values == nil {
+			fmt.Fprintf(w, "No symbols for %s\n", d.Machine.Function(d.Machine.PC))
+		}
+		for _, value := range values {
+			fmt.Fprintln(w, value)
+		}
+
+	case "list", "l":
+		n := 9
+		if len(args) > 0 {
+			var err error
+			n, err = strconv.Atoi(args[0])
+			if err != nil || n < 1 {
+				return false, fmt.Errorf("expected a positive count, got %s", args[0])
+			}
+		}
+		d.writeListing(w, n)
+
+	case "screenshot":
+		if len(args) != 1 {
+			return false, fmt.Errorf("%s needs a file name", command)
+		}
+
+		err := screen.WriteFile(args[0], d.Machine.RAM[screen.Base:screen.Base+screen.Words])
+		if err != nil {
+			return false, err
+		}
+
+	case "save", "load":
+		if len(args) != 1 {
+			return false, fmt.Errorf("%s needs a file name", command)
+		}
+
+		var err error
+		if command == "save" {
+			err = d.save(args[0])
+		} else {
+			err = d.load(args[0])
+		}
+		if err != nil {
+			return false, err
+		}
+		if command == "load" {
+			d.writeLocation(w)
+		}
+
+	case "help", "h":
+		io.WriteString(w, help)
+
+	case "quit", "q":
+		return true, nil
+
+	default:
+		return false, fmt.Errorf("unknown command %s, try help", command)
+	}
+
+	return false, nil
+}
+
+func (d *Debugger) save(name string) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	err = snapshot.SaveVM(file, d.Machine)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (d *Debugger) load(name string) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	m, err := snapshot.LoadVM(file)
+	if err != nil {
+		return fmt.Errorf("%s: %s", name, err)
+	}
+	return d.Restore(m)
+}
+
+// print shows a variable, or an element of an array given as name[index] where index is a number or variable.
+func (d *Debugger) print(s string) (string, error) {
+	open := strings.Index(s, "[")
+	if open == -1 {
+		value, err := d.Lookup(s)
+		if err != nil {
+			return "", err
+		}
+		return value.String(), nil
+	}
+
+	if !strings.HasSuffix(s, "]") {
+		return "", fmt.Errorf("missing ] in %s", s)
+	}
+
+	array, err := d.Lookup(s[:open])
+	if err != nil {
+		return "", err
+	}
+
+	index, err := strconv.Atoi(s[open+1 : len(s)-1])
+	if err != nil {
+		variable, lookupErr := d.Lookup(s[open+1 : len(s)-1])
+		if lookupErr != nil {
+			return "", lookupErr
+		}
+		index = int(variable.Value)
+	}
+
+	address := uint16(array.Value+int16(index)) & 0x7fff
+	return fmt.Sprintf("%s[%d] = RAM[%d] = %d", array.Name, index, address, d.Machine.RAM[address]), nil
+}
+
+func (d *Debugger) writeStop(w io.Writer, reason Reason) {
+	switch reason {
+	case Breakpoint:
+		fmt.Fprintln(w, "Breakpoint:")
+	case Halted:
+		fmt.Fprintln(w, "Program halted")
+	case Finished:
+		fmt.Fprintln(w, "Program finished")
+		return
+	case Limit:
+		fmt.Fprintf(w, "Stopped after %d commands\n", d.Limit)
+	}
+	d.writeLocation(w)
+}
+
+func (d *Debugger) writeLocation(w io.Writer) {
+	location := d.Location(d.Machine.PC)
+	source := ""
+	if c := d.Classes[location.Class]; c != nil && location.Line > 0 && location.Line <= len(c.Source) {
+		source = "  " + strings.TrimSpace(c.Source[location.Line-1])
+	}
+	fmt.Fprintf(w, "%s%s\n", location, source)
+}
+
+func (d *Debugger) writeListing(w io.Writer, n int) {
+	location := d.Location(d.Machine.PC)
+	c := d.Classes[location.Class]
+	if c == nil || len(c.Source) == 0 {
+		fmt.Fprintf(w, "No source for %s\n", location.Subroutine)
+		return
+	}
+
+	start := location.Line - n/2
+	if start < 1 {
+		start = 1
+	}
+
+	for line := start; line < start+n && line <= len(c.Source); line++ {
+		marker := "  "
+		if line == location.Line {
+			marker = "=>"
+		}
+		fmt.Fprintf(w, "%s %4d  %s\n", marker, line, c.Source[line-1])
+	}
+}
@@ -0,0 +1,308 @@
+// Package jackdebugger steps through compiled Jack programs a statement at a time on the VM interpreter,
+// using the symbol files JackAnalyser writes to map VM commands back to Jack lines and variables.
+package jackdebugger
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ChelseaDH/VMTranslator/command"
+	"github.com/ChelseaDH/VMTranslator/program"
+	"github.com/ChelseaDH/VMTranslator/snapshot"
+	"github.com/ChelseaDH/VMTranslator/vm"
+)
+
+// Reason describes why the debugger stopped running the program.
+type Reason int
+
+const (
+	Stepped Reason = iota
+	Breakpoint
+	Halted
+	Finished
+	Limit
+)
+
+var reasonNames = []string{"stepped", "breakpoint", "halted", "finished", "limit"}
+
+func (r Reason) String() string {
+	return reasonNames[r]
+}
+
+// Location is a position in the Jack source.
+type Location struct {
+	Class      string
+	Subroutine string
+	// Line is the Jack line, or 0 if the class was compiled without symbols.
+	Line int
+}
+
+func (l Location) String() string {
+	if l.Line == 0 {
+		return l.Subroutine
+	}
+	return fmt.Sprintf("%s.jack:%d (%s)", l.Class, l.Line, l.Subroutine)
+}
+
+// Debugger runs a VM program, stopping at Jack statements rather than individual VM commands.
+type Debugger struct {
+	Machine *vm.Machine
+	// Classes holds the symbols of each class compiled with them, by class name.
+	Classes map[string]*Class
+	// Limit is the most VM commands a single step or continue runs before stopping, 0 for no limit.
+	Limit uint64
+
+	// breakpoints holds the indices of the commands to stop at, along with the location they were set as
+	breakpoints map[int]string
+}
+
+// New returns a Debugger for lines, ready to run the program's first command.
+func New(lines []program.Line, classes map[string]*Class) (*Debugger, error) {
+	m, err := vm.New(lines)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Debugger{
+		Machine:     m,
+		Classes:     classes,
+		breakpoints: make(map[int]string),
+	}, nil
+}
+
+// Restore replaces the machine with m, such as one loaded from a snapshot, which must be running the same program.
+func (d *Debugger) Restore(m *vm.Machine) error {
+	if !snapshot.SameProgram(m.Lines, d.Machine.Lines) {
+		return errors.New("the snapshot is of a different program")
+	}
+
+	// The snapshot may have read the program from another path, so positions are kept as they are now
+	m.Lines = d.Machine.Lines
+	m.KeyboardInput = d.Machine.KeyboardInput
+	d.Machine = m
+	return nil
+}
+
+// LoadClasses reads every symbol file in dir, along with the Jack source next to it if there is one.
+func LoadClasses(dir string) (map[string]*Class, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	classes := make(map[string]*Class)
+	for _, file := range files {
+		if path.Ext(file.Name()) != SymbolFileExt {
+			continue
+		}
+
+		symbols, err := os.Open(path.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		c, err := ReadSymbols(symbols)
+		symbols.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", file.Name(), err)
+		}
+
+		// The source is only used for listings, so it is fine for it to be missing
+		source, err := os.ReadFile(path.Join(dir, c.Name+".jack"))
+		if err == nil {
+			c.Source = strings.Split(string(source), "\n")
+		}
+
+		classes[c.Name] = c
+	}
+
+	return classes, nil
+}
+
+// class returns the symbols of the class the command at index was compiled from, or nil if there are none.
+func (d *Debugger) class(index int) *Class {
+	if index < 0 || index >= len(d.Machine.Lines) {
+		return nil
+	}
+	return d.Classes[strings.TrimSuffix(path.Base(d.Machine.Lines[index].File), ".vm")]
+}
+
+// Location returns where in the Jack source the command at index was compiled from.
+func (d *Debugger) Location(index int) Location {
+	l := Location{Subroutine: d.Machine.Function(index)}
+	if c := d.class(index); c != nil {
+		l.Class = c.Name
+		l.Line = c.JackLine(d.Machine.Lines[index].Number)
+	}
+
+	return l
+}
+
+// statementStart reports whether the command at index is the first compiled from a Jack statement.
+// Function commands start a subroutine's declaration rather than a statement, and are not counted
+// so that stepping into a subroutine stops once its locals are ready.
+func (d *Debugger) statementStart(index int) bool {
+	c := d.class(index)
+	if c == nil || !c.StatementStart(d.Machine.Lines[index].Number) {
+		return false
+	}
+
+	fc, ok := d.Machine.Lines[index].Command.(*command.FunctionCommand)
+	return !ok || fc.Type() != command.Function
+}
+
+// resolve finds the commands a breakpoint given as Class.jack:line, Class:line or Class.subroutine stops at.
+func (d *Debugger) resolve(s string) ([]int, error) {
+	var indices []int
+
+	if i := strings.LastIndex(s, ":"); i != -1 {
+		name := strings.TrimSuffix(s[:i], ".jack")
+		line, err := strconv.Atoi(s[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid line number in %s", s)
+		}
+		if _, ok := d.Classes[name]; !ok {
+			return nil, fmt.Errorf("no symbols for class %s", name)
+		}
+
+		for index := range d.Machine.Lines {
+			if d.statementStart(index) && d.Location(index).Class == name && d.Location(index).Line == line {
+				indices = append(indices, index)
+			}
+		}
+	} else {
+		// A subroutine breakpoint stops at its first statement, after the function command has set up its locals
+		for index := range d.Machine.Lines {
+			if d.Machine.Function(index) != s {
+				continue
+			}
+
+			for index++; index < len(d.Machine.Lines) && d.Machine.Function(index) == s; index++ {
+				if d.statementStart(index) {
+					indices = append(indices, index)
+					break
+				}
+			}
+			break
+		}
+	}
+
+	if len(indices) == 0 {
+		return nil, fmt.Errorf("no code found for %s", s)
+	}
+
+	return indices, nil
+}
+
+// SetBreakpoint adds or removes a breakpoint given as Class.jack:line, Class:line or Class.subroutine.
+func (d *Debugger) SetBreakpoint(s string, set bool) error {
+	indices, err := d.resolve(s)
+	if err != nil {
+		return err
+	}
+
+	for _, index := range indices {
+		if set {
+			d.breakpoints[index] = s
+		} else {
+			delete(d.breakpoints, index)
+		}
+	}
+
+	return nil
+}
+
+// Breakpoints returns the breakpoints that are set, in the form they were given.
+func (d *Debugger) Breakpoints() []string {
+	seen := make(map[string]bool)
+	var breakpoints []string
+	for _, s := range d.breakpoints {
+		if !seen[s] {
+			seen[s] = true
+			breakpoints = append(breakpoints, s)
+		}
+	}
+	sort.Strings(breakpoints)
+
+	return breakpoints
+}
+
+// run executes at least one command, and then more until done returns true, a breakpoint is reached,
+// the program halts or finishes, or Limit commands have run.
+func (d *Debugger) run(done func() bool) (Reason, error) {
+	m := d.Machine
+	for count := uint64(0); d.Limit == 0 || count < d.Limit; count++ {
+		if m.Halted() {
+			return Halted, nil
+		}
+
+		err := m.Step()
+		if errors.Is(err, vm.ErrFinished) || m.Finished() {
+			return Finished, nil
+		}
+		if err != nil {
+			return Stepped, err
+		}
+
+		if _, ok := d.breakpoints[m.PC]; ok {
+			return Breakpoint, nil
+		}
+		if done() {
+			return Stepped, nil
+		}
+	}
+
+	return Limit, nil
+}
+
+// Step runs to the next Jack statement, entering any subroutine called along the way.
+func (d *Debugger) Step() (Reason, error) {
+	return d.run(func() bool {
+		return d.statementStart(d.Machine.PC)
+	})
+}
+
+// Next runs to the next Jack statement of the current subroutine or its callers, stepping over calls.
+func (d *Debugger) Next() (Reason, error) {
+	depth := len(d.Machine.Frames)
+	return d.run(func() bool {
+		return len(d.Machine.Frames) <= depth && d.statementStart(d.Machine.PC)
+	})
+}
+
+// Finish runs until the current subroutine returns.
+func (d *Debugger) Finish() (Reason, error) {
+	depth := len(d.Machine.Frames)
+	return d.run(func() bool {
+		return len(d.Machine.Frames) < depth
+	})
+}
+
+// Continue runs until a breakpoint is reached or the program halts or finishes.
+func (d *Debugger) Continue() (Reason, error) {
+	return d.run(func() bool {
+		return false
+	})
+}
+
+// Backtrace returns the location of every subroutine call in progress, innermost first.
+func (d *Debugger) Backtrace() []Location {
+	m := d.Machine
+	locations := []Location{d.Location(m.PC)}
+
+	// Each caller is stopped at the call command before the one its callee returns to
+	for i := len(m.Frames) - 1; i >= 0; i-- {
+		// Sys.init is called by the bootstrap, and so returns past the end of the program
+		ret := m.Frames[i].Return
+		if ret <= 0 || ret >= len(m.Lines) {
+			break
+		}
+		locations = append(locations, d.Location(ret-1))
+	}
+
+	return locations
+}
@@ -0,0 +1,237 @@
+package jackdebugger
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ChelseaDH/VMTranslator/program"
+)
+
+// Main.vm and Main.jsym were compiled by JackAnalyser -symbols from:
+//
+//	 1  class Main {
+//	 2      static int total;
+//	 3
+//	 4      function void main() {
+//	 5          var int i;
+//	 6          let i = 1;
+//	 7          while (i < 4) {
+//	 8              let total = total + Main.double(i);
+//	 9              let i = i + 1;
+//	10          }
+//	11          return;
+//	12      }
+//	13
+//	14      function int double(int n) {
+//	15          return n + n;
+//	16      }
+//	17  }
+const mainVM = `function Main.main 1
+push constant 1
+pop local 0
+label COND_MAIN_0
+push local 0
+push constant 4
+lt
+not
+if-goto COND_MAIN_1
+push static 0
+push local 0
+call Main.double 1
+add
+pop static 0
+push local 0
+push constant 1
+add
+pop local 0
+goto COND_MAIN_0
+label COND_MAIN_1
+push constant 0
+return
+function Main.double 0
+push argument 0
+push argument 0
+add
+return
+`
+
+const mainSymbols = `class Main
+static 0 int total
+subroutine function Main.main 4
+local 0 int i
+subroutine function Main.double 14
+argument 0 int n
+line 1 4
+line 2 6
+line 4 7
+line 10 8
+line 15 9
+line 21 11
+line 23 14
+line 24 15
+`
+
+// Sys is hand written, and so has no symbols
+const sysVM = `function Sys.init 0
+call Main.main 0
+pop temp 0
+call Sys.halt 0
+function Sys.halt 0
+label HALT
+goto HALT
+`
+
+type commandTest struct {
+	command   string
+	expOutput string
+	expectErr bool
+}
+
+var commandTests = []commandTest{
+	{command: "break Main.main", expOutput: ""},
+	{command: "break Main.jack:15", expOutput: ""},
+	{command: "c", expOutput: "Breakpoint:\nMain.jack:6 (Main.main)\n"},
+	{command: "delete Main.main", expOutput: ""},
+	{command: "n", expOutput: "Main.jack:7 (Main.main)\n"},
+	{command: "n", expOutput: "Main.jack:8 (Main.main)\n"},
+	{command: "p i", expOutput: "local int i = 1\n"},
+	{command: "s", expOutput: "Breakpoint:\nMain.jack:15 (Main.double)\n"},
+	{command: "bt", expOutput: "#0 Main.jack:15 (Main.double)\n#1 Main.jack:8 (Main.main)\n#2 Sys.init\n"},
+	{command: "locals", expOutput: "argument int n = 1\n"},
+	{command: "f", expOutput: "Main.jack:8 (Main.main)\n"},
+	{command: "n", expOutput: "Main.jack:9 (Main.main)\n"},
+	{command: "p total", expOutput: "static int total = 2\n"},
+	{command: "delete Main.jack:15", expOutput: ""},
+	{command: "i", expOutput: ""},
+	{command: "c", expOutput: "Program halted\nSys.halt\n"},
+	{command: "p Main.total", expOutput: "static int total = 12\n"},
+	{command: "p total", expectErr: true},
+	{command: "break Main.jack:3", expectErr: true},
+	{command: "break Other.jack:3", expectErr: true},
+	{command: "screenshot", expectErr: true},
+	{command: "jump", expectErr: true},
+}
+
+func TestDebugger_Execute(t *testing.T) {
+	main, err := program.Read("Main.vm", strings.NewReader(mainVM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sys, err := program.Read("Sys.vm", strings.NewReader(sysVM))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	symbols, err := ReadSymbols(strings.NewReader(mainSymbols))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := New(append(main, sys...), map[string]*Class{"Main": symbols})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range commandTests {
+		var output strings.Builder
+		_, err := d.Execute(test.command, &output)
+
+		if err == nil && test.expectErr {
+			t.Errorf("expected an error but none returned for %s", test.command)
+		}
+
+		if err != nil && !test.expectErr {
+			t.Errorf("did not expect an error, but %q returned for %s", err, test.command)
+		}
+
+		if output.String() != test.expOutput {
+			t.Errorf("expected output %q but got %q for %s", test.expOutput, output.String(), test.command)
+		}
+	}
+}
+
+type symbolsTest struct {
+	input     string
+	expectErr bool
+}
+
+var symbolsTests = []symbolsTest{
+	{input: mainSymbols},
+	{input: "static 0 int x", expectErr: true},
+	{input: "class Main\nlocal 0 int x", expectErr: true},
+	{input: "class Main\nline 1", expectErr: true},
+	{input: "class Main\nvariable 0 int x", expectErr: true},
+}
+
+func TestDebugger_Snapshot(t *testing.T) {
+	main, err := program.Read("Main.vm", strings.NewReader(mainVM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sys, err := program.Read("Sys.vm", strings.NewReader(sysVM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	symbols, err := ReadSymbols(strings.NewReader(mainSymbols))
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "main.snapshot")
+
+	d, err := New(append(main, sys...), map[string]*Class{"Main": symbols})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, command := range []string{"break Main.jack:15", "c", "save " + name, "delete Main.jack:15", "c"} {
+		_, err = d.Execute(command, io.Discard)
+		if err != nil {
+			t.Fatalf("did not expect an error, but %q returned for %s", err, command)
+		}
+	}
+
+	var output strings.Builder
+	_, err = d.Execute("load "+name, &output)
+	if err != nil {
+		t.Fatalf("did not expect an error, but %q returned", err)
+	}
+	if expOutput := "Main.jack:15 (Main.double)\n"; output.String() != expOutput {
+		t.Errorf("expected output %q but got %q", expOutput, output.String())
+	}
+
+	// Carrying on from the snapshot gives the same result as running straight through
+	for _, test := range []commandTest{
+		{command: "p n", expOutput: "argument int n = 1\n"},
+		{command: "c", expOutput: "Program halted\nSys.halt\n"},
+		{command: "p Main.total", expOutput: "static int total = 12\n"},
+	} {
+		output.Reset()
+		_, err = d.Execute(test.command, &output)
+		if err != nil || output.String() != test.expOutput {
+			t.Errorf("expected output %q but got %q, %v for %s", test.expOutput, output.String(), err, test.command)
+		}
+	}
+
+	other, err := New(sys[4:], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Execute("load "+name, io.Discard); err == nil {
+		t.Errorf("expected an error loading a snapshot of another program, but none returned")
+	}
+}
+
+func TestReadSymbols(t *testing.T) {
+	for _, test := range symbolsTests {
+		_, err := ReadSymbols(strings.NewReader(test.input))
+
+		if err == nil && test.expectErr {
+			t.Errorf("expected an error but none returned for %q", test.input)
+		}
+
+		if err != nil && !test.expectErr {
+			t.Errorf("did not expect an error, but %q returned for %q", err, test.input)
+		}
+	}
+}
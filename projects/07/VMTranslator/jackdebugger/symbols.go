@@ -0,0 +1,161 @@
+package jackdebugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SymbolFileExt is the extension of the symbol files JackAnalyser writes with the -symbols flag.
+const SymbolFileExt = ".jsym"
+
+// Variable is a Jack variable and the index it has in its VM segment.
+type Variable struct {
+	Index int
+	Type  string
+	Name  string
+}
+
+// Subroutine is a Jack constructor, function or method along with its arguments and locals.
+type Subroutine struct {
+	Kind      string
+	Name      string
+	Line      int
+	Arguments []Variable
+	Locals    []Variable
+}
+
+// SourceLine records that the VM code from line VM of a .vm file onwards was compiled from line Jack of its class.
+type SourceLine struct {
+	VM   int
+	Jack int
+}
+
+// Class holds the symbols of a single compiled Jack class.
+type Class struct {
+	Name        string
+	Statics     []Variable
+	Fields      []Variable
+	Subroutines map[string]*Subroutine
+	// Lines is sorted by VM line.
+	Lines []SourceLine
+	// Source holds the lines of the Jack source, if it is available.
+	Source []string
+}
+
+// ReadSymbols parses a symbol file written by JackAnalyser.
+func ReadSymbols(r io.Reader) (*Class, error) {
+	c := &Class{Subroutines: make(map[string]*Subroutine)}
+	var current *Subroutine
+
+	scanner := bufio.NewScanner(r)
+	number := 0
+	for scanner.Scan() {
+		number++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		err := fmt.Errorf("line %d: invalid %s entry", number, fields[0])
+		switch fields[0] {
+		case "class":
+			if len(fields) != 2 {
+				return nil, err
+			}
+			c.Name = fields[1]
+
+		case "static", "field", "argument", "local":
+			if len(fields) != 4 {
+				return nil, err
+			}
+			index, convErr := strconv.Atoi(fields[1])
+			if convErr != nil {
+				return nil, err
+			}
+			v := Variable{Index: index, Type: fields[2], Name: fields[3]}
+
+			switch fields[0] {
+			case "static":
+				c.Statics = append(c.Statics, v)
+			case "field":
+				c.Fields = append(c.Fields, v)
+			case "argument", "local":
+				if current == nil {
+					return nil, fmt.Errorf("line %d: %s declared outside of a subroutine", number, fields[0])
+				}
+				if fields[0] == "argument" {
+					current.Arguments = append(current.Arguments, v)
+				} else {
+					current.Locals = append(current.Locals, v)
+				}
+			}
+
+		case "subroutine":
+			if len(fields) != 4 {
+				return nil, err
+			}
+			line, convErr := strconv.Atoi(fields[3])
+			if convErr != nil {
+				return nil, err
+			}
+			current = &Subroutine{Kind: fields[1], Name: fields[2], Line: line}
+			c.Subroutines[current.Name] = current
+
+		case "line":
+			if len(fields) != 3 {
+				return nil, err
+			}
+			vm, convErr := strconv.Atoi(fields[1])
+			if convErr != nil {
+				return nil, err
+			}
+			jack, convErr := strconv.Atoi(fields[2])
+			if convErr != nil {
+				return nil, err
+			}
+			c.Lines = append(c.Lines, SourceLine{VM: vm, Jack: jack})
+
+		default:
+			return nil, fmt.Errorf("line %d: unknown entry %s", number, fields[0])
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if c.Name == "" {
+		return nil, fmt.Errorf("symbol file does not name a class")
+	}
+
+	sort.SliceStable(c.Lines, func(i, j int) bool {
+		return c.Lines[i].VM < c.Lines[j].VM
+	})
+
+	return c, nil
+}
+
+// JackLine returns the Jack line the VM code at vmLine was compiled from, or 0 if it is not known.
+func (c *Class) JackLine(vmLine int) int {
+	i := sort.Search(len(c.Lines), func(i int) bool {
+		return c.Lines[i].VM > vmLine
+	})
+	if i == 0 {
+		return 0
+	}
+
+	return c.Lines[i-1].Jack
+}
+
+// StatementStart reports whether the VM code at vmLine is the first compiled from a Jack statement or declaration.
+func (c *Class) StatementStart(vmLine int) bool {
+	i := sort.Search(len(c.Lines), func(i int) bool {
+		return c.Lines[i].VM >= vmLine
+	})
+
+	return i < len(c.Lines) && c.Lines[i].VM == vmLine
+}
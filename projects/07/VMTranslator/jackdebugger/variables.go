@@ -0,0 +1,125 @@
+package jackdebugger
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ChelseaDH/VMTranslator/vm"
+)
+
+// Value is a variable and the value it currently holds.
+type Value struct {
+	Variable
+	// Kind is the segment the variable is stored in: static, field, argument or local.
+	Kind    string
+	Address int16
+	Value   int16
+}
+
+func (v Value) String() string {
+	return fmt.Sprintf("%s %s %s = %s", v.Kind, v.Type, v.Name, format(v.Type, v.Value))
+}
+
+// format shows a value as the Jack type it was declared with.
+func format(typ string, value int16) string {
+	switch typ {
+	case "int":
+		return fmt.Sprint(value)
+	case "boolean":
+		if value == 0 {
+			return "false"
+		}
+		return "true"
+	case "char":
+		if value >= 32 && value < 127 {
+			return fmt.Sprintf("'%c' (%d)", rune(value), value)
+		}
+		return fmt.Sprint(value)
+	default:
+		if value == 0 {
+			return "null"
+		}
+		return fmt.Sprintf("%s@%d", typ, value)
+	}
+}
+
+// subroutine returns the symbols of the subroutine being run, or nil if it was compiled without them.
+func (d *Debugger) subroutine() (*Class, *Subroutine) {
+	c := d.class(d.Machine.PC)
+	if c == nil {
+		return nil, nil
+	}
+	return c, c.Subroutines[d.Machine.Function(d.Machine.PC)]
+}
+
+func (d *Debugger) value(kind string, v Variable, base int16) Value {
+	address := base + int16(v.Index)
+	return Value{Variable: v, Kind: kind, Address: address, Value: d.Machine.RAM[uint16(address)&0x7fff]}
+}
+
+// Variables returns the arguments and locals of the subroutine being run,
+// followed by the fields of the current object in a method or constructor.
+func (d *Debugger) Variables() []Value {
+	c, s := d.subroutine()
+	if s == nil {
+		return nil
+	}
+
+	ram := &d.Machine.RAM
+	var values []Value
+	for _, v := range s.Arguments {
+		values = append(values, d.value("argument", v, ram[vm.ARG]))
+	}
+	for _, v := range s.Locals {
+		values = append(values, d.value("local", v, ram[vm.LCL]))
+	}
+	if s.Kind != "function" {
+		for _, v := range c.Fields {
+			values = append(values, d.value("field", v, ram[vm.THIS]))
+		}
+	}
+
+	return values
+}
+
+// Lookup finds a variable by its Jack name, searching the subroutine being run and then its class.
+// The statics of any class can also be named as Class.name.
+func (d *Debugger) Lookup(name string) (Value, error) {
+	c, s := d.subroutine()
+
+	if i := strings.Index(name, "."); i != -1 {
+		other, ok := d.Classes[name[:i]]
+		if !ok {
+			return Value{}, fmt.Errorf("no symbols for class %s", name[:i])
+		}
+		return d.static(other, name[i+1:])
+	}
+
+	if s == nil {
+		return Value{}, fmt.Errorf("no symbols for %s", d.Machine.Function(d.Machine.PC))
+	}
+
+	for _, v := range d.Variables() {
+		if v.Name == name {
+			return v, nil
+		}
+	}
+
+	return d.static(c, name)
+}
+
+func (d *Debugger) static(c *Class, name string) (Value, error) {
+	for _, v := range c.Statics {
+		if v.Name != name {
+			continue
+		}
+
+		address, ok := d.Machine.StaticAddress(c.Name+".vm", v.Index)
+		if !ok {
+			return Value{}, fmt.Errorf("%s.%s is never used, so has no address", c.Name, name)
+		}
+		return d.value("static", v, int16(address)-int16(v.Index)), nil
+	}
+
+	return Value{}, fmt.Errorf("unknown variable %s", name)
+}
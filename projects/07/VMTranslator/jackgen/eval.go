@@ -0,0 +1,250 @@
+package jackgen
+
+import "errors"
+
+// errBudget is raised while evaluating a program that runs for too long or allocates too much,
+// which the generator then throws away.
+var errBudget = errors.New("program exceeds its budget")
+
+const (
+	// maxSteps is the most statements and expressions a program may evaluate.
+	maxSteps = 20000
+	// maxAllocations is the most arrays and objects a program may create, well within the Jack heap.
+	maxAllocations = 1000
+)
+
+// value is the contents of a variable, which is an int or refers to an array or object.
+type value struct {
+	n      int16
+	array  []int16
+	object *object
+}
+
+type object struct {
+	fields []value
+}
+
+// evaluator runs a program directly from its syntax tree, with the semantics the compiled program should have:
+// 16 bit arithmetic that wraps around, true as -1, operands evaluated left to right and everything starting at 0.
+type evaluator struct {
+	statics     map[*class][]value
+	log         []int16
+	steps       int
+	allocations int
+}
+
+type frame struct {
+	e      *evaluator
+	class  *class
+	this   *object
+	args   []value
+	locals []value
+}
+
+// evaluate runs main, returning the values it logs, or errBudget if it exceeds the budget.
+func evaluate(classes []*class, main *subroutine) (log []int16, err error) {
+	e := &evaluator{statics: make(map[*class][]value)}
+	for _, c := range classes {
+		e.statics[c] = make([]value, len(c.statics))
+	}
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			if recovered != errBudget {
+				panic(recovered)
+			}
+			err = errBudget
+		}
+	}()
+
+	e.call(main, nil, nil)
+	return e.log, nil
+}
+
+func (e *evaluator) step() {
+	e.steps++
+	if e.steps > maxSteps {
+		panic(errBudget)
+	}
+}
+
+func (e *evaluator) allocate() {
+	e.allocations++
+	if e.allocations > maxAllocations {
+		panic(errBudget)
+	}
+}
+
+// call runs a subroutine, returning its result or the object it constructed.
+func (e *evaluator) call(s *subroutine, this *object, args []value) value {
+	e.step()
+	// A method's object is its first argument, leaving its parameters numbered from 1 as the compiler numbers them
+	if s.kind == "method" {
+		args = append([]value{{object: this}}, args...)
+	}
+	f := &frame{e: e, class: s.class, this: this, args: args, locals: make([]value, len(s.locals))}
+	if s.kind == "constructor" {
+		e.allocate()
+		f.this = &object{fields: make([]value, len(s.class.fields))}
+	}
+
+	for _, st := range s.body {
+		st.eval(f)
+	}
+
+	switch {
+	case s.kind == "constructor":
+		return value{object: f.this}
+	case s.void:
+		return value{}
+	default:
+		return value{n: s.result.eval(f)}
+	}
+}
+
+func (f *frame) slot(v *variable) *value {
+	switch v.kind {
+	case staticVar:
+		return &f.e.statics[f.class][v.index]
+	case fieldVar:
+		return &f.this.fields[v.index]
+	case argumentVar:
+		return &f.args[v.index]
+	default:
+		return &f.locals[v.index]
+	}
+}
+
+func (s *letStatement) eval(f *frame) {
+	f.e.step()
+	if s.index == nil {
+		f.slot(s.target).n = s.value.eval(f)
+		return
+	}
+
+	// The compiler works out the element's address before the value
+	index := s.index.eval(f) & (ArraySize - 1)
+	f.slot(s.target).array[index] = s.value.eval(f)
+}
+
+func (s *newStatement) eval(f *frame) {
+	f.e.step()
+	if s.constructor == nil {
+		f.e.allocate()
+		*f.slot(s.target) = value{array: make([]int16, ArraySize)}
+		return
+	}
+	*f.slot(s.target) = f.e.call(s.constructor, nil, evalArgs(f, s.args))
+}
+
+func (s *ifStatement) eval(f *frame) {
+	f.e.step()
+	body := s.otherwise
+	if s.condition.eval(f) != 0 {
+		body = s.then
+	}
+	for _, st := range body {
+		st.eval(f)
+	}
+}
+
+func (s *whileStatement) eval(f *frame) {
+	f.e.step()
+	counter := f.slot(s.counter)
+	for counter.n = 0; counter.n < int16(s.count); counter.n++ {
+		for _, st := range s.body {
+			st.eval(f)
+		}
+	}
+}
+
+func (s *doStatement) eval(f *frame) {
+	f.e.step()
+	s.call.value(f)
+}
+
+func (s *logStatement) eval(f *frame) {
+	f.e.step()
+	f.e.log = append(f.e.log, s.value.eval(f))
+}
+
+func (e *intConstant) eval(f *frame) int16 {
+	return e.value
+}
+
+func (e *variableExpression) eval(f *frame) int16 {
+	return f.slot(e.v).n
+}
+
+func (e *arrayExpression) eval(f *frame) int16 {
+	f.e.step()
+	index := e.index.eval(f) & (ArraySize - 1)
+	return f.slot(e.array).array[index]
+}
+
+func (e *unaryExpression) eval(f *frame) int16 {
+	f.e.step()
+	operand := e.operand.eval(f)
+	if e.operator == '-' {
+		return -operand
+	}
+	return ^operand
+}
+
+func (e *binaryExpression) eval(f *frame) int16 {
+	f.e.step()
+	left := e.left.eval(f)
+	right := e.right.eval(f)
+
+	switch e.operator {
+	case '+':
+		return left + right
+	case '-':
+		return left - right
+	case '*':
+		return left * right
+	case '/':
+		return left / right
+	case '&':
+		return left & right
+	case '|':
+		return left | right
+	case '<':
+		return boolean(left < right)
+	case '>':
+		return boolean(left > right)
+	default:
+		return boolean(left == right)
+	}
+}
+
+func boolean(b bool) int16 {
+	if b {
+		return -1
+	}
+	return 0
+}
+
+func (e *callExpression) eval(f *frame) int16 {
+	return e.value(f).n
+}
+
+func (e *callExpression) value(f *frame) value {
+	this := f.this
+	if e.object != nil {
+		this = f.slot(e.object).object
+	}
+	if e.subroutine.kind == "function" {
+		this = nil
+	}
+
+	return f.e.call(e.subroutine, this, evalArgs(f, e.args))
+}
+
+func evalArgs(f *frame, args []expression) []value {
+	values := make([]value, len(args))
+	for i, arg := range args {
+		values[i] = value{n: arg.eval(f)}
+	}
+	return values
+}
@@ -0,0 +1,353 @@
+package jackgen
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+const (
+	// maxExpressionDepth and maxStatementDepth bound how deeply expressions and statements nest.
+	maxExpressionDepth = 3
+	maxStatementDepth  = 2
+	// maxLoopCount is the most times a while loop runs.
+	maxLoopCount = 4
+)
+
+// generator builds a program from a random source. Subroutines only call those generated before them,
+// so no program recurses and every loop is counted, which guarantees each program finishes.
+type generator struct {
+	r *rand.Rand
+}
+
+// scope is what the statements and expressions of a subroutine can use.
+type scope struct {
+	// ints are the int variables that can be read and assigned
+	ints    []*variable
+	arrays  []*variable
+	objects []*variable
+	calls   []*callExpression
+	// counters holds a loop counter for each depth of while loop
+	counters []*variable
+}
+
+// Generate returns a random program, the same for the same seed. Programs that would run for too long
+// are thrown away and generated again.
+func Generate(seed int64) *Program {
+	g := &generator{r: rand.New(rand.NewSource(seed))}
+	for {
+		p := g.program()
+		expected, err := evaluate(p.classes, p.main)
+		if err == nil {
+			p.Seed = seed
+			p.Expected = expected
+			return p
+		}
+	}
+}
+
+func (g *generator) between(min int, max int) int {
+	return min + g.r.Intn(max-min+1)
+}
+
+func (g *generator) chance(percent int) bool {
+	return g.r.Intn(100) < percent
+}
+
+func (g *generator) program() *Program {
+	main := &class{name: mainClass}
+	obj := &class{name: objectClass}
+
+	main.statics = variables("s", staticVar, intType, g.between(1, 3), 0)
+	obj.statics = variables("t", staticVar, intType, g.between(0, 2), 0)
+	obj.fields = variables("x", fieldVar, intType, g.between(1, 3), 0)
+
+	for i := g.between(2, 4); i > 0; i-- {
+		main.subroutines = append(main.subroutines, g.subroutine(main, "function", fmt.Sprintf("f%d", len(main.subroutines)), nil))
+	}
+
+	constructor := &subroutine{
+		class:  obj,
+		kind:   "constructor",
+		name:   "new",
+		params: variables("a", argumentVar, intType, len(obj.fields), 0),
+	}
+	for i, field := range obj.fields {
+		constructor.body = append(constructor.body, &letStatement{target: field, value: &variableExpression{v: constructor.params[i]}})
+	}
+	obj.subroutines = append(obj.subroutines, constructor)
+
+	// Methods can call the earlier methods of their object, and any function of Main
+	for i := g.between(1, 3); i > 0; i-- {
+		obj.subroutines = append(obj.subroutines, g.subroutine(obj, "method", fmt.Sprintf("m%d", len(obj.subroutines)-1), main.subroutines))
+	}
+
+	p := &Program{classes: []*class{main, obj}}
+	p.main = g.mainFunction(main, obj)
+	main.subroutines = append(main.subroutines, p.main)
+
+	return p
+}
+
+// variables declares count variables named prefix followed by a number, with indexes starting at first.
+func variables(prefix string, kind varKind, typ varType, count int, first int) []*variable {
+	var declared []*variable
+	for i := 0; i < count; i++ {
+		declared = append(declared, &variable{name: fmt.Sprintf("%s%d", prefix, i), kind: kind, index: first + i, typ: typ})
+	}
+	return declared
+}
+
+// subroutine generates a function or method of c, which can call the subroutines generated before it
+// in its own class and the functions in others.
+func (g *generator) subroutine(c *class, kind string, name string, others []*subroutine) *subroutine {
+	s := &subroutine{class: c, kind: kind, name: name, void: g.chance(25)}
+
+	s.params = variables("a", argumentVar, intType, g.between(0, 3), 0)
+	// A method's object is its first argument
+	if kind == "method" {
+		for _, p := range s.params {
+			p.index++
+		}
+	}
+
+	sc := &scope{}
+	sc.ints = append(sc.ints, s.params...)
+	sc.ints = append(sc.ints, c.statics...)
+	if kind == "method" {
+		sc.ints = append(sc.ints, c.fields...)
+	}
+
+	locals := variables("l", localVar, intType, g.between(0, 3), 0)
+	s.locals = append(s.locals, locals...)
+	sc.ints = append(sc.ints, locals...)
+
+	if g.chance(50) {
+		array := &variable{name: "r", kind: localVar, index: len(s.locals), typ: arrayType}
+		s.locals = append(s.locals, array)
+		sc.arrays = append(sc.arrays, array)
+		s.body = append(s.body, &newStatement{target: array})
+	}
+
+	sc.counters = variables("i", localVar, intType, maxStatementDepth, len(s.locals))
+	s.locals = append(s.locals, sc.counters...)
+
+	for _, other := range c.subroutines {
+		if other.kind == kind {
+			sc.calls = append(sc.calls, &callExpression{subroutine: other, qualified: kind == "function"})
+		}
+	}
+	for _, other := range others {
+		sc.calls = append(sc.calls, &callExpression{subroutine: other, qualified: true})
+	}
+
+	s.body = append(s.body, g.statements(sc, 0, g.between(1, 5))...)
+	if !s.void {
+		s.result = g.expression(sc, maxExpressionDepth)
+	}
+
+	return s
+}
+
+// mainFunction generates Main.main, which creates the arrays and objects, calls everything
+// and finishes by logging the statics of Main.
+func (g *generator) mainFunction(main *class, obj *class) *subroutine {
+	s := &subroutine{class: main, kind: "function", name: "main", void: true}
+	sc := &scope{}
+	sc.ints = append(sc.ints, main.statics...)
+
+	locals := variables("l", localVar, intType, g.between(2, 3), 0)
+	s.locals = append(s.locals, locals...)
+	sc.ints = append(sc.ints, locals...)
+
+	array := &variable{name: "r", kind: localVar, index: len(s.locals), typ: arrayType}
+	s.locals = append(s.locals, array)
+	sc.arrays = append(sc.arrays, array)
+	s.body = append(s.body, &newStatement{target: array})
+
+	// Objects are created before anything else, so that every method call has an object to use
+	constructor := obj.subroutines[0]
+	for i := g.between(1, 2); i > 0; i-- {
+		object := &variable{name: fmt.Sprintf("o%d", len(sc.objects)), kind: localVar, index: len(s.locals), typ: objectType}
+		s.locals = append(s.locals, object)
+		sc.objects = append(sc.objects, object)
+
+		var args []expression
+		for range constructor.params {
+			args = append(args, g.expression(sc, 1))
+		}
+		s.body = append(s.body, &newStatement{target: object, constructor: constructor, args: args})
+
+		for _, method := range obj.subroutines[1:] {
+			sc.calls = append(sc.calls, &callExpression{subroutine: method, object: object})
+		}
+	}
+
+	for _, function := range main.subroutines {
+		sc.calls = append(sc.calls, &callExpression{subroutine: function, qualified: true})
+	}
+
+	sc.counters = variables("i", localVar, intType, maxStatementDepth, len(s.locals))
+	s.locals = append(s.locals, sc.counters...)
+
+	s.body = append(s.body, g.statements(sc, 0, g.between(4, 10))...)
+	for _, static := range main.statics {
+		s.body = append(s.body, &logStatement{value: &variableExpression{v: static}})
+	}
+
+	return s
+}
+
+func (g *generator) statements(sc *scope, depth int, count int) []statement {
+	var statements []statement
+	for i := 0; i < count; i++ {
+		statements = append(statements, g.statement(sc, depth))
+	}
+	return statements
+}
+
+func (g *generator) statement(sc *scope, depth int) statement {
+	for {
+		switch g.r.Intn(7) {
+		case 0, 1:
+			if len(sc.ints) == 0 {
+				continue
+			}
+			return &letStatement{target: g.pick(sc.ints), value: g.expression(sc, maxExpressionDepth)}
+
+		case 2:
+			if len(sc.arrays) == 0 {
+				continue
+			}
+			return &letStatement{
+				target: g.pick(sc.arrays),
+				index:  g.expression(sc, 1),
+				value:  g.expression(sc, maxExpressionDepth),
+			}
+
+		case 3:
+			if depth == maxStatementDepth {
+				continue
+			}
+			s := &ifStatement{condition: g.condition(sc, 2), then: g.statements(sc, depth+1, g.between(1, 3))}
+			if g.chance(50) {
+				s.otherwise = g.statements(sc, depth+1, g.between(1, 3))
+			}
+			return s
+
+		case 4:
+			if depth == maxStatementDepth {
+				continue
+			}
+			return &whileStatement{
+				counter: sc.counters[depth],
+				count:   g.between(0, maxLoopCount),
+				body:    g.statements(sc, depth+1, g.between(1, 3)),
+			}
+
+		case 5:
+			if len(sc.calls) == 0 {
+				continue
+			}
+			return &doStatement{call: g.call(g.r.Intn(len(sc.calls)), sc, maxExpressionDepth-1)}
+
+		default:
+			return &logStatement{value: g.expression(sc, maxExpressionDepth)}
+		}
+	}
+}
+
+func (g *generator) pick(variables []*variable) *variable {
+	return variables[g.r.Intn(len(variables))]
+}
+
+// call returns a new call to the subroutine of sc.calls at index, with arguments no deeper than depth.
+func (g *generator) call(index int, sc *scope, depth int) *callExpression {
+	template := sc.calls[index]
+	call := &callExpression{subroutine: template.subroutine, object: template.object, qualified: template.qualified}
+	for range call.subroutine.params {
+		call.args = append(call.args, g.expression(sc, depth))
+	}
+	return call
+}
+
+// expression returns an int expression no deeper than depth.
+func (g *generator) expression(sc *scope, depth int) expression {
+	if depth == 0 || g.chance(20) {
+		return g.term(sc)
+	}
+
+	switch g.r.Intn(8) {
+	case 0:
+		operators := []byte{'-', '~'}
+		return &unaryExpression{operator: operators[g.r.Intn(len(operators))], operand: g.expression(sc, depth-1)}
+
+	case 1:
+		// Dividing by a small constant never divides by zero or overflows
+		return &binaryExpression{operator: '/', left: g.expression(sc, depth-1), right: &intConstant{value: int16(g.between(1, 9))}}
+
+	case 2:
+		var callable []int
+		for i, call := range sc.calls {
+			if !call.subroutine.void {
+				callable = append(callable, i)
+			}
+		}
+		if len(callable) > 0 {
+			return g.call(callable[g.r.Intn(len(callable))], sc, depth-1)
+		}
+		fallthrough
+
+	case 3:
+		return g.condition(sc, depth)
+
+	default:
+		operators := []byte{'+', '-', '*', '&', '|'}
+		return &binaryExpression{
+			operator: operators[g.r.Intn(len(operators))],
+			left:     g.expression(sc, depth-1),
+			right:    g.expression(sc, depth-1),
+		}
+	}
+}
+
+// condition returns an expression that is always true or false, as if and while statements require.
+func (g *generator) condition(sc *scope, depth int) expression {
+	if depth <= 1 || g.chance(60) {
+		operators := []byte{'<', '>', '='}
+		return &binaryExpression{
+			operator: operators[g.r.Intn(len(operators))],
+			left:     g.expression(sc, depth-1),
+			right:    g.expression(sc, depth-1),
+		}
+	}
+
+	switch g.r.Intn(3) {
+	case 0:
+		return &unaryExpression{operator: '~', operand: g.condition(sc, depth-1)}
+	case 1:
+		return &binaryExpression{operator: '&', left: g.condition(sc, depth-1), right: g.condition(sc, depth-1)}
+	default:
+		return &binaryExpression{operator: '|', left: g.condition(sc, depth-1), right: g.condition(sc, depth-1)}
+	}
+}
+
+// term returns a constant, variable or array element.
+func (g *generator) term(sc *scope) expression {
+	switch g.r.Intn(4) {
+	case 0:
+		if len(sc.arrays) > 0 {
+			return &arrayExpression{array: g.pick(sc.arrays), index: g.term(sc)}
+		}
+		fallthrough
+	case 1, 2:
+		if len(sc.ints) > 0 {
+			return &variableExpression{v: g.pick(sc.ints)}
+		}
+		fallthrough
+	default:
+		if g.chance(70) {
+			return &intConstant{value: int16(g.between(0, 20))}
+		}
+		return &intConstant{value: int16(g.between(0, 32767))}
+	}
+}
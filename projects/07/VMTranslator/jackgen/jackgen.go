@@ -0,0 +1,328 @@
+// Package jackgen generates random well-typed Jack programs along with the values they should log,
+// so that a Jack compiler can be tested by running what it compiles them to and comparing the two.
+//
+// A program is written as classes Main and Obj. Main.main creates arrays and Obj objects and calls
+// the other subroutines, which do arithmetic on their arguments, locals, fields, statics and array elements,
+// branch and loop, and log values by calling Sys.log. Each program is evaluated as it is generated,
+// so its expected log is known without trusting any compiler.
+package jackgen
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ArraySize is the length of every array a program creates. Array indexes are masked with ArraySize-1,
+// which keeps them in bounds whatever value they compute.
+const ArraySize = 8
+
+type varKind int
+
+const (
+	staticVar varKind = iota
+	fieldVar
+	argumentVar
+	localVar
+)
+
+type varType int
+
+const (
+	intType varType = iota
+	arrayType
+	objectType
+)
+
+var typeNames = map[varType]string{intType: "int", arrayType: "Array", objectType: objectClass}
+
+const (
+	mainClass   = "Main"
+	objectClass = "Obj"
+)
+
+type variable struct {
+	name  string
+	kind  varKind
+	index int
+	typ   varType
+}
+
+type class struct {
+	name        string
+	statics     []*variable
+	fields      []*variable
+	subroutines []*subroutine
+}
+
+type subroutine struct {
+	class *class
+	// kind is function, method or constructor
+	kind   string
+	name   string
+	void   bool
+	params []*variable
+	locals []*variable
+	body   []statement
+	// result is the value returned by a subroutine that is not void or a constructor
+	result expression
+}
+
+// Program is a generated Jack program.
+type Program struct {
+	// Seed is the seed the program was generated from.
+	Seed    int64
+	classes []*class
+	main    *subroutine
+	// Expected is the sequence of values the program logs.
+	Expected []int16
+}
+
+// Files returns the Jack source of each class, by file name.
+func (p *Program) Files() map[string]string {
+	files := make(map[string]string)
+	for _, c := range p.classes {
+		files[c.name+".jack"] = c.jack()
+	}
+	return files
+}
+
+// Write writes the source of each class to dir.
+func (p *Program) Write(dir string) error {
+	for name, source := range p.Files() {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(source), 0644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *class) jack() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Generated by jackgen\nclass %s {\n", c.name)
+	declare(&b, "    static", c.statics)
+	declare(&b, "    field", c.fields)
+
+	for _, s := range c.subroutines {
+		b.WriteString("\n")
+		s.write(&b)
+	}
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+// declare writes a declaration of each variable, one per line.
+func declare(b *strings.Builder, keyword string, variables []*variable) {
+	for _, v := range variables {
+		fmt.Fprintf(b, "%s %s %s;\n", keyword, typeNames[v.typ], v.name)
+	}
+}
+
+func (s *subroutine) write(b *strings.Builder) {
+	returns := "int"
+	switch {
+	case s.kind == "constructor":
+		returns = s.class.name
+	case s.void:
+		returns = "void"
+	}
+
+	var params []string
+	for _, p := range s.params {
+		params = append(params, typeNames[p.typ]+" "+p.name)
+	}
+
+	fmt.Fprintf(b, "    %s %s %s(%s) {\n", s.kind, returns, s.name, strings.Join(params, ", "))
+	declare(b, "        var", s.locals)
+	for _, st := range s.body {
+		st.write(b, 2)
+	}
+
+	switch {
+	case s.kind == "constructor":
+		b.WriteString("        return this;\n")
+	case s.void:
+		b.WriteString("        return;\n")
+	default:
+		fmt.Fprintf(b, "        return %s;\n", s.result.jack())
+	}
+	b.WriteString("    }\n")
+}
+
+func indent(depth int) string {
+	return strings.Repeat("    ", depth)
+}
+
+type statement interface {
+	write(b *strings.Builder, depth int)
+	eval(f *frame)
+}
+
+type expression interface {
+	jack() string
+	eval(f *frame) int16
+}
+
+// letStatement assigns to an int variable, or to an array element if index is set.
+type letStatement struct {
+	target *variable
+	index  expression
+	value  expression
+}
+
+func (s *letStatement) write(b *strings.Builder, depth int) {
+	target := s.target.name
+	if s.index != nil {
+		target = arrayIndex(s.target, s.index)
+	}
+	fmt.Fprintf(b, "%slet %s = %s;\n", indent(depth), target, s.value.jack())
+}
+
+// newStatement assigns a new array, or a new object made by the constructor, to a variable.
+type newStatement struct {
+	target      *variable
+	constructor *subroutine
+	args        []expression
+}
+
+func (s *newStatement) write(b *strings.Builder, depth int) {
+	if s.constructor == nil {
+		fmt.Fprintf(b, "%slet %s = Array.new(%d);\n", indent(depth), s.target.name, ArraySize)
+		return
+	}
+	fmt.Fprintf(b, "%slet %s = %s.%s(%s);\n", indent(depth), s.target.name, s.constructor.class.name, s.constructor.name, jackList(s.args))
+}
+
+type ifStatement struct {
+	condition expression
+	then      []statement
+	otherwise []statement
+}
+
+func (s *ifStatement) write(b *strings.Builder, depth int) {
+	fmt.Fprintf(b, "%sif (%s) {\n", indent(depth), s.condition.jack())
+	for _, st := range s.then {
+		st.write(b, depth+1)
+	}
+	if s.otherwise != nil {
+		fmt.Fprintf(b, "%s} else {\n", indent(depth))
+		for _, st := range s.otherwise {
+			st.write(b, depth+1)
+		}
+	}
+	fmt.Fprintf(b, "%s}\n", indent(depth))
+}
+
+// whileStatement runs its body count times, using a counter the body never assigns to.
+type whileStatement struct {
+	counter *variable
+	count   int
+	body    []statement
+}
+
+func (s *whileStatement) write(b *strings.Builder, depth int) {
+	fmt.Fprintf(b, "%slet %s = 0;\n", indent(depth), s.counter.name)
+	fmt.Fprintf(b, "%swhile (%s < %d) {\n", indent(depth), s.counter.name, s.count)
+	for _, st := range s.body {
+		st.write(b, depth+1)
+	}
+	fmt.Fprintf(b, "%slet %s = %s + 1;\n", indent(depth+1), s.counter.name, s.counter.name)
+	fmt.Fprintf(b, "%s}\n", indent(depth))
+}
+
+type doStatement struct {
+	call *callExpression
+}
+
+func (s *doStatement) write(b *strings.Builder, depth int) {
+	fmt.Fprintf(b, "%sdo %s;\n", indent(depth), s.call.jack())
+}
+
+type logStatement struct {
+	value expression
+}
+
+func (s *logStatement) write(b *strings.Builder, depth int) {
+	fmt.Fprintf(b, "%sdo Sys.log(%s);\n", indent(depth), s.value.jack())
+}
+
+type intConstant struct {
+	value int16
+}
+
+func (e *intConstant) jack() string {
+	return strconv.Itoa(int(e.value))
+}
+
+type variableExpression struct {
+	v *variable
+}
+
+func (e *variableExpression) jack() string {
+	return e.v.name
+}
+
+type arrayExpression struct {
+	array *variable
+	index expression
+}
+
+func (e *arrayExpression) jack() string {
+	return arrayIndex(e.array, e.index)
+}
+
+func arrayIndex(array *variable, index expression) string {
+	return fmt.Sprintf("%s[%s & %d]", array.name, index.jack(), ArraySize-1)
+}
+
+type unaryExpression struct {
+	operator byte
+	operand  expression
+}
+
+func (e *unaryExpression) jack() string {
+	return "(" + string(e.operator) + e.operand.jack() + ")"
+}
+
+// binaryExpression is always written in brackets, as Jack leaves the order of operators to the compiler.
+type binaryExpression struct {
+	operator    byte
+	left, right expression
+}
+
+func (e *binaryExpression) jack() string {
+	return "(" + e.left.jack() + " " + string(e.operator) + " " + e.right.jack() + ")"
+}
+
+// callExpression calls a subroutine of the same class, a function of another class, or a method of an object.
+type callExpression struct {
+	subroutine *subroutine
+	// object is the variable a method is called on, or nil to call it on this
+	object *variable
+	args   []expression
+	// qualified is set when the call names the class
+	qualified bool
+}
+
+func (e *callExpression) jack() string {
+	name := e.subroutine.name
+	switch {
+	case e.object != nil:
+		name = e.object.name + "." + name
+	case e.qualified:
+		name = e.subroutine.class.name + "." + name
+	}
+	return name + "(" + jackList(e.args) + ")"
+}
+
+func jackList(expressions []expression) string {
+	var list []string
+	for _, e := range expressions {
+		list = append(list, e.jack())
+	}
+	return strings.Join(list, ", ")
+}
@@ -0,0 +1,176 @@
+package jackgen
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ChelseaDH/VMTranslator/program"
+)
+
+func TestGenerate(t *testing.T) {
+	for seed := int64(0); seed < 20; seed++ {
+		p := Generate(seed)
+		again := Generate(seed)
+		if !reflect.DeepEqual(p.Files(), again.Files()) || !reflect.DeepEqual(p.Expected, again.Expected) {
+			t.Errorf("expected seed %d to generate the same program each time", seed)
+		}
+
+		files := p.Files()
+		if len(files) != 2 || !strings.Contains(files["Main.jack"], "function void main()") || !strings.Contains(files["Obj.jack"], "constructor Obj new(") {
+			t.Errorf("expected seed %d to generate classes Main and Obj, got %v", seed, files)
+		}
+	}
+}
+
+// The program hand-built by sample logs its argument doubled in a loop, then the array element it stored.
+func sample() *Program {
+	main := &class{name: mainClass}
+	total := &variable{name: "l0", kind: localVar, index: 0, typ: intType}
+	array := &variable{name: "r", kind: localVar, index: 1, typ: arrayType}
+	counter := &variable{name: "i0", kind: localVar, index: 2, typ: intType}
+	param := &variable{name: "a0", kind: argumentVar, index: 0, typ: intType}
+
+	double := &subroutine{
+		class:  main,
+		kind:   "function",
+		name:   "f0",
+		params: []*variable{param},
+		result: &binaryExpression{operator: '*', left: &variableExpression{v: param}, right: &intConstant{value: 2}},
+	}
+	mainFunction := &subroutine{
+		class:  main,
+		kind:   "function",
+		name:   "main",
+		void:   true,
+		locals: []*variable{total, array, counter},
+		body: []statement{
+			&newStatement{target: array},
+			&whileStatement{counter: counter, count: 3, body: []statement{
+				&letStatement{target: total, value: &callExpression{
+					subroutine: double,
+					qualified:  true,
+					args:       []expression{&binaryExpression{operator: '+', left: &variableExpression{v: total}, right: &intConstant{value: 1}}},
+				}},
+				&logStatement{value: &variableExpression{v: total}},
+			}},
+			&letStatement{target: array, index: &intConstant{value: 9}, value: &unaryExpression{operator: '-', operand: &variableExpression{v: total}}},
+			&logStatement{value: &arrayExpression{array: array, index: &intConstant{value: 1}}},
+		},
+	}
+	main.subroutines = []*subroutine{double, mainFunction}
+
+	return &Program{classes: []*class{main}, main: mainFunction}
+}
+
+func TestEvaluate(t *testing.T) {
+	p := sample()
+	log, err := evaluate(p.classes, p.main)
+	if err != nil {
+		t.Fatalf("did not expect an error, but %q returned", err)
+	}
+
+	expLog := []int16{2, 6, 14, -14}
+	if !reflect.DeepEqual(log, expLog) {
+		t.Errorf("expected log %v, got %v", expLog, log)
+	}
+
+	expSource := "    function void main() {\n" +
+		"        var int l0;\n" +
+		"        var Array r;\n" +
+		"        var int i0;\n" +
+		"        let r = Array.new(8);\n" +
+		"        let i0 = 0;\n" +
+		"        while (i0 < 3) {\n" +
+		"            let l0 = Main.f0((l0 + 1));\n" +
+		"            do Sys.log(l0);\n" +
+		"            let i0 = i0 + 1;\n" +
+		"        }\n" +
+		"        let r[9 & 7] = (-l0);\n" +
+		"        do Sys.log(r[1 & 7]);\n" +
+		"        return;\n" +
+		"    }\n"
+	if source := p.Files()["Main.jack"]; !strings.Contains(source, expSource) {
+		t.Errorf("expected Main.jack to contain\n%s\ngot\n%s", expSource, source)
+	}
+}
+
+type runTest struct {
+	input     string
+	limit     uint64
+	expLog    []int16
+	expErrors bool
+}
+
+var runTests = []runTest{
+	{
+		input: `function Main.main 1
+push constant 6
+push constant 7
+call Math.multiply 2
+call Sys.log 1
+pop temp 0
+push constant 3
+call Array.new 1
+pop local 0
+push local 0
+call Sys.log 1
+pop temp 0
+push constant 2
+call Memory.alloc 1
+call Sys.log 1
+pop temp 0
+push constant 20
+push constant 3
+neg
+call Math.divide 2
+call Sys.log 1
+pop temp 0
+push constant 0
+return`,
+		limit:  1000,
+		expLog: []int16{42, 2048, 2051, -6},
+	},
+	{
+		input:     "function Main.main 0\npush constant 1\npush constant 0\ncall Math.divide 2\nreturn",
+		limit:     1000,
+		expErrors: true,
+	},
+	// A program that never returns from Main.main runs out of commands
+	{
+		input:     "function Main.main 0\nlabel LOOP\npush constant 1\npop temp 0\ngoto LOOP",
+		limit:     1000,
+		expErrors: true,
+	},
+	{
+		// A miscompiled program that sets SP to 1 and then calls with two arguments fails rather than panicking
+		input:     "function Main.main 0\npush constant 0\npop pointer 1\npush constant 1\npop that 0\ncall Math.multiply 2",
+		limit:     1000,
+		expErrors: true,
+	},
+}
+
+func TestRun(t *testing.T) {
+	for _, test := range runTests {
+		lines, err := program.Read("Main.vm", strings.NewReader(test.input))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		log, err := Run(lines, test.limit)
+		if test.expErrors {
+			if err == nil {
+				t.Errorf("expected an error running %q, but none returned", test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("did not expect an error, but %q returned", err)
+			continue
+		}
+
+		if !reflect.DeepEqual(log, test.expLog) {
+			t.Errorf("expected log %v, got %v", test.expLog, log)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package jackgen
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ChelseaDH/VMTranslator/command"
+	"github.com/ChelseaDH/VMTranslator/program"
+	"github.com/ChelseaDH/VMTranslator/vm"
+)
+
+// HeapBase and HeapEnd bound the RAM the runner allocates arrays and objects from, as the Jack OS does.
+const (
+	HeapBase = 2048
+	HeapEnd  = 16384
+)
+
+// driver calls Main.main and then halts, in place of the Jack OS's Sys.init.
+const driver = `function Sys.init 0
+call Main.main 0
+pop temp 0
+label HALT
+goto HALT`
+
+// runner provides the OS functions generated programs call, so that only the compiler's output is being tested.
+type runner struct {
+	m    *vm.Machine
+	heap int16
+	log  []int16
+}
+
+// natives are the OS functions a compiled program calls, which return a value for their arguments.
+var natives = map[string]func(r *runner, args []int16) (int16, error){
+	"Sys.log": func(r *runner, args []int16) (int16, error) {
+		r.log = append(r.log, args[0])
+		return 0, nil
+	},
+	"Memory.alloc": (*runner).alloc,
+	"Array.new":    (*runner).alloc,
+	"Math.multiply": func(r *runner, args []int16) (int16, error) {
+		return args[0] * args[1], nil
+	},
+	"Math.divide": func(r *runner, args []int16) (int16, error) {
+		if args[1] == 0 {
+			return 0, errors.New("division by zero")
+		}
+		return args[0] / args[1], nil
+	},
+}
+
+func (r *runner) alloc(args []int16) (int16, error) {
+	address := r.heap
+	if args[0] < 0 || int(address)+int(args[0]) > HeapEnd {
+		return 0, fmt.Errorf("cannot allocate %d words", args[0])
+	}
+	r.heap += args[0]
+	return address, nil
+}
+
+// Run runs a compiled program on the VM interpreter until it halts, returning the values it logged.
+// It stops with an error if limit commands run first.
+func Run(lines []program.Line, limit uint64) ([]int16, error) {
+	driverLines, err := program.Read("Sys.vm", strings.NewReader(driver))
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := vm.New(append(append([]program.Line{}, lines...), driverLines...))
+	if err != nil {
+		return nil, err
+	}
+	r := &runner{m: m, heap: HeapBase}
+
+	for m.Steps < limit {
+		if m.Halted() {
+			return r.log, nil
+		}
+
+		fc, ok := m.Lines[m.PC].Command.(*command.FunctionCommand)
+		if !ok || fc.Type() != command.Call || natives[fc.Name] == nil {
+			err = m.Step()
+			if err != nil {
+				return r.log, err
+			}
+			continue
+		}
+
+		// A native call replaces its arguments on the stack with its result, as a VM function would
+		sp := int(m.RAM[vm.SP])
+		if sp < fc.Args {
+			return r.log, fmt.Errorf("%s: stack pointer %d cannot hold the %d arguments of %s", m.Lines[m.PC].Position(), sp, fc.Args, fc.Name)
+		}
+		args := append([]int16{}, m.RAM[sp-fc.Args:sp]...)
+		result, err := natives[fc.Name](r, args)
+		if err != nil {
+			return r.log, fmt.Errorf("%s: %s", m.Lines[m.PC].Position(), err)
+		}
+		m.RAM[sp-fc.Args] = result
+		m.RAM[vm.SP] = int16(sp - fc.Args + 1)
+		m.PC++
+		m.Steps++
+	}
+
+	return r.log, fmt.Errorf("stopped after %d commands", limit)
+}
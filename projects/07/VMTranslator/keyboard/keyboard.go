@@ -0,0 +1,160 @@
+// Package keyboard replays scripted key presses into the Hack keyboard register.
+//
+// A script lists the step at which each key is pressed or released, one event per line. Steps count VM commands
+// on the VM interpreter and translated programs, and instructions on the CPU emulator:
+//
+//	# Move left for a while then quit
+//	1000 press left
+//	25000 release
+//	30000 press q
+//	31000 release
+//
+// Steps must not decrease. Pressing a key while another is held replaces it, as the register holds a single key.
+// Keys are a single printable character, one of the names in Codes, or a decimal key code.
+// Blank lines and lines starting with # are ignored.
+//
+// hacktest, hacktrace, hackdbg, jackdbg and vmplay replay a script given with -keys. With a program translated by
+// VMTranslator -target go, hack.CPU or vm.Machine, replay a script by setting
+//
+//	m.KeyboardInput = func() int16 { return script.Key(m.Steps) }
+package keyboard
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Codes maps the names of the special keys of the Hack character set to their key codes.
+var Codes = map[string]int16{
+	"space":     32,
+	"newline":   128,
+	"enter":     128,
+	"backspace": 129,
+	"left":      130,
+	"up":        131,
+	"right":     132,
+	"down":      133,
+	"home":      134,
+	"end":       135,
+	"pageup":    136,
+	"pagedown":  137,
+	"insert":    138,
+	"delete":    139,
+	"esc":       140,
+	"f1":        141,
+	"f2":        142,
+	"f3":        143,
+	"f4":        144,
+	"f5":        145,
+	"f6":        146,
+	"f7":        147,
+	"f8":        148,
+	"f9":        149,
+	"f10":       150,
+	"f11":       151,
+	"f12":       152,
+}
+
+// Event sets the keyboard register to Code from Step onwards, with a Code of 0 releasing every key.
+type Event struct {
+	Step uint64
+	Code int16
+}
+
+// Script is a list of keyboard events in step order.
+type Script struct {
+	Events []Event
+}
+
+// Code returns the key code for a single printable character, a name from Codes or a decimal key code.
+func Code(key string) (int16, error) {
+	if len(key) == 1 && key[0] > ' ' && key[0] <= '~' {
+		return int16(key[0]), nil
+	}
+
+	if code, ok := Codes[strings.ToLower(key)]; ok {
+		return code, nil
+	}
+
+	code, err := strconv.ParseInt(key, 10, 16)
+	if err != nil || code <= 0 {
+		return 0, fmt.Errorf("unknown key %q", key)
+	}
+
+	return int16(code), nil
+}
+
+// Parse reads a keyboard script.
+func Parse(r io.Reader) (*Script, error) {
+	script := &Script{}
+
+	scanner := bufio.NewScanner(r)
+	number := 0
+	for scanner.Scan() {
+		number++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		step, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid step %q", number, fields[0])
+		}
+
+		if n := len(script.Events); n > 0 && step < script.Events[n-1].Step {
+			return nil, fmt.Errorf("line %d: step %d is before the previous event", number, step)
+		}
+
+		event := Event{Step: step}
+		switch {
+		case len(fields) == 3 && fields[1] == "press":
+			event.Code, err = Code(fields[2])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", number, err)
+			}
+
+		case len(fields) == 2 && fields[1] == "release":
+
+		default:
+			return nil, fmt.Errorf("line %d: expected \"<step> press <key>\" or \"<step> release\"", number)
+		}
+
+		script.Events = append(script.Events, event)
+	}
+
+	return script, scanner.Err()
+}
+
+// ReadFile reads the keyboard script in the file called name.
+func ReadFile(name string) (*Script, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	script, err := Parse(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	return script, nil
+}
+
+// Key returns the code of the key held at step, or 0 if none is.
+func (s *Script) Key(step uint64) int16 {
+	i := sort.Search(len(s.Events), func(i int) bool {
+		return s.Events[i].Step > step
+	})
+
+	if i == 0 {
+		return 0
+	}
+
+	return s.Events[i-1].Code
+}
@@ -0,0 +1,136 @@
+package keyboard
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ChelseaDH/VMTranslator/hack"
+	"github.com/ChelseaDH/VMTranslator/program"
+	"github.com/ChelseaDH/VMTranslator/vm"
+)
+
+type parseTest struct {
+	input     string
+	expectErr bool
+}
+
+var parseTests = []parseTest{
+	{input: "# comment\n\n10 press a\n20 release\n20 press LEFT\n30 press 65\n"},
+	{input: "10 press a\n5 release\n", expectErr: true},
+	{input: "10 press nothing\n", expectErr: true},
+	{input: "10 hold a\n", expectErr: true},
+	{input: "ten press a\n", expectErr: true},
+}
+
+func TestParse(t *testing.T) {
+	for _, test := range parseTests {
+		_, err := Parse(strings.NewReader(test.input))
+
+		if err == nil && test.expectErr {
+			t.Errorf("expected an error but none returned for %q", test.input)
+		}
+
+		if err != nil && !test.expectErr {
+			t.Errorf("did not expect an error, but %q returned for %q", err, test.input)
+		}
+	}
+}
+
+func TestScript_Key(t *testing.T) {
+	script, err := Parse(strings.NewReader(parseTests[0].input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for step, exp := range map[uint64]int16{0: 0, 9: 0, 10: 'a', 19: 'a', 20: 130, 29: 130, 30: 65, 1000: 65} {
+		if key := script.Key(step); key != exp {
+			t.Errorf("expected key %d at step %d but got %d", exp, step, key)
+		}
+	}
+}
+
+// presses counts key presses into R1 until there have been three, keeping the last key pressed in R0.
+const presses = `(WAIT)
+@KBD
+D=M
+@WAIT
+D;JEQ
+@R0
+M=D
+@R1
+M=M+1
+(RELEASE)
+@KBD
+D=M
+@RELEASE
+D;JNE
+@R1
+D=M
+@3
+D=D-A
+@WAIT
+D;JLT
+(END)
+@END
+0;JMP`
+
+const pressesScript = "100 press a\n200 release\n300 press b\n400 release\n500 press up\n600 release\n"
+
+func TestScript_CPU(t *testing.T) {
+	script, err := Parse(strings.NewReader(pressesScript))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := hack.Assemble(strings.NewReader(presses))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cpu := hack.NewCPU(p)
+	cpu.KeyboardInput = func() int16 { return script.Key(cpu.Cycles) }
+	err = cpu.Run(10000)
+	if err != hack.ErrHalted {
+		t.Fatalf("expected the program to halt, got %v", err)
+	}
+	if cpu.RAM[0] != Codes["up"] || cpu.RAM[1] != 3 || cpu.Cycles < 600 {
+		t.Errorf("expected three presses ending with up after step 600, got %d ending with %d after %d", cpu.RAM[1], cpu.RAM[0], cpu.Cycles)
+	}
+}
+
+// waitKey waits for a key to be pressed and keeps it in static 0.
+const waitKey = `function Sys.init 0
+push constant 24576
+pop pointer 1
+label WAIT
+push that 0
+if-goto DONE
+goto WAIT
+label DONE
+push that 0
+pop static 0
+label END
+goto END`
+
+func TestScript_VM(t *testing.T) {
+	script, err := Parse(strings.NewReader("50 press x\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines, err := program.Read("Sys.vm", strings.NewReader(waitKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := vm.New(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.KeyboardInput = func() int16 { return script.Key(m.Steps) }
+	err = m.Run(1000)
+	if err != vm.ErrHalted {
+		t.Fatalf("expected the program to halt, got %v", err)
+	}
+	if m.RAM[vm.StaticBase] != 'x' || m.Steps < 50 {
+		t.Errorf("expected x to be read after step 50, got %d after %d", m.RAM[vm.StaticBase], m.Steps)
+	}
+}
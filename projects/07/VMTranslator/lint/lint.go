@@ -0,0 +1,211 @@
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ChelseaDH/VMTranslator/cfg"
+	"github.com/ChelseaDH/VMTranslator/command"
+	"github.com/ChelseaDH/VMTranslator/program"
+)
+
+// Issue is a problem found in a VM program, reported against the line it was found on.
+type Issue struct {
+	Line    program.Line
+	Message string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s: %s", i.Line.Position(), i.Message)
+}
+
+var osClasses = []string{"Array", "Keyboard", "Math", "Memory", "Output", "Screen", "String", "Sys"}
+
+// IsOSFunction reports whether name belongs to one of the Jack OS classes,
+// which are usually linked in from the course tools rather than being part of the program.
+func IsOSFunction(name string) bool {
+	for _, class := range osClasses {
+		if strings.HasPrefix(name, class+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Lint checks lines for jumps to undeclared labels, jumps across function boundaries, calls to undeclared functions,
+// functions declared more than once, functions that can run past their end without returning and unbalanced stack use.
+// Calls to functions for which isExternal returns true are assumed to be defined elsewhere.
+func Lint(lines []program.Line, isExternal func(name string) bool) []Issue {
+	var issues []Issue
+	report := func(line program.Line, format string, a ...interface{}) {
+		issues = append(issues, Issue{Line: line, Message: fmt.Sprintf(format, a...)})
+	}
+
+	functions := program.Functions(lines)
+	graphs := make([]*cfg.Graph, len(functions))
+	declared := make(map[string]program.Line)
+	labelOwners := make(map[string]string)
+
+	for i, f := range functions {
+		graphs[i] = cfg.Build(f)
+		if f.Name == "" {
+			continue
+		}
+
+		if first, ok := declared[f.Name]; ok {
+			report(f.Declaration, "function %s already declared at %s", f.Name, first.Position())
+		} else {
+			declared[f.Name] = f.Declaration
+		}
+
+		for _, line := range f.Body {
+			if line.Command.Type() == command.Label {
+				label := cfg.LabelKey(line.Command.(*command.BranchingCommand).Label)
+				if _, ok := labelOwners[label]; !ok {
+					labelOwners[label] = f.Name
+				}
+			}
+		}
+	}
+
+	for _, g := range graphs {
+		f := g.Function
+		seen := make(map[string]program.Line)
+
+		for _, line := range f.Body {
+			switch c := line.Command.(type) {
+			case *command.BranchingCommand:
+				label := cfg.LabelKey(c.Label)
+				if c.Type() == command.Label {
+					if first, ok := seen[label]; ok {
+						report(line, "label %s already declared at %s", c.Label, first.Position())
+					}
+					seen[label] = line
+					continue
+				}
+
+				if _, ok := g.Labels[label]; ok {
+					continue
+				}
+
+				if owner, ok := labelOwners[label]; ok {
+					report(line, "%s to label %s crosses into function %s", c.Type(), c.Label, owner)
+				} else {
+					report(line, "%s to undeclared label %s", c.Type(), c.Label)
+				}
+
+			case *command.FunctionCommand:
+				if c.Type() != command.Call {
+					continue
+				}
+
+				if _, ok := declared[c.Name]; !ok && (isExternal == nil || !isExternal(c.Name)) {
+					report(line, "call to undeclared function %s", c.Name)
+				}
+			}
+		}
+
+		if f.Name == "" {
+			continue
+		}
+
+		reachable := g.Reachable()
+		for _, b := range g.Blocks {
+			if b.FallsOff && reachable[b.Index] {
+				report(b.Lines[len(b.Lines)-1], "function %s can reach its end without returning", f.Name)
+			}
+		}
+
+		issues = append(issues, checkStack(g)...)
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Line.File != issues[j].Line.File {
+			return issues[i].Line.File < issues[j].Line.File
+		}
+		return issues[i].Line.Number < issues[j].Line.Number
+	})
+
+	return issues
+}
+
+// stackEffect returns how many values c needs on the working stack and the change in stack depth it causes.
+func stackEffect(c command.Command) (needs int, effect int) {
+	switch c.Type() {
+	case command.Add, command.Sub, command.Eq, command.Gt, command.Lt, command.And, command.Or:
+		return 2, -1
+	case command.Neg, command.Not:
+		return 1, 0
+	case command.Push:
+		return 0, 1
+	case command.Pop, command.IfGoto:
+		return 1, -1
+	case command.Call:
+		args := c.(*command.FunctionCommand).Args
+		return args, 1 - args
+	case command.Return:
+		return 1, -1
+	default:
+		return 0, 0
+	}
+}
+
+// checkStack follows the working stack depth through each reachable block of g, starting empty at the top of the function.
+// The depth must never drop below zero, must agree wherever control flow merges and must be exactly one at each return.
+func checkStack(g *cfg.Graph) []Issue {
+	var issues []Issue
+	if len(g.Blocks) == 0 {
+		return nil
+	}
+
+	entry := make([]int, len(g.Blocks))
+	visited := make([]bool, len(g.Blocks))
+	visited[0] = true
+	work := []int{0}
+
+	for len(work) > 0 {
+		b := g.Blocks[work[0]]
+		work = work[1:]
+
+		depth := entry[b.Index]
+		for _, line := range b.Lines {
+			needs, effect := stackEffect(line.Command)
+			if depth < needs {
+				issues = append(issues, Issue{
+					Line:    line,
+					Message: fmt.Sprintf("%s needs %d values but the working stack holds %d", line.Command, needs, depth),
+				})
+				depth = needs
+			}
+
+			if line.Command.Type() == command.Return && depth != 1 {
+				issues = append(issues, Issue{
+					Line:    line,
+					Message: fmt.Sprintf("return leaves %d values on the working stack, expected 1", depth),
+				})
+			}
+			depth += effect
+		}
+
+		for _, s := range b.Successors {
+			if !visited[s] {
+				visited[s] = true
+				entry[s] = depth
+				work = append(work, s)
+				continue
+			}
+
+			if entry[s] != depth {
+				target := g.Blocks[s]
+				issues = append(issues, Issue{
+					Line:    target.Lines[0],
+					Message: fmt.Sprintf("working stack depth differs between paths reaching label %s: %d and %d", target.Label(), entry[s], depth),
+				})
+			}
+		}
+	}
+
+	return issues
+}
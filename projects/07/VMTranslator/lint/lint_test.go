@@ -0,0 +1,80 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ChelseaDH/VMTranslator/program"
+)
+
+type lintTest struct {
+	input     string
+	expIssues []string
+}
+
+var lintTests = []lintTest{
+	{
+		input: `function Main.main 1
+push constant 1
+pop local 0
+label LOOP
+push local 0
+if-goto LOOP
+call Math.multiply 2
+call Main.missing 0
+return`,
+		expIssues: []string{
+			"test.vm:7: call Math.multiply 2 needs 2 values but the working stack holds 0",
+			"test.vm:8: call to undeclared function Main.missing",
+			"test.vm:9: return leaves 2 values on the working stack, expected 1",
+		},
+	},
+	{
+		input: `function Main.a 0
+label END
+push constant 0
+return
+function Main.b 0
+goto END
+function Main.a 0
+push constant 1
+if-goto NOWHERE
+label NOWHERE_ELSE`,
+		expIssues: []string{
+			"test.vm:6: goto to label END crosses into function Main.a",
+			"test.vm:7: function Main.a already declared at test.vm:1",
+			"test.vm:9: if-goto to undeclared label NOWHERE",
+			"test.vm:10: function Main.a can reach its end without returning",
+		},
+	},
+	{
+		input: `function Main.c 0
+push argument 0
+if-goto TRUE
+push constant 1
+label TRUE
+push constant 0
+return`,
+		expIssues: []string{
+			"test.vm:5: working stack depth differs between paths reaching label TRUE: 0 and 1",
+		},
+	},
+}
+
+func TestLint(t *testing.T) {
+	for _, test := range lintTests {
+		lines, err := program.Read("test.vm", strings.NewReader(test.input))
+		if err != nil {
+			t.Fatalf("unexpected error %q", err)
+		}
+
+		var issues []string
+		for _, issue := range Lint(lines, IsOSFunction) {
+			issues = append(issues, issue.String())
+		}
+
+		if strings.Join(issues, "\n") != strings.Join(test.expIssues, "\n") {
+			t.Errorf("expected issues\n%s\ngot\n%s", strings.Join(test.expIssues, "\n"), strings.Join(issues, "\n"))
+		}
+	}
+}
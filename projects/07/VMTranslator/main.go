@@ -2,47 +2,118 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path"
+	"runtime"
 	"strings"
+	"sync"
 
-	"github.com/ChelseaDH/VMTranslator/parser"
+	"github.com/ChelseaDH/VMTranslator/command"
+	"github.com/ChelseaDH/VMTranslator/ctranslator"
+	"github.com/ChelseaDH/VMTranslator/gotranslator"
+	"github.com/ChelseaDH/VMTranslator/program"
 	"github.com/ChelseaDH/VMTranslator/translator"
+	"github.com/ChelseaDH/VMTranslator/usage"
 )
 
+// backend is implemented by each output target the VM commands can be translated to.
+type backend interface {
+	SetNamespace(namespace string)
+	Initialise() error
+	Translate(c command.Command) error
+	Terminate()
+}
+
+const outputBufferSize = 64 * 1024
+
+var targetExtensions = map[string]string{
+	"asm": ".asm",
+	"c":   ".c",
+	"go":  ".go",
+}
+
+// newBackend returns the backend for target, translating the program called name.
+func newBackend(target string, output io.Writer, name string, goPackage string, safe bool) backend {
+	switch target {
+	case "c":
+		return &ctranslator.Translator{Output: output}
+	case "go":
+		return &gotranslator.Translator{Output: output, Package: goPackage, Program: name}
+	default:
+		return &translator.Translator{Output: output, Safe: safe}
+	}
+}
+
 func main() {
-	args := os.Args
-	if len(args) != 2 {
+	target := flag.String("target", "asm", "output target, one of asm, c or go")
+	goPackage := flag.String("package", "", "package name of the generated Go source, defaults to the input name")
+	safe := flag.Bool("safe", false, "check stack and pointer bounds at runtime, halting with an error code in R15 (asm target only)")
+	report := flag.Bool("report", false, "print the static, stack frame and ROM usage of the program")
+	workers := flag.Int("workers", runtime.NumCPU(), "number of files to translate at once in directory mode")
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) != 1 {
 		log.Fatal("Incorrect number of command line arguments provided")
 	}
 
-	name := args[1]
+	ext, ok := targetExtensions[*target]
+	if !ok {
+		log.Fatalf("Unknown target %s", *target)
+	}
+
+	if *workers < 1 {
+		log.Fatal("At least one worker is required")
+	}
+
+	if *safe && *target != "asm" {
+		log.Fatal("Runtime safety checks are only supported by the asm target")
+	}
+
+	name := args[0]
 	fileInfo, err := os.Stat(name)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	// The assembly is measured as it is written so its size and variables can be checked once translation is done
+	var assembly *usage.Assembly
+	if *target == "asm" {
+		assembly = &usage.Assembly{}
+	}
+
+	var lines []program.Line
+	var output *bufio.Writer
+	var outputName string
+
 	switch mode := fileInfo.Mode(); {
 	case mode.IsRegular():
-		outputFile, err := os.OpenFile(strings.Replace(name, ".vm", ".asm", 1), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		outputName = strings.Replace(name, ".vm", ext, 1)
+		outputFile, err := os.OpenFile(outputName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 		if err != nil {
 			log.Fatal(err)
 		}
 		defer outputFile.Close()
 
+		output = newOutput(outputFile, assembly)
 		namespace := strings.Replace(path.Base(name), ".vm", "", 1)
-		t := translator.Translator{
-			Namespace: namespace,
-			Output:    outputFile,
-		}
+		t := newBackend(*target, output, namespace, *goPackage, *safe)
+		t.SetNamespace(namespace)
 
-		translateFile(name, t)
+		lines, err = translateFile(name, t)
+		if err != nil {
+			log.Fatal(err)
+		}
 		t.Terminate()
 
 	case mode.IsDir():
-		outputFile, err := os.OpenFile(path.Join(name, fmt.Sprintf("%s.asm", path.Base(name))), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		outputName = path.Join(name, fmt.Sprintf("%s%s", path.Base(name), ext))
+		outputFile, err := os.OpenFile(outputName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -53,48 +124,168 @@ func main() {
 			log.Fatal(err)
 		}
 
-		t := translator.Translator{
-			Output: outputFile,
+		var paths []string
+		for _, file := range files {
+			if path.Ext(file.Name()) == ".vm" {
+				paths = append(paths, path.Join(name, file.Name()))
+			}
+		}
+
+		results := translateFiles(paths, *workers, *target, *safe)
+		failed := false
+		for _, result := range results {
+			if result.err != nil {
+				log.Print(result.err)
+				failed = true
+			}
 		}
+		if failed {
+			os.Exit(1)
+		}
+
+		output = newOutput(outputFile, assembly)
+		t := newBackend(*target, output, path.Base(name), *goPackage, *safe)
 		err = t.Initialise()
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		for _, file := range files {
-			if path.Ext(file.Name()) == ".vm" {
-				t.Namespace = strings.Replace(file.Name(), ".vm", "", 1)
-				translateFile(path.Join(name, file.Name()), t)
+		// Results are written in file name order, whichever order the workers finished in
+		for _, result := range results {
+			lines = append(lines, result.lines...)
+			if result.translated != nil {
+				_, err = output.Write(result.translated.Bytes())
+				if err != nil {
+					log.Fatal(err)
+				}
+				continue
+			}
+
+			t.SetNamespace(result.namespace)
+			for _, line := range result.lines {
+				err = t.Translate(line.Command)
+				if err != nil {
+					log.Fatalf("%s: %s", line.Position(), err)
+				}
 			}
 		}
 		t.Terminate()
 
 	default:
-		log.Fatal("Second command line argument must be a .vm file or directory containing one or more .vm files")
+		log.Fatal("Command line argument must be a .vm file or directory containing one or more .vm files")
 	}
-}
 
-func translateFile(path string, translator translator.Translator) {
-	inputFile, err := os.Open(path)
+	err = output.Flush()
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer inputFile.Close()
 
-	scanner := bufio.NewScanner(inputFile)
-	for scanner.Scan() {
-		command, err := parser.Parse(scanner.Text())
+	memory := usage.Analyse(lines)
+	if assembly != nil {
+		memory.AddAssembly(assembly)
+	}
+
+	if *report {
+		err = memory.Write(os.Stdout)
 		if err != nil {
 			log.Fatal(err)
 		}
+	}
+
+	// The assembly is only measured once written, so a program that does not fit is removed rather than left behind
+	err = memory.Check()
+	if err != nil {
+		os.Remove(outputName)
+		log.Fatal(err)
+	}
+}
+
+// newOutput buffers writes to file, measuring them with assembly too if it is not nil.
+func newOutput(file io.Writer, assembly *usage.Assembly) *bufio.Writer {
+	if assembly != nil {
+		file = io.MultiWriter(file, assembly)
+	}
+
+	return bufio.NewWriterSize(file, outputBufferSize)
+}
+
+// translateFile translates every command in the file at path, returning the lines it read.
+func translateFile(path string, translator backend) ([]program.Line, error) {
+	inputFile, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer inputFile.Close()
+
+	lines, err := program.Read(path, inputFile)
+	if err != nil {
+		return nil, err
+	}
 
-		if command == nil {
-			continue
+	for _, line := range lines {
+		err = translator.Translate(line.Command)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", line.Position(), err)
 		}
+	}
+
+	return lines, nil
+}
 
-		err = translator.Translate(command)
+// fileResult is the outcome of reading, and if possible translating, a single file in directory mode.
+type fileResult struct {
+	namespace  string
+	lines      []program.Line
+	translated *bytes.Buffer
+	err        error
+}
+
+// translateFiles reads the files at paths using a pool of workers, returning the results in the same order as paths.
+// The assembly for each file does not depend on the others, so for the asm target the workers translate
+// each file into its own buffer as well. The c and go targets build a single program from every file,
+// so their files are only parsed concurrently and are left to be translated in order.
+func translateFiles(paths []string, workers int, target string, safe bool) []fileResult {
+	results := make([]fileResult, len(paths))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = readFile(paths[i], target, safe)
+			}
+		}()
+	}
+
+	for i := range paths {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+func readFile(filePath string, target string, safe bool) fileResult {
+	result := fileResult{namespace: strings.Replace(path.Base(filePath), ".vm", "", 1)}
+
+	if target != "asm" {
+		inputFile, err := os.Open(filePath)
 		if err != nil {
-			log.Fatal(err)
+			result.err = err
+			return result
 		}
+		defer inputFile.Close()
+
+		result.lines, result.err = program.Read(filePath, inputFile)
+		return result
 	}
+
+	result.translated = &bytes.Buffer{}
+	t := &translator.Translator{Output: result.translated, Safe: safe}
+	t.SetNamespace(result.namespace)
+	result.lines, result.err = translateFile(filePath, t)
+	return result
 }
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/ChelseaDH/VMTranslator/translator"
+)
+
+const benchmarkDir = "testfiles/benchmark"
+
+// BenchmarkTranslateDirectory measures the whole pipeline, reading and parsing each file and writing the assembly.
+func BenchmarkTranslateDirectory(b *testing.B) {
+	files, err := os.ReadDir(benchmarkDir)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for i := 0; i < b.N; i++ {
+		output := bufio.NewWriter(io.Discard)
+		t := &translator.Translator{Output: output}
+		err = t.Initialise()
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		for _, file := range files {
+			t.SetNamespace(strings.TrimSuffix(file.Name(), ".vm"))
+			_, err = translateFile(path.Join(benchmarkDir, file.Name()), t)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+		t.Terminate()
+
+		err = output.Flush()
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestTranslateFiles(t *testing.T) {
+	files, err := os.ReadDir(benchmarkDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+	var sequential bytes.Buffer
+	tr := &translator.Translator{Output: &sequential}
+	for _, file := range files {
+		paths = append(paths, path.Join(benchmarkDir, file.Name()))
+		tr.SetNamespace(strings.TrimSuffix(file.Name(), ".vm"))
+		_, err = translateFile(path.Join(benchmarkDir, file.Name()), tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, workers := range []int{1, 4, 16} {
+		var concurrent bytes.Buffer
+		for i, result := range translateFiles(paths, workers, "asm", false) {
+			if result.err != nil {
+				t.Fatalf("did not expect an error, but %q returned for %s", result.err, paths[i])
+			}
+			concurrent.Write(result.translated.Bytes())
+		}
+
+		if !bytes.Equal(concurrent.Bytes(), sequential.Bytes()) {
+			t.Errorf("output with %d workers differs from translating the files in order", workers)
+		}
+	}
+}
+
+func TestTranslateFiles_Errors(t *testing.T) {
+	dir := t.TempDir()
+	for name, contents := range map[string]string{"A.vm": "push constant 1\n", "B.vm": "push nowhere 1\n", "C.vm": "jump\n"} {
+		err := os.WriteFile(path.Join(dir, name), []byte(contents), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, target := range []string{"asm", "c"} {
+		results := translateFiles([]string{path.Join(dir, "A.vm"), path.Join(dir, "B.vm"), path.Join(dir, "C.vm")}, 2, target, false)
+		if results[0].err != nil {
+			t.Errorf("did not expect an error, but %q returned for A.vm", results[0].err)
+		}
+		for _, result := range results[1:] {
+			if result.err == nil {
+				t.Errorf("expected an error but none returned for %s", result.namespace)
+			}
+		}
+	}
+}
+
+func TestNewBackend_GoPackage(t *testing.T) {
+	files, err := os.ReadDir(benchmarkDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// In directory mode the package is named after the directory, not the last file translated
+	for goPackage, expPackage := range map[string]string{"": "benchmark", "life": "life"} {
+		var output bytes.Buffer
+		tr := newBackend("go", &output, path.Base(benchmarkDir), goPackage, false)
+		err = tr.Initialise()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, file := range files {
+			tr.SetNamespace(strings.TrimSuffix(file.Name(), ".vm"))
+			_, err = translateFile(path.Join(benchmarkDir, file.Name()), tr)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		tr.Terminate()
+
+		if !strings.Contains(output.String(), "\npackage "+expPackage+"\n") {
+			t.Errorf("expected package %s with -package %q", expPackage, goPackage)
+		}
+	}
+}
+
+// TestNewBackend_GoBuild checks that a generated package passes go vet and runs, as it must to be embedded in a program
+// or a go test harness.
+func TestNewBackend_GoBuild(t *testing.T) {
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go is not installed")
+	}
+	files, err := os.ReadDir(benchmarkDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var output bytes.Buffer
+	tr := newBackend("go", &output, path.Base(benchmarkDir), "", false)
+	err = tr.Initialise()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		tr.SetNamespace(strings.TrimSuffix(file.Name(), ".vm"))
+		_, err = translateFile(path.Join(benchmarkDir, file.Name()), tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	tr.Terminate()
+
+	dir := t.TempDir()
+	err = os.Mkdir(path.Join(dir, "benchmark"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, contents := range map[string]string{
+		"go.mod":                 "module example.com/harness\n\ngo 1.17\n",
+		"benchmark/benchmark.go": output.String(),
+		"main.go": `package main
+
+import (
+	"fmt"
+
+	"example.com/harness/benchmark"
+)
+
+func main() {
+	m := benchmark.Machine{Limit: 100000}
+	fmt.Println(m.Run())
+}
+`,
+	} {
+		err = os.WriteFile(path.Join(dir, name), []byte(contents), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, args := range [][]string{{"vet", "./..."}, {"run", "."}} {
+		cmd := exec.Command(goTool, args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("go %s failed: %s\n%s", strings.Join(args, " "), err, out)
+		}
+		// The game of life runs forever, so stops at the step limit
+		if args[0] == "run" && string(out) != "step limit exceeded\n" {
+			t.Errorf("expected the program to stop at the step limit, got %q", out)
+		}
+	}
+}
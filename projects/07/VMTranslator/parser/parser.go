@@ -2,23 +2,23 @@ package parser
 
 import (
 	"fmt"
-	"log"
-	"regexp"
 	"strconv"
 	"strings"
 
 	"github.com/ChelseaDH/VMTranslator/command"
 )
 
+// maxParts is one more than the most words a command can have, so that commands with too many arguments are caught
+const maxParts = 4
+
 func Parse(line string) (command.Command, error) {
-	comment, err := regexp.Compile(`//.*`)
-	if err != nil {
-		log.Fatal(err)
+	var buf [maxParts]string
+	parts := split(line, buf[:0])
+	commandName := ""
+	if len(parts) > 0 {
+		commandName = parts[0]
 	}
 
-	parts := strings.Split(strings.TrimSpace(comment.ReplaceAllString(line, "")), " ")
-	commandName := parts[0]
-
 	switch commandName {
 	case "":
 		return nil, nil
@@ -91,6 +91,38 @@ func Parse(line string) (command.Command, error) {
 	}
 }
 
+// split appends the space separated words of line, up to the start of any comment, to parts.
+// At most maxParts words are returned, any after that are dropped.
+func split(line string, parts []string) []string {
+	if i := strings.Index(line, "//"); i != -1 {
+		line = line[:i]
+	}
+
+	for len(line) > 0 && len(parts) < maxParts {
+		start := 0
+		for start < len(line) && isSpace(line[start]) {
+			start++
+		}
+		if start == len(line) {
+			break
+		}
+
+		end := start
+		for end < len(line) && !isSpace(line[end]) {
+			end++
+		}
+
+		parts = append(parts, line[start:end])
+		line = line[end:]
+	}
+
+	return parts
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\v' || c == '\f'
+}
+
 func checkArgumentValidity(segment command.Segment, index int, commandName string) error {
 	if index < 0 {
 		return fmt.Errorf("the index argument of a %s command must be greater than or equal to 0", commandName)
@@ -0,0 +1,98 @@
+package parser
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type parseTest struct {
+	input string
+	// expOutput is the parsed command written back out, empty if no command is expected
+	expOutput string
+	expectErr bool
+}
+
+var parseTests = []parseTest{
+	{input: "push constant 7", expOutput: "push constant 7"},
+	{input: "pop local 2 // the count", expOutput: "pop local 2"},
+	{input: "add// no space before the comment", expOutput: "add"},
+	{input: "\tpush\t argument   1  ", expOutput: "push argument 1"},
+	{input: "call Main.f 2\r\n", expOutput: "call Main.f 2"},
+	{input: "if-goto LOOP\r", expOutput: "if-goto LOOP"},
+	{input: "// a comment on its own", expOutput: ""},
+	{input: "   ", expOutput: ""},
+	{input: "", expOutput: ""},
+	{input: "push constant 1 2", expectErr: true},
+	{input: "push constant 1 2 3 4 5", expectErr: true},
+	{input: "function Main.f 0 extra", expectErr: true},
+	{input: "goto A B", expectErr: true},
+	{input: "push constant", expectErr: true},
+	{input: "push temp 8", expectErr: true},
+	{input: "jump", expectErr: true},
+}
+
+func TestParse(t *testing.T) {
+	for _, test := range parseTests {
+		c, err := Parse(test.input)
+
+		if err == nil && test.expectErr {
+			t.Errorf("expected an error but none returned for %q", test.input)
+		}
+
+		if err != nil && !test.expectErr {
+			t.Errorf("did not expect an error, but %q returned for %q", err, test.input)
+		}
+
+		if err != nil {
+			continue
+		}
+
+		output := ""
+		if c != nil {
+			output = c.String()
+		}
+		if output != test.expOutput {
+			t.Errorf("expected %q but got %q for %q", test.expOutput, output, test.input)
+		}
+	}
+}
+
+// benchmarkLines reads the compiled Jack OS and GameOfLife program used by the benchmarks.
+func benchmarkLines(b *testing.B) []string {
+	files, err := filepath.Glob("../testfiles/benchmark/*.vm")
+	if err != nil || len(files) == 0 {
+		b.Fatalf("no benchmark programs found: %v", err)
+	}
+
+	var lines []string
+	for _, name := range files {
+		file, err := os.Open(name)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		file.Close()
+	}
+
+	return lines
+}
+
+func BenchmarkParse(b *testing.B) {
+	lines := benchmarkLines(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, line := range lines {
+			_, err := Parse(line)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
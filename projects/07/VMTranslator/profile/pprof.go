@@ -0,0 +1,154 @@
+package profile
+
+import (
+	"compress/gzip"
+	"io"
+)
+
+// Field numbers of the pprof profile.proto messages used here.
+const (
+	profileSampleType = 1
+	profileSample     = 2
+	profileLocation   = 4
+	profileFunction   = 5
+	profileStrings    = 6
+	profilePeriodType = 11
+	profilePeriod     = 12
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocationID = 1
+	sampleValue      = 2
+
+	locationID   = 1
+	locationLine = 4
+
+	lineFunctionID = 1
+
+	functionID         = 1
+	functionName       = 2
+	functionSystemName = 3
+)
+
+// protobuf builds a protocol buffer message, which is all pprof needs of the encoding.
+type protobuf struct {
+	data []byte
+}
+
+func (p *protobuf) varint(v uint64) {
+	for v >= 0x80 {
+		p.data = append(p.data, byte(v)|0x80)
+		v >>= 7
+	}
+	p.data = append(p.data, byte(v))
+}
+
+// uint64 writes a varint field.
+func (p *protobuf) uint64(field int, v uint64) {
+	p.varint(uint64(field) << 3)
+	p.varint(v)
+}
+
+// bytes writes a length delimited field, used for strings, embedded messages and packed repeated fields.
+func (p *protobuf) bytes(field int, b []byte) {
+	p.varint(uint64(field)<<3 | 2)
+	p.varint(uint64(len(b)))
+	p.data = append(p.data, b...)
+}
+
+func (p *protobuf) packed(field int, values []uint64) {
+	var packed protobuf
+	for _, v := range values {
+		packed.varint(v)
+	}
+	p.bytes(field, packed.data)
+}
+
+// WritePprof writes the call tree as a gzipped profile that go tool pprof can read,
+// with one sample for each distinct call stack counting the instructions executed at its top.
+func (pr *Profiler) WritePprof(w io.Writer) error {
+	strings := []string{""}
+	stringIndex := map[string]uint64{"": 0}
+	str := func(s string) uint64 {
+		if i, ok := stringIndex[s]; ok {
+			return i
+		}
+		stringIndex[s] = uint64(len(strings))
+		strings = append(strings, s)
+		return stringIndex[s]
+	}
+
+	var profile protobuf
+	valueType := func(field int, typ string, unit string) {
+		var vt protobuf
+		vt.uint64(valueTypeType, str(typ))
+		vt.uint64(valueTypeUnit, str(unit))
+		profile.bytes(field, vt.data)
+	}
+	valueType(profileSampleType, "instructions", "count")
+
+	// Each function has a single location, sharing its id
+	ids := make(map[string]uint64)
+	var functions []string
+	id := func(function string) uint64 {
+		if i, ok := ids[function]; ok {
+			return i
+		}
+		functions = append(functions, function)
+		ids[function] = uint64(len(functions))
+		return ids[function]
+	}
+
+	var walk func(n *Node)
+	walk = func(n *Node) {
+		if n.Self > 0 {
+			var stack []uint64
+			for caller := n; caller != nil; caller = caller.Parent {
+				stack = append(stack, id(caller.Function))
+			}
+
+			var sample protobuf
+			sample.packed(sampleLocationID, stack)
+			sample.packed(sampleValue, []uint64{n.Self})
+			profile.bytes(profileSample, sample.data)
+		}
+
+		for _, child := range n.SortedChildren() {
+			walk(child)
+		}
+	}
+	walk(pr.Root)
+
+	for i, function := range functions {
+		var line protobuf
+		line.uint64(lineFunctionID, uint64(i+1))
+
+		var location protobuf
+		location.uint64(locationID, uint64(i+1))
+		location.bytes(locationLine, line.data)
+		profile.bytes(profileLocation, location.data)
+
+		var f protobuf
+		f.uint64(functionID, uint64(i+1))
+		f.uint64(functionName, str(function))
+		f.uint64(functionSystemName, str(function))
+		profile.bytes(profileFunction, f.data)
+	}
+
+	valueType(profilePeriodType, "instructions", "count")
+	profile.uint64(profilePeriod, 1)
+
+	// The string table is written last as the other messages add to it
+	for _, s := range strings {
+		profile.bytes(profileStrings, []byte(s))
+	}
+
+	gz := gzip.NewWriter(w)
+	_, err := gz.Write(profile.data)
+	if err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
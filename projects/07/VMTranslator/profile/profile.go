@@ -0,0 +1,244 @@
+// Package profile counts the instructions a Hack program executes and attributes them to the VM functions,
+// and so the Jack subroutines, they were translated from.
+package profile
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/ChelseaDH/VMTranslator/hack"
+)
+
+// Bootstrap names the code before the first function label, which sets up the stack and calls Sys.init.
+const Bootstrap = "(bootstrap)"
+
+// setLCL is the M=D instruction that, after @LCL, ends the translator's call sequence by setting LCL = SP
+// just before it loads the function's address and jumps to it.
+const setLCL = 0b1110001100001000
+
+// Node is a function in the call tree, reached through the calls made by its ancestors.
+type Node struct {
+	Function string
+	Parent   *Node
+	Children map[string]*Node
+	// Self counts the instructions executed in the function itself, and Total those of its callees as well.
+	Self  uint64
+	Total uint64
+}
+
+func newNode(function string, parent *Node) *Node {
+	return &Node{Function: function, Parent: parent, Children: make(map[string]*Node)}
+}
+
+// SortedChildren returns the children of n with the most expensive first.
+func (n *Node) SortedChildren() []*Node {
+	var children []*Node
+	for _, child := range n.Children {
+		children = append(children, child)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		if children[i].Total != children[j].Total {
+			return children[i].Total > children[j].Total
+		}
+		return children[i].Function < children[j].Function
+	})
+
+	return children
+}
+
+// frame is a call in progress, which ends once the program jumps to the caller's return address.
+type frame struct {
+	node *Node
+	ret  uint16
+}
+
+// Profiler runs a program on a CPU, counting the instructions executed at each ROM address
+// and building a call tree from the jumps to function labels the translator writes.
+type Profiler struct {
+	CPU *hack.CPU
+	// Counts holds the number of times the instruction at each ROM address has run.
+	Counts []uint64
+	// Root is the call tree, starting from the bootstrap code.
+	Root *Node
+
+	// functions holds the function each ROM address belongs to, and entries the addresses functions start at
+	functions []string
+	entries   map[uint16]string
+	stack     []frame
+}
+
+// New returns a Profiler for p, which must have been assembled from source so that its labels are known.
+//
+// The translator writes a label named after each VM function where its code starts, and labels containing $
+// for branches and return addresses within it. Each instruction belongs to the closest function label before it.
+func New(p *hack.Program) *Profiler {
+	pr := &Profiler{
+		CPU:       hack.NewCPU(p),
+		Counts:    make([]uint64, len(p.ROM)),
+		functions: make([]string, len(p.ROM)),
+		entries:   make(map[uint16]string),
+	}
+
+	for name, address := range p.Labels {
+		if strings.Contains(name, "$") {
+			continue
+		}
+		// Several labels at the same address are ordered by name so the result is always the same
+		if existing, ok := pr.entries[address]; !ok || name < existing {
+			pr.entries[address] = name
+		}
+	}
+
+	function := Bootstrap
+	for address := range pr.functions {
+		if name, ok := pr.entries[uint16(address)]; ok {
+			function = name
+		}
+		pr.functions[address] = function
+	}
+
+	pr.Root = newNode(Bootstrap, nil)
+	pr.stack = []frame{{node: pr.Root}}
+	return pr
+}
+
+// Function returns the name of the function the instruction at address belongs to.
+func (pr *Profiler) Function(address uint16) string {
+	if int(address) >= len(pr.functions) {
+		return ""
+	}
+	return pr.functions[address]
+}
+
+// Step executes a single instruction, counting it against the function being run.
+func (pr *Profiler) Step() error {
+	pc := pr.CPU.PC
+	_, err := pr.CPU.Step()
+	if err != nil {
+		return err
+	}
+
+	pr.Counts[pc]++
+	current := pr.stack[len(pr.stack)-1]
+	current.node.Self++
+
+	// Only jumps can call or return, including a call to a function that starts straight after it
+	word := pr.CPU.ROM[pc]
+	if word&0x8000 == 0 || word&0x7 == 0 {
+		return nil
+	}
+	next := pr.CPU.PC
+
+	// Calls jump to a function label just after setting LCL, so are told apart from returns even when a return
+	// address is also the start of the next function, and from a loop at the very start of a function, whose
+	// label shares the function's address.
+	name, ok := pr.entries[next]
+	if !ok || !pr.calls(pc, next) {
+		// A return jumps back to the instruction after the caller's jump through the address saved in its
+		// frame, possibly unwinding frames that never returned normally. Branches load their label instead,
+		// which may be the same address when the function after a call starts with a loop.
+		if pc > 0 && pr.CPU.ROM[pc-1]&0x8000 == 0 {
+			return nil
+		}
+		for i := len(pr.stack) - 1; i > 0; i-- {
+			if pr.stack[i].ret == next {
+				pr.stack = pr.stack[:i]
+				break
+			}
+		}
+		return nil
+	}
+
+	child, ok := current.node.Children[name]
+	if !ok {
+		child = newNode(name, current.node)
+		current.node.Children[name] = child
+	}
+	pr.stack = append(pr.stack, frame{node: child, ret: pc + 1})
+
+	return nil
+}
+
+// calls reports whether the jump at pc ends the translator's call sequence for the function at next:
+// @LCL, M=D, @next, 0;JMP.
+func (pr *Profiler) calls(pc uint16, next uint16) bool {
+	rom := pr.CPU.ROM
+	return pc >= 3 && rom[pc-1] == next && rom[pc-2] == setLCL && rom[pc-3] == 1
+}
+
+// Run steps until the program halts, returning hack.ErrHalted, or until limit instructions have run if limit is not zero.
+func (pr *Profiler) Run(limit uint64) error {
+	for limit == 0 || pr.CPU.Cycles < limit {
+		if pr.CPU.Halted() {
+			return hack.ErrHalted
+		}
+
+		err := pr.Step()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Finish totals the instructions run beneath each node of the call tree. It must be called before reporting.
+func (pr *Profiler) Finish() {
+	total(pr.Root)
+}
+
+func total(n *Node) uint64 {
+	n.Total = n.Self
+	for _, child := range n.Children {
+		n.Total += total(child)
+	}
+	return n.Total
+}
+
+// Entry is the instructions attributed to a single function.
+type Entry struct {
+	Function string
+	Self     uint64
+	// Total counts the instructions run by the function or anything it called,
+	// counting each instruction once even if the function is on the stack more than once.
+	Total uint64
+}
+
+// Flat returns the cost of each function, most expensive first.
+func (pr *Profiler) Flat() []Entry {
+	entries := make(map[string]*Entry)
+	var walk func(n *Node, onStack map[string]bool)
+	walk = func(n *Node, onStack map[string]bool) {
+		e, ok := entries[n.Function]
+		if !ok {
+			e = &Entry{Function: n.Function}
+			entries[n.Function] = e
+		}
+		e.Self += n.Self
+
+		// Recursive calls are already counted by the outermost call of the same function
+		if !onStack[n.Function] {
+			e.Total += n.Total
+			onStack[n.Function] = true
+			defer delete(onStack, n.Function)
+		}
+
+		for _, child := range n.Children {
+			walk(child, onStack)
+		}
+	}
+	walk(pr.Root, make(map[string]bool))
+
+	var flat []Entry
+	for _, e := range entries {
+		flat = append(flat, *e)
+	}
+	sort.Slice(flat, func(i, j int) bool {
+		if flat[i].Self != flat[j].Self {
+			return flat[i].Self > flat[j].Self
+		}
+		return flat[i].Function < flat[j].Function
+	})
+
+	return flat
+}
@@ -0,0 +1,179 @@
+package profile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ChelseaDH/VMTranslator/hack"
+	"github.com/ChelseaDH/VMTranslator/parser"
+	"github.com/ChelseaDH/VMTranslator/translator"
+)
+
+// Sys.init calls Main.fact, which calls itself and Main.double
+const program = `function Sys.init 0
+push constant 3
+call Main.fact 1
+pop temp 1
+label HALT
+goto HALT
+function Main.fact 0
+push argument 0
+push constant 1
+gt
+if-goto rec
+push constant 1
+return
+label rec
+push argument 0
+push constant 1
+sub
+call Main.fact 1
+call Main.double 1
+return
+function Main.double 0
+push argument 0
+push argument 0
+add
+return`
+
+func translate(t *testing.T, source string) *hack.Program {
+	var output bytes.Buffer
+	tr := translator.Translator{Output: &output}
+	tr.SetNamespace("Test")
+
+	err := tr.Initialise()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(source, "\n") {
+		c, err := parser.Parse(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = tr.Translate(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	tr.Terminate()
+
+	p, err := hack.Assemble(&output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestProfiler_Run(t *testing.T) {
+	p := translate(t, program)
+	pr := New(p)
+	err := pr.Run(100000)
+	if err != hack.ErrHalted {
+		t.Fatalf("expected the program to halt, but %v returned", err)
+	}
+	pr.Finish()
+
+	if pr.CPU.RAM[6] != 4 {
+		t.Errorf("expected 2 * fact(2) = 4 in temp 1, got %d", pr.CPU.RAM[6])
+	}
+
+	counted := uint64(0)
+	for _, count := range pr.Counts {
+		counted += count
+	}
+	if counted != pr.CPU.Cycles || pr.Root.Total != pr.CPU.Cycles {
+		t.Errorf("expected %d instructions to be counted, got %d per address and %d in the tree", pr.CPU.Cycles, counted, pr.Root.Total)
+	}
+
+	// fact(3) calls fact(2) and then double, and fact(2) calls fact(1) and then double
+	expTree := "(bootstrap)\n Sys.init\n  Main.fact\n   Main.double\n   Main.fact\n    Main.double\n    Main.fact\n"
+	var tree strings.Builder
+	var walk func(n *Node, depth int)
+	walk = func(n *Node, depth int) {
+		tree.WriteString(strings.Repeat(" ", depth) + n.Function + "\n")
+		children := n.SortedChildren()
+		// Order by name rather than cost so the expected tree does not depend on the translator's output
+		for i := 1; i < len(children); i++ {
+			for j := i; j > 0 && children[j].Function < children[j-1].Function; j-- {
+				children[j], children[j-1] = children[j-1], children[j]
+			}
+		}
+		for _, child := range children {
+			walk(child, depth+1)
+		}
+	}
+	walk(pr.Root, 0)
+	if tree.String() != expTree {
+		t.Errorf("expected call tree\n%s but got\n%s", expTree, tree.String())
+	}
+
+	for _, e := range pr.Flat() {
+		if e.Function == "Main.fact" && e.Total >= pr.Root.Total {
+			t.Errorf("expected recursive calls to Main.fact to be counted once in its total, got %d of %d", e.Total, pr.Root.Total)
+		}
+	}
+}
+
+func TestProfiler_WritePprof(t *testing.T) {
+	pr := New(translate(t, program))
+	err := pr.Run(100000)
+	if err != hack.ErrHalted {
+		t.Fatalf("expected the program to halt, but %v returned", err)
+	}
+	pr.Finish()
+
+	var output bytes.Buffer
+	err = pr.WritePprof(&output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gz, err := gzip.NewReader(&output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{"instructions", "count", "Main.fact", "Main.double", "Sys.init"} {
+		if !bytes.Contains(data, []byte(s)) {
+			t.Errorf("expected the profile to contain the string %q", s)
+		}
+	}
+}
+
+func TestProfiler_LoopAtStart(t *testing.T) {
+	// Main.count's loop label shares its address, as a Jack while loop that starts a function does, and
+	// that is also the bootstrap's return address as Main.count is the first function
+	pr := New(translate(t, `function Main.count 0
+label LOOP
+push argument 0
+push constant 1
+sub
+pop argument 0
+push argument 0
+if-goto LOOP
+push constant 0
+return
+function Sys.init 0
+push constant 50
+call Main.count 1
+pop temp 1
+label HALT
+goto HALT`))
+	err := pr.Run(100000)
+	if err != hack.ErrHalted {
+		t.Fatalf("expected the program to halt, but %v returned", err)
+	}
+	pr.Finish()
+
+	count := pr.Root.Children["Sys.init"].Children["Main.count"]
+	if count == nil || len(count.Children) != 0 || count.Self < pr.Root.Total/2 {
+		t.Errorf("expected Main.count to be called once, with no recursive calls, and run most of the program")
+	}
+}
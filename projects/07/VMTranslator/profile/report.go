@@ -0,0 +1,55 @@
+package profile
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteFlat prints the cost of each function, most expensive first.
+func (pr *Profiler) WriteFlat(w io.Writer) error {
+	var b strings.Builder
+	all := float64(pr.Root.Total)
+	if all == 0 {
+		all = 1
+	}
+
+	b.WriteString(fmt.Sprintf("%12s %7s %7s %12s %7s  %s\n", "self", "self%", "sum%", "total", "total%", "function"))
+	sum := uint64(0)
+	for _, e := range pr.Flat() {
+		sum += e.Self
+		b.WriteString(fmt.Sprintf("%12d %6.2f%% %6.2f%% %12d %6.2f%%  %s\n",
+			e.Self, 100*float64(e.Self)/all, 100*float64(sum)/all, e.Total, 100*float64(e.Total)/all, e.Function))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteTree prints the call tree, with each function indented beneath its caller and callees most expensive first.
+// Calls costing less than minimum percent of the whole program are left out.
+func (pr *Profiler) WriteTree(w io.Writer, minimum float64) error {
+	var b strings.Builder
+	all := float64(pr.Root.Total)
+	if all == 0 {
+		all = 1
+	}
+
+	b.WriteString(fmt.Sprintf("%12s %7s %12s  %s\n", "total", "total%", "self", "function"))
+	var walk func(n *Node, depth int)
+	walk = func(n *Node, depth int) {
+		percent := 100 * float64(n.Total) / all
+		if percent < minimum {
+			return
+		}
+
+		b.WriteString(fmt.Sprintf("%12d %6.2f%% %12d  %s%s\n", n.Total, percent, n.Self, strings.Repeat("  ", depth), n.Function))
+		for _, child := range n.SortedChildren() {
+			walk(child, depth+1)
+		}
+	}
+	walk(pr.Root, 0)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
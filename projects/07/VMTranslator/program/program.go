@@ -0,0 +1,124 @@
+package program
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/ChelseaDH/VMTranslator/command"
+	"github.com/ChelseaDH/VMTranslator/parser"
+)
+
+// Line is a single VM command along with where it was read from.
+type Line struct {
+	File    string
+	Number  int
+	Command command.Command
+}
+
+func (l Line) Position() string {
+	return fmt.Sprintf("%s:%d", l.File, l.Number)
+}
+
+// Function is a VM function declaration and the commands making up its body.
+// Commands appearing before the first declaration of a file are grouped into a Function with no Name.
+type Function struct {
+	Name        string
+	Locals      int
+	Declaration Line
+	Body        []Line
+}
+
+// Read parses every command read from r, recording file as their source.
+func Read(file string, r io.Reader) ([]Line, error) {
+	var lines []Line
+
+	scanner := bufio.NewScanner(r)
+	number := 0
+	for scanner.Scan() {
+		number++
+		c, err := parser.Parse(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", file, number, err)
+		}
+
+		if c == nil {
+			continue
+		}
+
+		lines = append(lines, Line{File: file, Number: number, Command: c})
+	}
+
+	return lines, scanner.Err()
+}
+
+// ReadPath parses a .vm file, or every .vm file in a directory in name order.
+func ReadPath(name string) ([]Line, error) {
+	fileInfo, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !fileInfo.IsDir() {
+		return readFile(name)
+	}
+
+	files, err := os.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []Line
+	for _, file := range files {
+		if path.Ext(file.Name()) != ".vm" {
+			continue
+		}
+
+		fileLines, err := readFile(path.Join(name, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, fileLines...)
+	}
+
+	return lines, nil
+}
+
+func readFile(name string) ([]Line, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Read(name, file)
+}
+
+// Functions groups lines by the function declared before them.
+func Functions(lines []Line) []Function {
+	var functions []Function
+	current := -1
+
+	for _, line := range lines {
+		if fc, ok := line.Command.(*command.FunctionCommand); ok && fc.Type() == command.Function {
+			functions = append(functions, Function{
+				Name:        fc.Name,
+				Locals:      fc.Args,
+				Declaration: line,
+			})
+			current = len(functions) - 1
+			continue
+		}
+
+		if current == -1 || functions[current].Declaration.File != line.File {
+			functions = append(functions, Function{Declaration: Line{File: line.File, Number: line.Number}})
+			current = len(functions) - 1
+		}
+
+		functions[current].Body = append(functions[current].Body, line)
+	}
+
+	return functions
+}
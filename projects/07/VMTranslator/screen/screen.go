@@ -0,0 +1,113 @@
+// Package screen renders the Hack screen memory map as an image, and reads back the text the Jack OS printed on it.
+//
+// The words passed to each function are the 8K screen map starting at RAM 16384, for example
+// machine.RAM[Base:Base+Words] of a program translated with VMTranslator -target go.
+// Machine.Tick can be used to take a snapshot every Machine.Interval commands, and one taken after Run
+// returns shows the screen at halt. The hackdbg and jackdbg screenshot command and the hacktrace -screen flag
+// write the screen of the emulators in the same way.
+package screen
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+const (
+	// Base is the RAM address of the screen memory map.
+	Base = 16384
+	// Width and Height are the dimensions of the screen in pixels.
+	Width  = 512
+	Height = 256
+	// Words is the number of 16-bit words in the screen memory map.
+	Words = Width * Height / 16
+)
+
+var palette = color.Palette{color.White, color.Black}
+
+// Pixel reports whether the pixel at x, y is set (black).
+// The least significant bit of each word is its leftmost pixel.
+func Pixel(words []int16, x int, y int) bool {
+	word := uint16(words[y*Width/16+x/16])
+	return word&(1<<(x%16)) != 0
+}
+
+// Image returns the screen as a two colour image, with set pixels in black on a white background.
+func Image(words []int16) (*image.Paletted, error) {
+	if len(words) != Words {
+		return nil, fmt.Errorf("expected %d words of screen memory, got %d", Words, len(words))
+	}
+
+	img := image.NewPaletted(image.Rect(0, 0, Width, Height), palette)
+	for y := 0; y < Height; y++ {
+		for x := 0; x < Width; x++ {
+			if Pixel(words, x, y) {
+				img.Pix[y*img.Stride+x] = 1
+			}
+		}
+	}
+
+	return img, nil
+}
+
+// WritePNG writes the screen as a PNG image.
+func WritePNG(w io.Writer, words []int16) error {
+	img, err := Image(words)
+	if err != nil {
+		return err
+	}
+
+	return png.Encode(w, img)
+}
+
+// WritePBM writes the screen as a binary (P4) portable bitmap, the same format the C target dumps at halt.
+func WritePBM(w io.Writer, words []int16) error {
+	if len(words) != Words {
+		return fmt.Errorf("expected %d words of screen memory, got %d", Words, len(words))
+	}
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "P4\n%d %d\n", Width, Height)
+	for _, word := range words {
+		var bytes [2]byte
+		for bit := 0; bit < 16; bit++ {
+			if uint16(word)&(1<<bit) != 0 {
+				bytes[bit/8] |= 0x80 >> (bit % 8)
+			}
+		}
+		out.Write(bytes[:])
+	}
+
+	return out.Flush()
+}
+
+// WriteFile writes the screen to the file called name, as a PBM image if its extension is .pbm and a PNG image otherwise.
+func WriteFile(name string, words []int16) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	if strings.EqualFold(path.Ext(name), ".pbm") {
+		err = WritePBM(file, words)
+	} else {
+		err = WritePNG(file, words)
+	}
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// NumberedName adds n to name ahead of its extension, such as screen-1000.png, to name one of a series of images.
+func NumberedName(name string, n uint64) string {
+	ext := path.Ext(name)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), n, ext)
+}
@@ -0,0 +1,127 @@
+package screen
+
+import (
+	"bytes"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testScreen() []int16 {
+	words := make([]int16, Words)
+	// Leftmost pixel of the top row, and the rightmost pixel of the bottom row
+	words[0] = 1
+	words[Words-1] = -32768
+	return words
+}
+
+func TestWritePBM(t *testing.T) {
+	var output bytes.Buffer
+	err := WritePBM(&output, testScreen())
+	if err != nil {
+		t.Fatalf("did not expect an error, but %q returned", err)
+	}
+
+	header := "P4\n512 256\n"
+	data := output.Bytes()
+	if !bytes.HasPrefix(data, []byte(header)) || len(data) != len(header)+Words*2 {
+		t.Fatalf("unexpected PBM header or length %d", len(data))
+	}
+
+	data = data[len(header):]
+	if data[0] != 0x80 || data[len(data)-1] != 0x01 {
+		t.Errorf("expected corner pixels to be set, got %#x and %#x", data[0], data[len(data)-1])
+	}
+}
+
+func TestWritePNG(t *testing.T) {
+	var output bytes.Buffer
+	err := WritePNG(&output, testScreen())
+	if err != nil {
+		t.Fatalf("did not expect an error, but %q returned", err)
+	}
+
+	img, err := png.Decode(&output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []struct {
+		x, y int
+		set  bool
+	}{{0, 0, true}, {1, 0, false}, {Width - 1, Height - 1, true}, {Width - 2, Height - 1, false}} {
+		r, _, _, _ := img.At(p.x, p.y).RGBA()
+		if set := r == 0; set != p.set {
+			t.Errorf("expected pixel %d,%d set to be %t", p.x, p.y, p.set)
+		}
+	}
+
+	if err := WritePNG(&output, make([]int16, 10)); err == nil {
+		t.Errorf("expected an error but none returned for a short screen")
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	for name, expPrefix := range map[string]string{"screen.pbm": "P4\n", "screen.png": "\x89PNG", "screen": "\x89PNG"} {
+		err := WriteFile(filepath.Join(dir, name), testScreen())
+		if err != nil {
+			t.Fatalf("did not expect an error, but %q returned for %s", err, name)
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(data), expPrefix) {
+			t.Errorf("expected %s to start with %q", name, expPrefix)
+		}
+	}
+
+	if err := WriteFile(filepath.Join(dir, "short.png"), make([]int16, 10)); err == nil {
+		t.Errorf("expected an error but none returned for a short screen")
+	}
+}
+
+func TestNumberedName(t *testing.T) {
+	for name, expName := range map[string]string{"out.png": "out-1000.png", "dir/out.pbm": "dir/out-1000.pbm", "out": "out-1000"} {
+		if numbered := NumberedName(name, 1000); numbered != expName {
+			t.Errorf("expected %s but got %s for %s", expName, numbered, name)
+		}
+	}
+}
+
+// printChar draws c as the Jack OS Output class does.
+func printChar(words []int16, row int, column int, c rune) {
+	for i, bits := range font[c] {
+		words[(row*CharHeight+i)*Width/16+column/2] |= int16(uint16(bits) << (column % 2 * CharWidth))
+	}
+}
+
+func TestText(t *testing.T) {
+	words := make([]int16, Words)
+	for i, c := range "Hi, {world}!" {
+		printChar(words, 1, 2+i, c)
+	}
+	printChar(words, 3, 0, 0)
+	printChar(words, 3, 63, '~')
+	// A stray pixel leaves the cell unreadable
+	printChar(words, 4, 5, 'x')
+	words[4*CharHeight*Width/16+2] |= 0x100
+
+	text, err := Text(words)
+	if err != nil {
+		t.Fatalf("did not expect an error, but %q returned", err)
+	}
+
+	expText := "\n  Hi, {world}!\n\n■" + strings.Repeat(" ", 62) + "~\n     �\n"
+	if text != expText {
+		t.Errorf("expected text %q, got %q", expText, text)
+	}
+
+	if text, _ := Text(make([]int16, Words)); text != "" {
+		t.Errorf("expected no text on a blank screen, got %q", text)
+	}
+}
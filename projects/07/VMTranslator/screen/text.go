@@ -0,0 +1,75 @@
+package screen
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// CharWidth and CharHeight are the size in pixels of the cell the Jack OS Output class prints each character in.
+	CharWidth  = 8
+	CharHeight = 11
+	// Columns and Rows are the number of characters Output fits across and down the screen.
+	Columns = Width / CharWidth
+	Rows    = Height / CharHeight
+)
+
+const (
+	// Block is read from a cell showing the black square Output prints for characters it has no bitmap for.
+	Block = '■'
+	// Unknown is read from a cell that does not show a character of the font, such as one drawn over by graphics.
+	Unknown = '�'
+)
+
+// glyphs maps the bitmap of each character in the font back to the character.
+var glyphs = make(map[[CharHeight]uint8]rune)
+
+func init() {
+	for c, bitmap := range font {
+		if c == 0 {
+			c = Block
+		}
+		glyphs[bitmap] = c
+	}
+}
+
+// Text reads the characters the Jack OS Output class printed on the screen, one line per row, without the spaces
+// at the end of each line or the empty lines at the bottom of the screen.
+func Text(words []int16) (string, error) {
+	if len(words) != Words {
+		return "", fmt.Errorf("expected %d words of screen memory, got %d", Words, len(words))
+	}
+
+	lines := make([]string, Rows)
+	for row := range lines {
+		var line strings.Builder
+		for column := 0; column < Columns; column++ {
+			line.WriteRune(char(words, row, column))
+		}
+		lines[row] = strings.TrimRight(line.String(), " ")
+	}
+
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return "", nil
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// char reads the character in the cell at row, column. Output prints even columns in the low byte of a word
+// and odd columns in the high byte.
+func char(words []int16, row int, column int) rune {
+	var bitmap [CharHeight]uint8
+	for i := range bitmap {
+		word := uint16(words[(row*CharHeight+i)*Width/16+column/2])
+		bitmap[i] = uint8(word >> (column % 2 * CharWidth))
+	}
+
+	c, ok := glyphs[bitmap]
+	if !ok {
+		return Unknown
+	}
+	return c
+}
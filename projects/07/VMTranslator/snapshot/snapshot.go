@@ -0,0 +1,194 @@
+// Package snapshot saves the whole state of the Hack CPU emulator or the VM interpreter to a file, and restores it,
+// so that a program that takes minutes to reach an interesting state can be started from there again.
+//
+// A CPU snapshot holds the ROM, RAM, registers, PC and cycle count. A VM snapshot holds the program's commands,
+// the RAM, PC, command count and call stack. Snapshots are gzipped gob streams.
+package snapshot
+
+import (
+	"compress/gzip"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/ChelseaDH/VMTranslator/hack"
+	"github.com/ChelseaDH/VMTranslator/parser"
+	"github.com/ChelseaDH/VMTranslator/program"
+	"github.com/ChelseaDH/VMTranslator/vm"
+)
+
+const (
+	// format identifies a snapshot file, and version the layout of the state that follows its header.
+	format  = "hack snapshot"
+	version = 1
+
+	cpuKind = "CPU"
+	vmKind  = "VM"
+)
+
+type header struct {
+	Format  string
+	Version int
+	Kind    string
+}
+
+type cpuState struct {
+	ROM    []uint16
+	RAM    []int16
+	A, D   int16
+	PC     uint16
+	Cycles uint64
+}
+
+type vmState struct {
+	Lines  []line
+	RAM    []int16
+	PC     int
+	Steps  uint64
+	Frames []vm.Frame
+}
+
+// line is a VM command as text, along with where it was read from.
+type line struct {
+	File    string
+	Number  int
+	Command string
+}
+
+func save(w io.Writer, kind string, state interface{}) error {
+	z := gzip.NewWriter(w)
+	encoder := gob.NewEncoder(z)
+
+	err := encoder.Encode(header{Format: format, Version: version, Kind: kind})
+	if err != nil {
+		return err
+	}
+	err = encoder.Encode(state)
+	if err != nil {
+		return err
+	}
+
+	return z.Close()
+}
+
+func load(r io.Reader, kind string, state interface{}) error {
+	z, err := gzip.NewReader(r)
+	if err != nil {
+		return errors.New("not a snapshot")
+	}
+	defer z.Close()
+	decoder := gob.NewDecoder(z)
+
+	var h header
+	err = decoder.Decode(&h)
+	if err != nil || h.Format != format {
+		return errors.New("not a snapshot")
+	}
+	if h.Version != version {
+		return fmt.Errorf("snapshot version %d is not supported", h.Version)
+	}
+	if h.Kind != kind {
+		return fmt.Errorf("expected a %s snapshot, got a %s snapshot", kind, h.Kind)
+	}
+
+	return decoder.Decode(state)
+}
+
+// SaveCPU writes a snapshot of cpu.
+func SaveCPU(w io.Writer, cpu *hack.CPU) error {
+	return save(w, cpuKind, cpuState{
+		ROM:    cpu.ROM,
+		RAM:    cpu.RAM[:],
+		A:      cpu.A,
+		D:      cpu.D,
+		PC:     cpu.PC,
+		Cycles: cpu.Cycles,
+	})
+}
+
+// LoadCPU reads a snapshot written by SaveCPU, returning a CPU in the state it was saved in.
+func LoadCPU(r io.Reader) (*hack.CPU, error) {
+	var state cpuState
+	err := load(r, cpuKind, &state)
+	if err != nil {
+		return nil, err
+	}
+	if len(state.RAM) != hack.RAMSize || len(state.ROM) > hack.ROMSize {
+		return nil, errors.New("the snapshot's memory is the wrong size")
+	}
+
+	cpu := &hack.CPU{ROM: state.ROM, A: state.A, D: state.D, PC: state.PC, Cycles: state.Cycles}
+	copy(cpu.RAM[:], state.RAM)
+	return cpu, nil
+}
+
+// SaveVM writes a snapshot of m, including its program.
+func SaveVM(w io.Writer, m *vm.Machine) error {
+	state := vmState{RAM: m.RAM[:], PC: m.PC, Steps: m.Steps, Frames: m.Frames}
+	for _, l := range m.Lines {
+		state.Lines = append(state.Lines, line{File: l.File, Number: l.Number, Command: l.Command.String()})
+	}
+
+	return save(w, vmKind, state)
+}
+
+// LoadVM reads a snapshot written by SaveVM, returning a Machine running the same program in the state it was saved in.
+func LoadVM(r io.Reader) (*vm.Machine, error) {
+	var state vmState
+	err := load(r, vmKind, &state)
+	if err != nil {
+		return nil, err
+	}
+	if len(state.RAM) != vm.RAMSize {
+		return nil, errors.New("the snapshot's memory is the wrong size")
+	}
+
+	lines := make([]program.Line, len(state.Lines))
+	for i, l := range state.Lines {
+		c, err := parser.Parse(l.Command)
+		if err != nil || c == nil {
+			return nil, fmt.Errorf("%s:%d: invalid command %q in the snapshot", l.File, l.Number, l.Command)
+		}
+		lines[i] = program.Line{File: l.File, Number: l.Number, Command: c}
+	}
+
+	// New calls Sys.init, which the saved state then replaces
+	m, err := vm.New(lines)
+	if err != nil {
+		return nil, err
+	}
+	copy(m.RAM[:], state.RAM)
+	m.PC, m.Steps, m.Frames = state.PC, state.Steps, state.Frames
+
+	return m, nil
+}
+
+// SameProgram reports whether a and b run the same commands read from the same places, as a machine loaded from
+// a snapshot must to be used with symbols read for the original program. Files are compared by name alone, as
+// statics and symbols are keyed, so the directory may be given by another path than when the snapshot was saved.
+func SameProgram(a []program.Line, b []program.Line) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if path.Base(a[i].File) != path.Base(b[i].File) || a[i].Number != b[i].Number || a[i].Command.String() != b[i].Command.String() {
+			return false
+		}
+	}
+	return true
+}
+
+// SameROM reports whether a and b hold the same program.
+func SameROM(a []uint16, b []uint16) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
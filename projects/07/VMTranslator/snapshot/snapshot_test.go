@@ -0,0 +1,182 @@
+package snapshot
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ChelseaDH/VMTranslator/hack"
+	"github.com/ChelseaDH/VMTranslator/program"
+	"github.com/ChelseaDH/VMTranslator/vm"
+)
+
+const asm = `@100
+D=A
+@i
+M=D
+(LOOP)
+@sum
+M=M+D
+@i
+MD=M-1
+@LOOP
+D;JGT
+(END)
+@END
+0;JMP`
+
+func TestCPU(t *testing.T) {
+	p, err := hack.Assemble(strings.NewReader(asm))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Saving part way through the loop and carrying on from the snapshot gives the same result as running straight through
+	cpu := hack.NewCPU(p)
+	err = cpu.Run(200)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var saved bytes.Buffer
+	err = SaveCPU(&saved, cpu)
+	if err != nil {
+		t.Fatalf("did not expect an error, but %q returned", err)
+	}
+	restored, err := LoadCPU(bytes.NewReader(saved.Bytes()))
+	if err != nil {
+		t.Fatalf("did not expect an error, but %q returned", err)
+	}
+	if !reflect.DeepEqual(cpu, restored) {
+		t.Fatalf("expected the restored CPU to match the saved one")
+	}
+
+	for _, c := range []*hack.CPU{cpu, restored} {
+		if err = c.Run(0); err != hack.ErrHalted {
+			t.Fatalf("expected the program to halt, got %v", err)
+		}
+	}
+	if restored.RAM[17] != 5050 || restored.Cycles != cpu.Cycles {
+		t.Errorf("expected the restored CPU to sum to 5050 after %d instructions, got %d after %d", cpu.Cycles, restored.RAM[17], restored.Cycles)
+	}
+
+	if _, err := LoadVM(bytes.NewReader(saved.Bytes())); err == nil || err.Error() != "expected a VM snapshot, got a CPU snapshot" {
+		t.Errorf("expected an error loading a CPU snapshot as a VM one, got %v", err)
+	}
+}
+
+const vmProgram = `function Sys.init 0
+push constant 6
+call Main.fact 1
+pop static 0
+label END
+goto END
+function Main.fact 0
+push argument 0
+push constant 1
+gt
+if-goto REC
+push constant 1
+return
+label REC
+push argument 0
+push argument 0
+push constant 1
+sub
+call Main.fact 1
+call Main.mult 2
+return
+function Main.mult 1
+label LOOP
+push argument 1
+push constant 0
+eq
+if-goto DONE
+push local 0
+push argument 0
+add
+pop local 0
+push argument 1
+push constant 1
+sub
+pop argument 1
+goto LOOP
+label DONE
+push local 0
+return`
+
+func TestVM(t *testing.T) {
+	lines, err := program.Read("Main.vm", strings.NewReader(vmProgram))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := vm.New(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Deep in the recursion, with several frames on the call stack
+	for m.Steps < 60 {
+		err = m.Step()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var saved bytes.Buffer
+	err = SaveVM(&saved, m)
+	if err != nil {
+		t.Fatalf("did not expect an error, but %q returned", err)
+	}
+	restored, err := LoadVM(&saved)
+	if err != nil {
+		t.Fatalf("did not expect an error, but %q returned", err)
+	}
+
+	if !SameProgram(m.Lines, restored.Lines) || restored.PC != m.PC || restored.Steps != m.Steps || restored.RAM != m.RAM {
+		t.Fatalf("expected the restored machine to match the saved one")
+	}
+	if !reflect.DeepEqual(restored.Frames, m.Frames) || len(m.Frames) < 3 {
+		t.Fatalf("expected the call stack %v, got %v", m.Frames, restored.Frames)
+	}
+
+	for _, machine := range []*vm.Machine{m, restored} {
+		if err = machine.Run(0); err != vm.ErrHalted {
+			t.Fatalf("expected the program to halt, got %v", err)
+		}
+	}
+	if restored.RAM[vm.StaticBase] != 720 || restored.Steps != m.Steps {
+		t.Errorf("expected the restored machine to compute 720 after %d commands, got %d after %d", m.Steps, restored.RAM[vm.StaticBase], restored.Steps)
+	}
+}
+
+func TestSameProgram(t *testing.T) {
+	read := func(name string, source string) []program.Line {
+		lines, err := program.Read(name, strings.NewReader(source))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return lines
+	}
+
+	// A directory given as /tmp/small when the snapshot was saved and as . when it is loaded
+	saved := read("/tmp/small/Main.vm", vmProgram)
+	if !SameProgram(saved, read("Main.vm", vmProgram)) {
+		t.Errorf("expected the same files read through another path to be the same program")
+	}
+	if SameProgram(saved, read("/tmp/small/Other.vm", vmProgram)) {
+		t.Errorf("expected a file of another name to be a different program")
+	}
+	if SameProgram(saved, read("/tmp/small/Main.vm", strings.Replace(vmProgram, "push local 0", "push local 1", 1))) {
+		t.Errorf("expected a changed command to make a different program")
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	for _, input := range []string{"", "not a snapshot", "\x1f\x8b\x08\x00garbage"} {
+		if _, err := LoadCPU(strings.NewReader(input)); err == nil {
+			t.Errorf("expected an error loading %q, but none returned", input)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package terminal
+
+import (
+	"bytes"
+
+	"github.com/ChelseaDH/VMTranslator/keyboard"
+)
+
+// The control characters Ctrl-C and Ctrl-\ send, which the terminal turns into signals outside of raw mode.
+const (
+	interrupt = 0x03
+	quit      = 0x1c
+)
+
+// escapeSequences maps the input sent by common terminals for special keys to Hack key names.
+var escapeSequences = map[string]string{
+	"\x1b[A": "up", "\x1b[B": "down", "\x1b[C": "right", "\x1b[D": "left",
+	"\x1bOA": "up", "\x1bOB": "down", "\x1bOC": "right", "\x1bOD": "left",
+	"\x1b[H": "home", "\x1bOH": "home", "\x1b[1~": "home", "\x1b[7~": "home",
+	"\x1b[F": "end", "\x1bOF": "end", "\x1b[4~": "end", "\x1b[8~": "end",
+	"\x1b[5~": "pageup", "\x1b[6~": "pagedown", "\x1b[2~": "insert", "\x1b[3~": "delete",
+	"\x1bOP": "f1", "\x1bOQ": "f2", "\x1bOR": "f3", "\x1bOS": "f4",
+	"\x1b[15~": "f5", "\x1b[17~": "f6", "\x1b[18~": "f7", "\x1b[19~": "f8",
+	"\x1b[20~": "f9", "\x1b[21~": "f10", "\x1b[23~": "f11", "\x1b[24~": "f12",
+}
+
+// Decode returns the Hack key code of the first key press in input and the number of bytes it used.
+// Input that is not a key press, such as an unknown escape sequence, has a code of 0.
+func Decode(input []byte) (code int16, n int) {
+	if len(input) == 0 {
+		return 0, 0
+	}
+
+	if input[0] == 0x1b {
+		for sequence, name := range escapeSequences {
+			if bytes.HasPrefix(input, []byte(sequence)) {
+				return keyboard.Codes[name], len(sequence)
+			}
+		}
+
+		// A lone escape is the escape key, anything else is an escape sequence we don't know
+		if len(input) == 1 || (input[1] != '[' && input[1] != 'O') {
+			return keyboard.Codes["esc"], 1
+		}
+		return 0, unknownSequence(input)
+	}
+
+	switch c := input[0]; {
+	case c == '\r' || c == '\n':
+		return keyboard.Codes["newline"], 1
+	case c == 0x7f || c == 0x08:
+		return keyboard.Codes["backspace"], 1
+	case c >= ' ' && c <= '~':
+		return int16(c), 1
+	default:
+		return 0, 1
+	}
+}
+
+// unknownSequence returns the length of the escape sequence at the start of input, so that the keys typed after it
+// are still read. An SS3 sequence is a single character after ESC O, and a CSI sequence after ESC [ runs up to and
+// including its final byte, in the range @ to ~.
+func unknownSequence(input []byte) int {
+	if input[1] == 'O' {
+		if len(input) < 3 {
+			return len(input)
+		}
+		return 3
+	}
+
+	for i := 2; i < len(input); i++ {
+		if input[i] >= '@' && input[i] <= '~' {
+			return i + 1
+		}
+	}
+	return len(input)
+}
@@ -0,0 +1,144 @@
+// Package terminal plays Hack programs on a text terminal, drawing the screen with Unicode characters
+// and passing key presses through to the keyboard register.
+//
+// A program translated with VMTranslator -target go can be played with
+//
+//	p := &terminal.Player{In: os.Stdin, Out: os.Stdout, Refresh: time.Second / 20}
+//	restore, err := terminal.Raw(os.Stdin)
+//	...
+//	defer restore()
+//	p.Quit = func() { restore(); os.Exit(0) }
+//	p.Start()
+//	m.KeyboardInput = p.Key
+//	m.Tick = func() { p.Draw(m.RAM[screen.Base : screen.Base+screen.Words]) }
+//	m.Interval = 1000
+//	err = m.Run()
+package terminal
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Player draws frames of the screen and tracks the key currently held on the terminal.
+type Player struct {
+	In  io.Reader
+	Out io.Writer
+	// Mode selects how pixels are drawn.
+	Mode Mode
+	// Refresh is the least time between frames, Draw skips frames that would come sooner.
+	Refresh time.Duration
+	// Hold is how long a key stays pressed after the terminal last sent it. Terminals don't report key releases,
+	// so this should cover the delay before a held key starts repeating. Defaults to half a second.
+	Hold time.Duration
+	// Quit, if set, is called when Ctrl-C or Ctrl-\ is pressed. Raw mode stops the terminal turning these into
+	// signals, so without it the program can't be interrupted.
+	Quit func()
+
+	mutex     sync.Mutex
+	key       int16
+	pressed   time.Time
+	closed    bool
+	lastFrame time.Time
+}
+
+// Start reads key presses from In until it is closed or returns an error.
+func (p *Player) Start() {
+	go func() {
+		buffer := make([]byte, 64)
+		for {
+			n, err := p.In.Read(buffer)
+			for input := buffer[:n]; len(input) > 0; {
+				if input[0] == interrupt || input[0] == quit {
+					if p.Quit != nil {
+						p.Quit()
+					}
+					input = input[1:]
+					continue
+				}
+
+				code, used := Decode(input)
+				input = input[used:]
+				if code != 0 {
+					p.mutex.Lock()
+					p.key, p.pressed = code, time.Now()
+					p.mutex.Unlock()
+				}
+			}
+
+			if err != nil {
+				p.mutex.Lock()
+				p.closed = true
+				p.mutex.Unlock()
+				return
+			}
+		}
+	}()
+}
+
+// Closed reports whether In has been closed or failed, after which no more keys will be pressed.
+func (p *Player) Closed() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.closed
+}
+
+// Key returns the code of the key currently held, or 0 if none is. It can be used as Machine.KeyboardInput.
+func (p *Player) Key() int16 {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	hold := p.Hold
+	if hold == 0 {
+		hold = time.Second / 2
+	}
+
+	if time.Since(p.pressed) > hold {
+		return 0
+	}
+	return p.key
+}
+
+// Draw writes the screen to Out, unless the previous frame was drawn less than Refresh ago.
+func (p *Player) Draw(words []int16) error {
+	if time.Since(p.lastFrame) < p.Refresh {
+		return nil
+	}
+	p.lastFrame = time.Now()
+
+	// Move the cursor home and draw over the previous frame
+	_, err := io.WriteString(p.Out, "\x1b[H"+Render(words, p.Mode))
+	return err
+}
+
+// Raw puts the terminal connected to f into raw mode using stty, so that key presses are read as they are typed
+// without being echoed, and clears the screen. The returned function restores the previous mode.
+// Ctrl-C and Ctrl-\ no longer send signals in raw mode, Player.Quit is called for them instead.
+func Raw(f *os.File) (restore func(), err error) {
+	stty := func(args ...string) ([]byte, error) {
+		cmd := exec.Command("stty", args...)
+		cmd.Stdin = f
+		return cmd.Output()
+	}
+
+	state, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = stty("raw", "-echo")
+	if err != nil {
+		return nil, err
+	}
+
+	os.Stdout.WriteString("\x1b[2J\x1b[?25l")
+	return func() {
+		os.Stdout.WriteString("\x1b[?25h")
+		stty(strings.TrimSpace(string(state)))
+	}, nil
+}
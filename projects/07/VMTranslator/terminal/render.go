@@ -0,0 +1,62 @@
+package terminal
+
+import (
+	"strings"
+
+	"github.com/ChelseaDH/VMTranslator/screen"
+)
+
+// Mode selects the characters the screen is drawn with.
+type Mode int
+
+const (
+	// HalfBlock draws two pixels per character, one above the other, needing a 512x128 terminal.
+	HalfBlock Mode = iota
+	// Braille draws a 2x4 block of pixels per character, needing a 256x64 terminal.
+	Braille
+)
+
+var halfBlocks = [4]rune{' ', '▀', '▄', '█'}
+
+// brailleDots gives the bit of the braille pattern for each pixel of a 2x4 cell, indexed by [y][x].
+var brailleDots = [4][2]rune{{0x01, 0x08}, {0x02, 0x10}, {0x04, 0x20}, {0x40, 0x80}}
+
+// Render draws the screen memory map as lines of text, with set pixels drawn and clear pixels left blank.
+func Render(words []int16, mode Mode) string {
+	var b strings.Builder
+
+	switch mode {
+	case Braille:
+		for y := 0; y < screen.Height; y += 4 {
+			for x := 0; x < screen.Width; x += 2 {
+				cell := rune(0x2800)
+				for dy := 0; dy < 4; dy++ {
+					for dx := 0; dx < 2; dx++ {
+						if screen.Pixel(words, x+dx, y+dy) {
+							cell |= brailleDots[dy][dx]
+						}
+					}
+				}
+				b.WriteRune(cell)
+			}
+			b.WriteString("\r\n")
+		}
+
+	default:
+		for y := 0; y < screen.Height; y += 2 {
+			for x := 0; x < screen.Width; x++ {
+				cell := 0
+				if screen.Pixel(words, x, y) {
+					cell |= 1
+				}
+				if screen.Pixel(words, x, y+1) {
+					cell |= 2
+				}
+				b.WriteRune(halfBlocks[cell])
+			}
+			b.WriteString("\r\n")
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,91 @@
+package terminal
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ChelseaDH/VMTranslator/screen"
+)
+
+type decodeTest struct {
+	input   string
+	expCode int16
+	expUsed int
+}
+
+var decodeTests = []decodeTest{
+	{input: "a", expCode: 'a', expUsed: 1},
+	{input: "\r", expCode: 128, expUsed: 1},
+	{input: "\x7f", expCode: 129, expUsed: 1},
+	{input: "\x1b[Dx", expCode: 130, expUsed: 3},
+	{input: "\x1bOA", expCode: 131, expUsed: 3},
+	{input: "\x1b[15~", expCode: 145, expUsed: 5},
+	{input: "\x1b", expCode: 140, expUsed: 1},
+	{input: "\x1b[99~", expCode: 0, expUsed: 5},
+	{input: "\x1b[99~ab", expCode: 0, expUsed: 5},
+	{input: "\x1b[1;5Cq", expCode: 0, expUsed: 6},
+	{input: "\x1bOXq", expCode: 0, expUsed: 3},
+	{input: "\x1b[12", expCode: 0, expUsed: 4},
+	{input: "\x01", expCode: 0, expUsed: 1},
+}
+
+func TestDecode(t *testing.T) {
+	for _, test := range decodeTests {
+		code, used := Decode([]byte(test.input))
+		if code != test.expCode || used != test.expUsed {
+			t.Errorf("expected code %d using %d bytes but got %d using %d for %q", test.expCode, test.expUsed, code, used, test.input)
+		}
+	}
+}
+
+func TestPlayer_Quit(t *testing.T) {
+	quit := make(chan bool, 2)
+	p := &Player{In: strings.NewReader("a\x03\x1b[1;5Cb\x1c"), Quit: func() { quit <- true }}
+	p.Start()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-quit:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected Ctrl-C and Ctrl-\\ to quit, got %d", i)
+		}
+	}
+
+	// The key after the unknown escape sequence is still read
+	if key := p.Key(); key != 'b' {
+		t.Errorf("expected b to be held, got %d", key)
+	}
+}
+
+func TestPlayer_Closed(t *testing.T) {
+	p := &Player{In: strings.NewReader("a")}
+	p.Start()
+
+	// Waiting for a key must end once the input is used up, as at the end of a pipe
+	for deadline := time.Now().Add(5 * time.Second); !p.Closed(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the player to be closed at the end of its input")
+		}
+	}
+	if key := p.Key(); key != 'a' {
+		t.Errorf("expected a to be held, got %d", key)
+	}
+}
+
+func TestRender(t *testing.T) {
+	words := make([]int16, screen.Words)
+	// The top left pixel, and the pixel below the pixel to its right
+	words[0] = 1
+	words[screen.Width/16] = 2
+
+	halfBlock := strings.Split(Render(words, HalfBlock), "\r\n")
+	if len(halfBlock) != screen.Height/2+1 || !strings.HasPrefix(halfBlock[0], "▀▄ ") {
+		t.Errorf("unexpected half block rendering starting %q", halfBlock[0][:12])
+	}
+
+	braille := strings.Split(Render(words, Braille), "\r\n")
+	if len(braille) != screen.Height/4+1 || !strings.HasPrefix(braille[0], "⠑⠀") {
+		t.Errorf("unexpected braille rendering starting %q", braille[0][:6])
+	}
+}
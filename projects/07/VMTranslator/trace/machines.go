@@ -0,0 +1,139 @@
+package trace
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ChelseaDH/VMTranslator/hack"
+	"github.com/ChelseaDH/VMTranslator/vm"
+)
+
+// CPU records a Hack CPU, with the variables of the course CPU emulator: time, the number of instructions run,
+// PC, A, D and RAM[address].
+type CPU struct {
+	*hack.CPU
+}
+
+func (c CPU) Value(name string) (int, error) {
+	switch name {
+	case "time":
+		return int(c.Cycles), nil
+	case "PC":
+		return int(c.PC), nil
+	case "A":
+		return int(c.A), nil
+	case "D":
+		return int(c.D), nil
+	}
+
+	address, err := index(name, "RAM", hack.RAMSize)
+	if err != nil {
+		return 0, err
+	}
+	return int(c.RAM[address]), nil
+}
+
+// Set sets a variable, as a test script's set command does. Every variable but time can be set.
+func (c CPU) Set(name string, value int16) error {
+	switch name {
+	case "time":
+		return fmt.Errorf("%s cannot be set", name)
+	case "PC":
+		c.PC = uint16(value)
+	case "A":
+		c.A = value
+	case "D":
+		c.D = value
+	default:
+		address, err := index(name, "RAM", hack.RAMSize)
+		if err != nil {
+			return err
+		}
+		c.RAM[address] = value
+	}
+	return nil
+}
+
+// vmPointers are the segments the VM emulator names by their pointer, along with the address of each.
+var vmPointers = map[string]int{"sp": vm.SP, "local": vm.LCL, "argument": vm.ARG, "this": vm.THIS, "that": vm.THAT}
+
+// VM records a VM program, with the variables of the course VM emulator: time, the number of commands run,
+// the pointers sp, local, argument, this and that, RAM[address], the elements of the segments such as local[2]
+// and temp[0], and top, the value on top of the stack.
+type VM struct {
+	*vm.Machine
+}
+
+func (m VM) Value(name string) (int, error) {
+	if name == "time" {
+		return int(m.Steps), nil
+	}
+
+	address, err := m.address(name)
+	if err != nil {
+		return 0, err
+	}
+	return int(m.RAM[address]), nil
+}
+
+// Set sets a variable, as a test script's set command does. Every variable but time can be set.
+func (m VM) Set(name string, value int16) error {
+	if name == "time" {
+		return fmt.Errorf("%s cannot be set", name)
+	}
+
+	address, err := m.address(name)
+	if err != nil {
+		return err
+	}
+	m.RAM[address] = value
+	return nil
+}
+
+// address returns the address of the RAM word holding a variable other than time.
+func (m VM) address(name string) (int, error) {
+	if name == "top" {
+		return wrap(int(m.RAM[vm.SP]) - 1), nil
+	}
+	if pointer, ok := vmPointers[name]; ok {
+		return pointer, nil
+	}
+
+	segment := name
+	if bracket := strings.Index(name, "["); bracket > 0 {
+		segment = name[:bracket]
+	}
+	switch segment {
+	case "RAM":
+		return index(name, segment, vm.RAMSize)
+	case "temp":
+		i, err := index(name, segment, 8)
+		return vm.TempBase + i, err
+	}
+
+	pointer, ok := vmPointers[segment]
+	if !ok || segment == "sp" {
+		return 0, fmt.Errorf("unknown variable %s", name)
+	}
+	i, err := index(name, segment, vm.RAMSize)
+	return wrap(int(m.RAM[pointer]) + i), err
+}
+
+// wrap returns an address computed from a segment pointer within the RAM, as the VM's own reads wrap it.
+func wrap(address int) int {
+	return address & (vm.RAMSize - 1)
+}
+
+// index returns the index of a variable name such as RAM[16], which must be below size.
+func index(name string, prefix string, size int) (int, error) {
+	if !strings.HasPrefix(name, prefix+"[") || !strings.HasSuffix(name, "]") {
+		return 0, fmt.Errorf("unknown variable %s", name)
+	}
+
+	i, err := strconv.Atoi(name[len(prefix)+1 : len(name)-1])
+	if err != nil || i < 0 || i >= size {
+		return 0, fmt.Errorf("invalid index in %s", name)
+	}
+	return i, nil
+}
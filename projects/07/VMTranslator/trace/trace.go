@@ -0,0 +1,185 @@
+// Package trace records the state of an emulator as it runs, in the table format of the course's .out and .cmp
+// files, so that traces can be compared with ones written by the official CPU and VM emulators.
+//
+// The columns of a trace are given as in the output-list command of a course test script, for example
+// "time%S1.4.1 PC%D0.5.0 RAM[256]%D1.6.1". Each names a variable of the emulator, followed by its format:
+// B for binary, D for decimal, X for hexadecimal or S for a left aligned string, then the number of spaces
+// to its left, the width of its value and the number of spaces to its right.
+package trace
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DefaultFormat is the format of a column given without one, as the course test scripts default to.
+const DefaultFormat = "%D1.6.1"
+
+// Column is a column of a trace.
+type Column struct {
+	Name   string
+	Format byte
+	// Left and Right are the number of spaces either side of the value, which is Width characters wide.
+	Left, Width, Right int
+}
+
+// ParseColumns parses a list of columns separated by spaces, as an output-list command gives them.
+func ParseColumns(list string) ([]Column, error) {
+	var columns []Column
+	for _, field := range strings.Fields(list) {
+		if !strings.Contains(field, "%") {
+			field += DefaultFormat
+		}
+
+		percent := strings.Index(field, "%")
+		c := Column{Name: field[:percent]}
+		format := field[percent+1:]
+		if c.Name == "" || len(format) < 2 || !strings.Contains("BDXS", format[:1]) {
+			return nil, fmt.Errorf("invalid column %s", field)
+		}
+		c.Format = format[0]
+
+		sizes := strings.Split(format[1:], ".")
+		if len(sizes) != 3 {
+			return nil, fmt.Errorf("invalid column %s: expected the spaces to the left, width and spaces to the right", field)
+		}
+		var err error
+		for i, size := range []*int{&c.Left, &c.Width, &c.Right} {
+			*size, err = strconv.Atoi(sizes[i])
+			if err != nil || *size < 0 {
+				return nil, fmt.Errorf("invalid column %s: %s is not a size", field, sizes[i])
+			}
+		}
+		if c.Width == 0 {
+			return nil, fmt.Errorf("invalid column %s: the width cannot be 0", field)
+		}
+
+		columns = append(columns, c)
+	}
+
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no columns given")
+	}
+	return columns, nil
+}
+
+// header returns the name of c centred in its width, leaving any odd space on the right.
+func (c Column) header() string {
+	total := c.Left + c.Width + c.Right
+	name := c.Name
+	if len(name) > total {
+		name = name[:total]
+	}
+	left := (total - len(name)) / 2
+	return strings.Repeat(" ", left) + name + strings.Repeat(" ", total-len(name)-left)
+}
+
+// cell returns value in the format of c.
+func (c Column) cell(value int) string {
+	var s string
+	switch c.Format {
+	case 'B':
+		s = fmt.Sprintf("%0*b", c.Width, uint16(value))
+		s = s[len(s)-c.Width:]
+	case 'X':
+		s = fmt.Sprintf("%0*X", c.Width, uint16(value))
+		s = s[len(s)-c.Width:]
+	case 'S':
+		s = fmt.Sprintf("%-*d", c.Width, value)
+	default:
+		s = fmt.Sprintf("%*d", c.Width, value)
+	}
+	return strings.Repeat(" ", c.Left) + s + strings.Repeat(" ", c.Right)
+}
+
+// Machine is an emulator a trace can be recorded from.
+type Machine interface {
+	// Value returns the current value of a variable, or an error if the emulator has no variable of that name.
+	Value(name string) (int, error)
+}
+
+// Recorder writes a row of a trace each time Record is called.
+type Recorder struct {
+	w       *bufio.Writer
+	columns []Column
+	machine Machine
+}
+
+// NewRecorder checks that m has each column's variable and writes the header row of the trace.
+func NewRecorder(w io.Writer, columns []Column, m Machine) (*Recorder, error) {
+	r := &Recorder{w: bufio.NewWriter(w), columns: columns, machine: m}
+
+	headers := make([]string, len(columns))
+	for i, c := range columns {
+		_, err := m.Value(c.Name)
+		if err != nil {
+			return nil, err
+		}
+		headers[i] = c.header()
+	}
+
+	return r, r.writeRow(headers)
+}
+
+// Record writes a row holding the current value of each column's variable.
+func (r *Recorder) Record() error {
+	cells := make([]string, len(r.columns))
+	for i, c := range r.columns {
+		value, err := r.machine.Value(c.Name)
+		if err != nil {
+			return err
+		}
+		cells[i] = c.cell(value)
+	}
+	return r.writeRow(cells)
+}
+
+func (r *Recorder) writeRow(cells []string) error {
+	_, err := r.w.WriteString("|" + strings.Join(cells, "|") + "|\n")
+	return err
+}
+
+// Flush writes any rows still buffered.
+func (r *Recorder) Flush() error {
+	return r.w.Flush()
+}
+
+// Compare compares a trace with a comparison file line by line, where a * in the comparison file matches
+// any character, returning the number of the first line that differs or 0 if none do.
+func Compare(trace io.Reader, cmp io.Reader) (int, error) {
+	traceLines, cmpLines := bufio.NewScanner(trace), bufio.NewScanner(cmp)
+	for line := 1; ; line++ {
+		moreTrace, moreCmp := traceLines.Scan(), cmpLines.Scan()
+		if !moreTrace || !moreCmp {
+			if err := traceLines.Err(); err != nil {
+				return 0, err
+			}
+			if err := cmpLines.Err(); err != nil {
+				return 0, err
+			}
+			if moreTrace != moreCmp {
+				return line, nil
+			}
+			return 0, nil
+		}
+
+		if !matches(strings.TrimRight(traceLines.Text(), " \r"), strings.TrimRight(cmpLines.Text(), " \r")) {
+			return line, nil
+		}
+	}
+}
+
+func matches(line string, pattern string) bool {
+	if len(line) != len(pattern) {
+		return false
+	}
+	for i := 0; i < len(line); i++ {
+		if pattern[i] != '*' && pattern[i] != line[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,255 @@
+package trace
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ChelseaDH/VMTranslator/hack"
+	"github.com/ChelseaDH/VMTranslator/program"
+	"github.com/ChelseaDH/VMTranslator/vm"
+)
+
+// values is a machine whose variables hold fixed values.
+type values map[string]int
+
+func (v values) Value(name string) (int, error) {
+	value, ok := v[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown variable %s", name)
+	}
+	return value, nil
+}
+
+type recorderTest struct {
+	list   string
+	values values
+	// expOutput is the header and one row, taken from the course's comparison files where possible
+	expOutput string
+}
+
+var recorderTests = []recorderTest{
+	{
+		list:      "time%S1.4.1 in%D1.6.1 reset%B2.1.2 load%B2.1.2 inc%B2.1.2 out%D1.6.1",
+		values:    values{"time": 1, "in": -32123, "reset": 0, "load": 1, "inc": 1, "out": 7},
+		expOutput: "| time |   in   |reset|load | inc |  out   |\n| 1    | -32123 |  0  |  1  |  1  |      7 |\n",
+	},
+	{
+		list:      "RAM[0]%D2.6.2 RAM[1]%D2.6.2 RAM[2]%D2.6.2",
+		values:    values{"RAM[0]": 6, "RAM[1]": 7, "RAM[2]": 42},
+		expOutput: "|  RAM[0]  |  RAM[1]  |  RAM[2]  |\n|       6  |       7  |      42  |\n",
+	},
+	// Columns without a format use the default, and long names are cut to the width of their column
+	{
+		list:      "A RAM[16384]%X0.4.0 D%B1.4.1",
+		values:    values{"A": -1, "RAM[16384]": -2, "D": 6},
+		expOutput: "|   A    |RAM[|  D   |\n|     -1 |FFFE| 0110 |\n",
+	},
+}
+
+func TestRecorder(t *testing.T) {
+	for _, test := range recorderTests {
+		columns, err := ParseColumns(test.list)
+		if err != nil {
+			t.Errorf("did not expect an error, but %q returned for %q", err, test.list)
+			continue
+		}
+
+		var output bytes.Buffer
+		r, err := NewRecorder(&output, columns, test.values)
+		if err != nil {
+			t.Errorf("did not expect an error, but %q returned for %q", err, test.list)
+			continue
+		}
+		err = r.Record()
+		if err == nil {
+			err = r.Flush()
+		}
+		if err != nil {
+			t.Errorf("did not expect an error, but %q returned for %q", err, test.list)
+			continue
+		}
+
+		if output.String() != test.expOutput {
+			t.Errorf("expected output\n%s\ngot\n%s", test.expOutput, output.String())
+		}
+	}
+
+	if _, err := NewRecorder(&bytes.Buffer{}, []Column{{Name: "B", Format: 'D', Width: 1}}, values{}); err == nil {
+		t.Errorf("expected an error recording an unknown variable, but none returned")
+	}
+}
+
+func TestParseColumns(t *testing.T) {
+	for _, list := range []string{"", "%D1.6.1", "A%Q1.6.1", "A%D1.6", "A%D1.0.1", "A%D1.x.1", "A%"} {
+		if _, err := ParseColumns(list); err == nil {
+			t.Errorf("expected an error parsing %q, but none returned", list)
+		}
+	}
+}
+
+type compareTest struct {
+	trace   string
+	cmp     string
+	expLine int
+}
+
+var compareTests = []compareTest{
+	{trace: "| a |\n| 1 |\n", cmp: "| a |\n| 1 |\n"},
+	{trace: "| a |\n| 1 |\n", cmp: "| a |\n| * |\n"},
+	// Comparison files written on Windows end their lines with \r
+	{trace: "| a |\n| 1 |", cmp: "| a |\r\n| 1 |\r\n"},
+	{trace: "| a |\n| 1 |\n", cmp: "| a |\n| 2 |\n", expLine: 2},
+	{trace: "| a |\n| 1 |\n", cmp: "| a |\n", expLine: 2},
+	{trace: "| a |\n", cmp: "| a |\n| 1 |\n", expLine: 2},
+}
+
+func TestCompare(t *testing.T) {
+	for _, test := range compareTests {
+		line, err := Compare(strings.NewReader(test.trace), strings.NewReader(test.cmp))
+		if err != nil {
+			t.Errorf("did not expect an error, but %q returned", err)
+			continue
+		}
+		if line != test.expLine {
+			t.Errorf("expected comparing %q with %q to fail at line %d, got %d", test.trace, test.cmp, test.expLine, line)
+		}
+	}
+}
+
+func TestCPU_Value(t *testing.T) {
+	p, err := hack.Assemble(strings.NewReader("@7\nD=A\n@300\nM=D"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := CPU{hack.NewCPU(p)}
+	for i := 0; i < 4; i++ {
+		c.Step()
+	}
+
+	expValues := values{"time": 4, "PC": 4, "A": 300, "D": 7, "RAM[300]": 7}
+	for name, expected := range expValues {
+		value, err := c.Value(name)
+		if err != nil || value != expected {
+			t.Errorf("expected %s to be %d, got %d, %v", name, expected, value, err)
+		}
+	}
+
+	for _, name := range []string{"M", "RAM[32768]", "RAM[x]", "RAM[1"} {
+		if _, err := c.Value(name); err == nil {
+			t.Errorf("expected an error reading %s, but none returned", name)
+		}
+	}
+}
+
+func TestVM_Value(t *testing.T) {
+	lines, err := program.Read("Main.vm", strings.NewReader(`function Main.main 2
+push constant 7
+pop local 1
+push constant 3
+pop temp 2
+push constant 9
+push constant 4
+call Main.main 0`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	machine, err := vm.New(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := VM{machine}
+	machine.RAM[vm.LCL] = 300
+	machine.RAM[vm.SP] = 302
+	// Running the function declaration pushes its two locals
+	for i := 0; i < 7; i++ {
+		err = machine.Step()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expValues := values{"time": 7, "sp": 306, "top": 4, "local": 300, "local[1]": 7, "temp[2]": 3, "RAM[304]": 9}
+	for name, expected := range expValues {
+		value, err := m.Value(name)
+		if err != nil || value != expected {
+			t.Errorf("expected %s to be %d, got %d, %v", name, expected, value, err)
+		}
+	}
+
+	for _, name := range []string{"PC", "sp[0]", "temp[8]", "static[0]", "local[-1]"} {
+		if _, err := m.Value(name); err == nil {
+			t.Errorf("expected an error reading %s, but none returned", name)
+		}
+	}
+}
+
+func TestCPU_Set(t *testing.T) {
+	// Mult.asm's test script sets its operands in RAM[0] and RAM[1] before running it
+	p, err := hack.Assemble(strings.NewReader("@0\nD=M\n@1\nD=D+M\n@2\nM=D"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := CPU{hack.NewCPU(p)}
+	for name, value := range map[string]int16{"RAM[0]": 6, "RAM[1]": -2, "D": 9} {
+		err = c.Set(name, value)
+		if err != nil {
+			t.Fatalf("did not expect an error, but %q returned setting %s", err, name)
+		}
+	}
+	for i := 0; i < 6; i++ {
+		c.Step()
+	}
+
+	if value, _ := c.Value("RAM[2]"); value != 4 {
+		t.Errorf("expected RAM[2] to be 4, got %d", value)
+	}
+
+	for _, name := range []string{"time", "M", "RAM[32768]"} {
+		if err := c.Set(name, 1); err == nil {
+			t.Errorf("expected an error setting %s, but none returned", name)
+		}
+	}
+}
+
+func TestVM_Set(t *testing.T) {
+	lines, err := program.Read("Main.vm", strings.NewReader("function Main.main 0\npush argument 1\npop temp 0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	machine, err := vm.New(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := VM{machine}
+	for _, set := range []struct {
+		name  string
+		value int16
+	}{{"sp", 256}, {"argument", 400}, {"argument[1]", 12}, {"local", 300}, {"local[0]", 5}} {
+		err = m.Set(set.name, set.value)
+		if err != nil {
+			t.Fatalf("did not expect an error, but %q returned setting %s", err, set.name)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		err = machine.Step()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expValues := values{"temp[0]": 12, "RAM[401]": 12, "RAM[300]": 5, "sp": 256}
+	for name, expected := range expValues {
+		value, err := m.Value(name)
+		if err != nil || value != expected {
+			t.Errorf("expected %s to be %d, got %d, %v", name, expected, value, err)
+		}
+	}
+
+	for _, name := range []string{"time", "PC", "sp[0]", "temp[8]"} {
+		if err := m.Set(name, 1); err == nil {
+			t.Errorf("expected an error setting %s, but none returned", name)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package translator
+
+import (
+	"fmt"
+
+	"github.com/ChelseaDH/VMTranslator/command"
+)
+
+// When a safety check fails, its error code is written to SafetyErrorAddress (R15)
+// and the program jumps to the SAFETY_HALT loop, where emulators can detect it.
+const (
+	SafetyErrorAddress = 15
+	SafetyHaltLabel    = "SAFETY_HALT"
+)
+
+// Error codes written to SafetyErrorAddress.
+const (
+	StackOverflow = iota + 1
+	StackUnderflow
+	PointerOutOfRange
+)
+
+const (
+	stackBase   = 256
+	stackLimit  = 2047
+	heapBase    = 2048
+	keyboardMap = 24576
+)
+
+var safetyLabels = map[int]string{
+	StackOverflow:     "SAFETY_STACK_OVERFLOW",
+	StackUnderflow:    "SAFETY_STACK_UNDERFLOW",
+	PointerOutOfRange: "SAFETY_POINTER_OUT_OF_RANGE",
+}
+
+// checkBefore writes the checks that must pass before c runs.
+func (t *Translator) checkBefore(c command.Command) {
+	// return and if-goto pop a value, which must come from the current frame. if-goto is checked before it
+	// jumps, as a check after it would be skipped whenever the jump is taken.
+	switch c.Type() {
+	case command.Return, command.IfGoto:
+		t.checkFrame(1)
+	}
+}
+
+// checkAfter writes the checks on the state left by c.
+func (t *Translator) checkAfter(c command.Command) {
+	switch c.Type() {
+	case command.Push, command.Call, command.Function:
+		t.write(fmt.Sprintf("@SP\nD=M\n@%d\nD=D-A\n@%s\nD;JGT\n", stackLimit, safetyLabels[StackOverflow]))
+
+	case command.Add, command.Sub, command.Eq, command.Gt, command.Lt, command.And, command.Or:
+		t.checkFrame(0)
+
+	case command.Pop:
+		t.checkFrame(0)
+
+		mac := c.(*command.MemoryAccessCommand)
+		if mac.Segment == command.Pointer {
+			t.checkPointer(mac.Index)
+		}
+	}
+}
+
+// checkFrame fails with StackUnderflow when fewer than n values are held on the working stack of the current function,
+// or below the base of the stack outside of any function.
+func (t *Translator) checkFrame(n int) {
+	if t.currentFunc == "" {
+		t.write(fmt.Sprintf("@SP\nD=M\n@%d\nD=D-A\n", stackBase+n))
+	} else {
+		t.write(fmt.Sprintf("@%s\nD=M\n@%d\nD=D+A\n@SP\nD=M-D\n", command.Local.Label(), t.currentLocals+n))
+	}
+	t.write(fmt.Sprintf("@%s\nD;JLT\n", safetyLabels[StackUnderflow]))
+}
+
+// checkPointer fails with PointerOutOfRange when THIS or THAT points outside of the heap, screen and keyboard memory maps.
+func (t *Translator) checkPointer(index int) {
+	segment := command.This.Label()
+	if index == 1 {
+		segment = command.That.Label()
+	}
+
+	t.write(fmt.Sprintf("@%s\nD=M\n@%d\nD=D-A\n@%s\nD;JLT\n", segment, heapBase, safetyLabels[PointerOutOfRange]))
+	t.write(fmt.Sprintf("@%s\nD=M\n@%d\nD=D-A\n@%s\nD;JGT\n", segment, keyboardMap, safetyLabels[PointerOutOfRange]))
+}
+
+func (t *Translator) writeSafetyHandlers() {
+	for code := StackOverflow; code <= PointerOutOfRange; code++ {
+		t.write(fmt.Sprintf("(%s)\n@%d\nD=A\n@SAFETY_FAIL\n0;JMP\n", safetyLabels[code], code))
+	}
+	t.write(fmt.Sprintf("(SAFETY_FAIL)\n@%d\nM=D\n(%s)\n@%s\n0;JMP\n", SafetyErrorAddress, SafetyHaltLabel, SafetyHaltLabel))
+}
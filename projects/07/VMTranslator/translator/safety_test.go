@@ -0,0 +1,138 @@
+package translator
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ChelseaDH/VMTranslator/hack"
+	"github.com/ChelseaDH/VMTranslator/parser"
+)
+
+type safetyTest struct {
+	input     string
+	expChecks []string
+}
+
+var safetyTests = []safetyTest{
+	{
+		input:     "push constant 1",
+		expChecks: []string{"@2047\nD=D-A\n@SAFETY_STACK_OVERFLOW\nD;JGT\n"},
+	},
+	{
+		input:     "add",
+		expChecks: []string{"@LCL\nD=M\n@2\nD=D+A\n@SP\nD=M-D\n@SAFETY_STACK_UNDERFLOW\nD;JLT\n"},
+	},
+	{
+		input: "pop pointer 1",
+		expChecks: []string{
+			"@THAT\nD=M\n@2048\nD=D-A\n@SAFETY_POINTER_OUT_OF_RANGE\nD;JLT\n",
+			"@THAT\nD=M\n@24576\nD=D-A\n@SAFETY_POINTER_OUT_OF_RANGE\nD;JGT\n",
+		},
+	},
+	{
+		input:     "if-goto END",
+		expChecks: []string{"// if-goto END\n@LCL\nD=M\n@3\nD=D+A\n@SP\nD=M-D\n@SAFETY_STACK_UNDERFLOW\nD;JLT\n"},
+	},
+	{
+		input:     "return",
+		expChecks: []string{"// return\n@LCL\nD=M\n@3\nD=D+A\n@SP\nD=M-D\n@SAFETY_STACK_UNDERFLOW\nD;JLT\n"},
+	},
+}
+
+func TestTranslator_Safe(t *testing.T) {
+	for _, test := range safetyTests {
+		var output bytes.Buffer
+		translator := Translator{Output: &output, Namespace: "Test", Safe: true}
+
+		for _, line := range []string{"function Test.f 2", test.input} {
+			c, err := parser.Parse(line)
+			if err != nil {
+				t.Fatalf("unexpected parse error %q for %s", err, line)
+			}
+
+			err = translator.Translate(c)
+			if err != nil {
+				t.Fatalf("unexpected error %q for %s", err, line)
+			}
+		}
+		translator.Terminate()
+
+		for _, exp := range test.expChecks {
+			if !strings.Contains(output.String(), exp) {
+				t.Errorf("expected output to contain %q for %s", exp, test.input)
+			}
+		}
+
+		if !strings.Contains(output.String(), "(SAFETY_FAIL)\n@15\nM=D\n(SAFETY_HALT)\n@SAFETY_HALT\n0;JMP\n") {
+			t.Errorf("expected safety handlers to be written for %s", test.input)
+		}
+	}
+}
+
+// run translates a program with Sys.init, with safety checks if safe is set, and runs it on the CPU emulator until it halts.
+func run(t *testing.T, safe bool, lines []string) *hack.CPU {
+	var output bytes.Buffer
+	translator := Translator{Output: &output, Namespace: "Test", Safe: safe}
+	err := translator.Initialise()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range lines {
+		c, err := parser.Parse(line)
+		if err != nil {
+			t.Fatalf("unexpected parse error %q for %s", err, line)
+		}
+
+		err = translator.Translate(c)
+		if err != nil {
+			t.Fatalf("unexpected error %q for %s", err, line)
+		}
+	}
+	translator.Terminate()
+
+	p, err := hack.Assemble(&output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cpu := hack.NewCPU(p)
+	err = cpu.Run(100000)
+	if err != hack.ErrHalted {
+		t.Fatalf("expected the program to halt, got %v", err)
+	}
+	return cpu
+}
+
+type safetyRunTest struct {
+	lines   []string
+	expCode int16
+}
+
+var safetyRunTests = []safetyRunTest{
+	{
+		// The if-goto pops the caller's THAT, saved in Test.f's frame, so the jump is taken to a loop where
+		// no later check can catch the underflow
+		lines: []string{
+			"function Sys.init 0", "push constant 3000", "pop pointer 1", "call Test.f 0", "label HALT", "goto HALT",
+			"function Test.f 0", "if-goto END", "push constant 0", "return", "label END", "goto END",
+		},
+		expCode: StackUnderflow,
+	},
+	{
+		lines: []string{
+			"function Sys.init 0", "push constant 1", "call Test.f 0", "label HALT", "goto HALT",
+			"function Test.f 0", "push constant 3000", "if-goto END", "push constant 0", "return", "label END", "goto END",
+		},
+		expCode: 0,
+	},
+}
+
+func TestTranslator_SafeRun(t *testing.T) {
+	for _, test := range safetyRunTests {
+		cpu := run(t, true, test.lines)
+		if cpu.RAM[SafetyErrorAddress] != test.expCode {
+			t.Errorf("expected error code %d, got %d for %v", test.expCode, cpu.RAM[SafetyErrorAddress], test.lines)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"strconv"
 	"strings"
 
 	"github.com/ChelseaDH/VMTranslator/command"
@@ -11,16 +12,38 @@ import (
 
 const tempIndex = 5
 
+// popAddress holds the address a pop to local, argument, this or that writes to while the value is popped.
+// It must not be in the temp segment, which the program may still be using.
+const popAddress = "R13"
+
 type Translator struct {
-	Output      io.Writer
-	Namespace   string
-	currentFunc string
-	jumpCount   int
-	returnCount int
+	Output    io.Writer
+	Namespace string
+	// Safe enables runtime checks of the stack and pointer segment, see safety.go
+	Safe          bool
+	currentFunc   string
+	currentLocals int
+	jumpCount     int
+	returnCount   int
+}
+
+// SetNamespace starts translating a new file. Generated labels are scoped to the namespace and numbered from zero,
+// so each file translates the same whether it shares a Translator with other files or has one of its own.
+func (t *Translator) SetNamespace(namespace string) {
+	t.Namespace = namespace
+	t.currentFunc = ""
+	t.currentLocals = 0
+	t.jumpCount = 0
+	t.returnCount = 0
 }
 
 func (t *Translator) Translate(c command.Command) error {
-	t.write(fmt.Sprintf("// %s\n", c.String()))
+	t.write("// " + c.String() + "\n")
+
+	if t.Safe {
+		t.checkBefore(c)
+		defer t.checkAfter(c)
+	}
 
 	switch c.Type() {
 	case command.Add:
@@ -85,6 +108,7 @@ func (t *Translator) Translate(c command.Command) error {
 	case command.Function:
 		fc := c.(*command.FunctionCommand)
 		t.currentFunc = fc.Name
+		t.currentLocals = fc.Args
 		t.defineFunction(fc)
 		return nil
 
@@ -103,7 +127,7 @@ func (t *Translator) Translate(c command.Command) error {
 }
 
 func (t *Translator) write(input string) {
-	_, err := fmt.Fprintf(t.Output, "%s", input)
+	_, err := io.WriteString(t.Output, input)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -111,6 +135,9 @@ func (t *Translator) write(input string) {
 
 func (t *Translator) Terminate() {
 	t.write("(END)\n@END\n0;JMP\n")
+	if t.Safe {
+		t.writeSafetyHandlers()
+	}
 }
 
 func (t *Translator) Initialise() error {
@@ -126,7 +153,7 @@ func (t *Translator) translateBinaryExpression(operator string, jump string) {
 	t.popStackIntoD()
 	t.write("@temp\nM=D\n")
 
-	t.write(fmt.Sprintf("@SP\nA=M-1\nD=M\n@temp\nD=D%sM\n", operator))
+	t.write("@SP\nA=M-1\nD=M\n@temp\nD=D" + operator + "M\n")
 	if jump != "" {
 		t.jump(jump)
 	}
@@ -134,7 +161,7 @@ func (t *Translator) translateBinaryExpression(operator string, jump string) {
 }
 
 func (t *Translator) translateUnaryExpression(operator string) {
-	t.write(fmt.Sprintf("@SP\nA=M-1\nM=%sM\n", operator))
+	t.write("@SP\nA=M-1\nM=" + operator + "M\n")
 }
 
 func (t *Translator) translatePop(c *command.MemoryAccessCommand) error {
@@ -142,16 +169,16 @@ func (t *Translator) translatePop(c *command.MemoryAccessCommand) error {
 
 	switch c.Segment {
 	case command.Local, command.Argument, command.This, command.That:
-		t.write(fmt.Sprintf("@%d\nD=A\n@%s\nD=D+M\n@%d\nM=D\n", c.Index, c.Segment.Label(), tempIndex))
-		loc = fmt.Sprintf("%d\nA=M", tempIndex)
+		t.write("@" + strconv.Itoa(c.Index) + "\nD=A\n@" + c.Segment.Label() + "\nD=D+M\n@" + popAddress + "\nM=D\n")
+		loc = popAddress + "\nA=M"
 		break
 
 	case command.Static:
-		loc = fmt.Sprintf("%s.%d", t.Namespace, c.Index)
+		loc = t.Namespace + "." + strconv.Itoa(c.Index)
 		break
 
 	case command.Temp:
-		loc = fmt.Sprintf("%d", tempIndex+c.Index)
+		loc = strconv.Itoa(tempIndex + c.Index)
 		break
 
 	case command.Pointer:
@@ -167,7 +194,7 @@ func (t *Translator) translatePop(c *command.MemoryAccessCommand) error {
 	}
 
 	t.popStackIntoD()
-	t.write(fmt.Sprintf("@%s\nM=D\n", loc))
+	t.write("@" + loc + "\nM=D\n")
 	return nil
 }
 
@@ -176,22 +203,22 @@ func (t *Translator) translatePush(c *command.MemoryAccessCommand) error {
 
 	switch c.Segment {
 	case command.Local, command.Argument, command.This, command.That:
-		loc = fmt.Sprintf("%d\nD=A\n@%s\nA=M+D", c.Index, c.Segment.Label())
+		loc = strconv.Itoa(c.Index) + "\nD=A\n@" + c.Segment.Label() + "\nA=M+D"
 		d = "M"
 		break
 
 	case command.Constant:
-		loc = fmt.Sprintf("%d", c.Index)
+		loc = strconv.Itoa(c.Index)
 		d = "A"
 		break
 
 	case command.Static:
-		loc = fmt.Sprintf("%s.%d", t.Namespace, c.Index)
+		loc = t.Namespace + "." + strconv.Itoa(c.Index)
 		d = "M"
 		break
 
 	case command.Temp:
-		loc = fmt.Sprintf("%d", tempIndex+c.Index)
+		loc = strconv.Itoa(tempIndex + c.Index)
 		d = "M"
 		break
 
@@ -209,7 +236,7 @@ func (t *Translator) translatePush(c *command.MemoryAccessCommand) error {
 		return fmt.Errorf("%s is not a valid segment type for push", c.Segment.String())
 	}
 
-	t.write(fmt.Sprintf("@%s\nD=%s\n", loc, d))
+	t.write("@" + loc + "\nD=" + d + "\n")
 	t.pushDOntoStack()
 	return nil
 }
@@ -219,26 +246,29 @@ func (t *Translator) incrementSP() {
 }
 
 func (t *Translator) jump(jumpType string) {
-	t.write(fmt.Sprintf("@TRUE%d\nD;%s\nD=0\n@FALSE%d\n0;JMP\n(TRUE%d)\nD=-1\n(FALSE%d)\n", t.jumpCount, jumpType, t.jumpCount, t.jumpCount, t.jumpCount))
+	// VM labels are uppercased, so lowercase names cannot clash with them
+	trueLabel := t.Namespace + "$true." + strconv.Itoa(t.jumpCount)
+	falseLabel := t.Namespace + "$false." + strconv.Itoa(t.jumpCount)
+	t.write("@" + trueLabel + "\nD;" + jumpType + "\nD=0\n@" + falseLabel + "\n0;JMP\n(" + trueLabel + ")\nD=-1\n(" + falseLabel + ")\n")
 	t.jumpCount++
 }
 
 func (t *Translator) addLabel(bc *command.BranchingCommand) {
-	t.write(fmt.Sprintf("(%s$%s)\n", t.currentFunc, strings.ToUpper(bc.Label)))
+	t.write("(" + t.currentFunc + "$" + strings.ToUpper(bc.Label) + ")\n")
 }
 
 func (t *Translator) unconditionalGoto(bc *command.BranchingCommand) {
-	t.write(fmt.Sprintf("@%s$%s\n0;JMP\n", t.currentFunc, strings.ToUpper(bc.Label)))
+	t.write("@" + t.currentFunc + "$" + strings.ToUpper(bc.Label) + "\n0;JMP\n")
 }
 
 func (t *Translator) conditionalGoto(bc *command.BranchingCommand) {
 	t.popStackIntoD()
-	t.write(fmt.Sprintf("@%s$%s\nD;JNE\n", t.currentFunc, strings.ToUpper(bc.Label)))
+	t.write("@" + t.currentFunc + "$" + strings.ToUpper(bc.Label) + "\nD;JNE\n")
 }
 
 func (t *Translator) defineFunction(fc *command.FunctionCommand) {
 	// Function label
-	t.write(fmt.Sprintf("(%s)\n", fc.Name))
+	t.write("(" + fc.Name + ")\n")
 	// Initialise local variables to 0
 	for i := 0; i < fc.Args; i++ {
 		t.write("@SP\nA=M\nM=0\n")
@@ -247,26 +277,26 @@ func (t *Translator) defineFunction(fc *command.FunctionCommand) {
 }
 
 func (t *Translator) callFunction(fc *command.FunctionCommand) {
-	returnLabel := fmt.Sprintf("%s$ret.%d", t.Namespace, t.returnCount)
+	returnLabel := t.Namespace + "$ret." + strconv.Itoa(t.returnCount)
 
 	// Push return address of caller to stack
-	t.write(fmt.Sprintf("@%s\nD=A\n", returnLabel))
+	t.write("@" + returnLabel + "\nD=A\n")
 	t.pushDOntoStack()
 	// Save state of caller
 	t.saveCallerSegments()
 	// ARG = SP - 5 - fc.Args && LCL = SP
-	t.write(fmt.Sprintf("@SP\nD=M\n@%d\nD=D-A\n@%d\nD=D-A\n@%s\nM=D\n", 5, fc.Args, command.Argument.Label()))
+	t.write("@SP\nD=M\n@5\nD=D-A\n@" + strconv.Itoa(fc.Args) + "\nD=D-A\n@ARG\nM=D\n")
 	// LCL = AP
-	t.write(fmt.Sprintf("@SP\nD=M\n@%s\nM=D\n", command.Local.Label()))
+	t.write("@SP\nD=M\n@LCL\nM=D\n")
 	// Jump to target function
-	t.write(fmt.Sprintf("@%s\n0;JMP\n", fc.Name))
+	t.write("@" + fc.Name + "\n0;JMP\n")
 	// Write return address label
-	t.write(fmt.Sprintf("(%s)\n", returnLabel))
+	t.write("(" + returnLabel + ")\n")
 	t.returnCount = t.returnCount + 1
 }
 
 func (t *Translator) saveSingleSegment(segment command.Segment) {
-	t.write(fmt.Sprintf("@%s\nD=M\n", segment.Label()))
+	t.write("@" + segment.Label() + "\nD=M\n")
 	t.pushDOntoStack()
 }
 
@@ -279,22 +309,22 @@ func (t *Translator) saveCallerSegments() {
 
 func (t *Translator) translateReturn() {
 	// Set temp endFrame var
-	t.write(fmt.Sprintf("@%s\nD=M\n@%s\nM=D\n", command.Local.Label(), "endFrame"))
+	t.write("@LCL\nD=M\n@endFrame\nM=D\n")
 	// Get return address of caller
-	t.write(fmt.Sprintf("@%d\nA=D-A\nD=M\n@%s\nM=D\n", 5, "retAddr"))
+	t.write("@5\nA=D-A\nD=M\n@retAddr\nM=D\n")
 	// *ARG = pop()
 	t.popStackIntoD()
-	t.write(fmt.Sprintf("@%s\nA=M\nM=D\n", command.Argument.Label()))
+	t.write("@ARG\nA=M\nM=D\n")
 	// SP = ARG + 1
-	t.write(fmt.Sprintf("@%s\nD=M+1\n@SP\nM=D\n", command.Argument.Label()))
+	t.write("@ARG\nD=M+1\n@SP\nM=D\n")
 	// Restore state of caller
 	t.restoreCallerSegments()
 	// Goto return address
-	t.write(fmt.Sprintf("@%s\nA=M\n0;JMP\n", "retAddr"))
+	t.write("@retAddr\nA=M\n0;JMP\n")
 }
 
 func (t *Translator) restoreSingleSegment(segment command.Segment) {
-	t.write(fmt.Sprintf("@%s\nAM=M-1\nD=M\n@%s\nM=D\n", "endFrame", segment.Label()))
+	t.write("@endFrame\nAM=M-1\nD=M\n@" + segment.Label() + "\nM=D\n")
 }
 
 func (t *Translator) restoreCallerSegments() {
@@ -0,0 +1,80 @@
+package translator
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ChelseaDH/VMTranslator/command"
+	"github.com/ChelseaDH/VMTranslator/parser"
+)
+
+// namespacedCommands are the commands read from a single file.
+type namespacedCommands struct {
+	namespace string
+	commands  []command.Command
+}
+
+func BenchmarkTranslate(b *testing.B) {
+	files, err := filepath.Glob("../testfiles/benchmark/*.vm")
+	if err != nil || len(files) == 0 {
+		b.Fatalf("no benchmark programs found: %v", err)
+	}
+
+	var programs []namespacedCommands
+	for _, name := range files {
+		file, err := os.Open(name)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		program := namespacedCommands{namespace: strings.TrimSuffix(filepath.Base(name), ".vm")}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			c, err := parser.Parse(scanner.Text())
+			if err != nil {
+				b.Fatal(err)
+			}
+			if c != nil {
+				program.commands = append(program.commands, c)
+			}
+		}
+		file.Close()
+		programs = append(programs, program)
+	}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		t := Translator{Output: io.Discard}
+		// SetNamespace resets the label counters, so it is only called when the file changes
+		for _, program := range programs {
+			t.SetNamespace(program.namespace)
+			for _, c := range program.commands {
+				err := t.Translate(c)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+		t.Terminate()
+	}
+}
+
+func TestTranslator_PopKeepsTemp(t *testing.T) {
+	// Popping to local, argument, this and that once staged the target address in temp 0, losing its value
+	cpu := run(t, false, []string{
+		"function Sys.init 1",
+		"push constant 7", "pop temp 0",
+		"push constant 9", "pop local 0",
+		"push temp 0", "pop static 0",
+		"push local 0", "pop static 1",
+		"label END", "goto END",
+	})
+
+	if cpu.RAM[16] != 7 || cpu.RAM[17] != 9 {
+		t.Errorf("expected temp 0 to keep 7 and local 0 to be 9, got %d and %d", cpu.RAM[16], cpu.RAM[17])
+	}
+}
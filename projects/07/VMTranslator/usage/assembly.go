@@ -0,0 +1,87 @@
+package usage
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+var predefinedSymbols = map[string]bool{
+	"SP": true, "LCL": true, "ARG": true, "THIS": true, "THAT": true, "SCREEN": true, "KBD": true,
+	"R0": true, "R1": true, "R2": true, "R3": true, "R4": true, "R5": true, "R6": true, "R7": true,
+	"R8": true, "R9": true, "R10": true, "R11": true, "R12": true, "R13": true, "R14": true, "R15": true,
+}
+
+// Assembly is an io.Writer that measures the Hack assembly written through it,
+// counting instructions and the variables the assembler will allocate.
+type Assembly struct {
+	instructions int
+	labels       map[string]bool
+	symbols      []string
+	seen         map[string]bool
+	partial      []byte
+}
+
+func (a *Assembly) Write(p []byte) (int, error) {
+	data := append(a.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i == -1 {
+			break
+		}
+		a.line(string(data[:i]))
+		data = data[i+1:]
+	}
+	a.partial = append([]byte(nil), data...)
+
+	return len(p), nil
+}
+
+func (a *Assembly) line(line string) {
+	if i := strings.Index(line, "//"); i != -1 {
+		line = line[:i]
+	}
+	line = strings.TrimSpace(line)
+
+	switch {
+	case line == "":
+		return
+
+	case strings.HasPrefix(line, "("):
+		if a.labels == nil {
+			a.labels = make(map[string]bool)
+		}
+		a.labels[strings.Trim(line, "()")] = true
+		return
+
+	case strings.HasPrefix(line, "@"):
+		symbol := line[1:]
+		if _, err := strconv.Atoi(symbol); err != nil && !predefinedSymbols[symbol] && !a.seen[symbol] {
+			if a.seen == nil {
+				a.seen = make(map[string]bool)
+			}
+			a.seen[symbol] = true
+			a.symbols = append(a.symbols, symbol)
+		}
+	}
+
+	a.instructions++
+}
+
+// Instructions returns the number of instructions written so far.
+func (a *Assembly) Instructions() int {
+	return a.instructions
+}
+
+// Variables returns the symbols written so far that are neither predefined nor declared as labels,
+// in the order the assembler will allocate them.
+func (a *Assembly) Variables() []string {
+	var variables []string
+	for _, symbol := range a.symbols {
+		if !a.labels[symbol] {
+			variables = append(variables, symbol)
+		}
+	}
+
+	return variables
+}
@@ -0,0 +1,185 @@
+package usage
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ChelseaDH/VMTranslator/command"
+	"github.com/ChelseaDH/VMTranslator/program"
+)
+
+const (
+	// VariableBase is the first RAM address the Hack assembler allocates to variables.
+	VariableBase = 16
+	// StackBase is the RAM address the stack starts at, which variables must not reach.
+	StackBase = 256
+	// MaxVariables is the number of RAM words available to variables, including static variables.
+	MaxVariables = StackBase - VariableBase
+	// MaxROM is the number of instructions the Hack ROM can hold.
+	MaxROM = 32768
+)
+
+// FileStatics counts the distinct static variables used by a single .vm file.
+type FileStatics struct {
+	File    string
+	Statics int
+}
+
+// FunctionFrame records the number of locals a function declares and the number of arguments it takes,
+// which is the greater of the highest argument it reads and the most it is called with.
+type FunctionFrame struct {
+	Name      string
+	Locals    int
+	Arguments int
+}
+
+// Report describes the memory a VM program uses.
+type Report struct {
+	Files     []FileStatics
+	Functions []FunctionFrame
+	// ROM is the number of instructions in the translated program, or -1 if it is not known.
+	ROM int
+	// Variables are the symbols other than statics that the Hack assembler will allocate RAM to,
+	// such as the translator's own working variables and calls to functions that are never declared.
+	Variables []string
+}
+
+// Analyse builds a report of the statics and stack frames used by lines.
+// ROM size and assembler variables are filled in separately once the program has been translated.
+func Analyse(lines []program.Line) *Report {
+	r := &Report{ROM: -1}
+
+	statics := make(map[string]map[int]bool)
+	for _, line := range lines {
+		mac, ok := line.Command.(*command.MemoryAccessCommand)
+		if !ok || mac.Segment != command.Static {
+			continue
+		}
+
+		if _, ok := statics[line.File]; !ok {
+			statics[line.File] = make(map[int]bool)
+			r.Files = append(r.Files, FileStatics{File: line.File})
+		}
+		statics[line.File][mac.Index] = true
+	}
+
+	for i := range r.Files {
+		r.Files[i].Statics = len(statics[r.Files[i].File])
+	}
+
+	callArgs := make(map[string]int)
+	for _, line := range lines {
+		if fc, ok := line.Command.(*command.FunctionCommand); ok && fc.Type() == command.Call && fc.Args > callArgs[fc.Name] {
+			callArgs[fc.Name] = fc.Args
+		}
+	}
+
+	for _, f := range program.Functions(lines) {
+		if f.Name == "" {
+			continue
+		}
+
+		frame := FunctionFrame{Name: f.Name, Locals: f.Locals, Arguments: callArgs[f.Name]}
+		for _, line := range f.Body {
+			if mac, ok := line.Command.(*command.MemoryAccessCommand); ok && mac.Segment == command.Argument && mac.Index+1 > frame.Arguments {
+				frame.Arguments = mac.Index + 1
+			}
+		}
+		r.Functions = append(r.Functions, frame)
+	}
+
+	return r
+}
+
+// AddAssembly records the size of the translated program and the variables the assembler will allocate for it.
+// Static variables, named after their file and index, are already counted per file and are left out.
+func (r *Report) AddAssembly(a *Assembly) {
+	r.ROM = a.Instructions()
+
+	namespaces := make(map[string]bool)
+	for _, f := range r.Files {
+		namespaces[strings.TrimSuffix(path.Base(f.File), ".vm")] = true
+	}
+
+	r.Variables = nil
+	for _, v := range a.Variables() {
+		if i := strings.LastIndex(v, "."); i != -1 && namespaces[v[:i]] {
+			if _, err := strconv.Atoi(v[i+1:]); err == nil {
+				continue
+			}
+		}
+		r.Variables = append(r.Variables, v)
+	}
+}
+
+// Statics returns the total number of static variables across all files.
+func (r *Report) Statics() int {
+	total := 0
+	for _, f := range r.Files {
+		total += f.Statics
+	}
+
+	return total
+}
+
+// Check returns an error if the program uses more variables or instructions than the Hack platform has room for.
+func (r *Report) Check() error {
+	if used := r.Statics() + len(r.Variables); used > MaxVariables {
+		return fmt.Errorf("program uses %d static variables and %d other assembler variables, but only %d fit between RAM %d and the stack at RAM %d",
+			r.Statics(), len(r.Variables), MaxVariables, VariableBase, StackBase)
+	}
+
+	if r.ROM > MaxROM {
+		return fmt.Errorf("program is %d instructions long, but the ROM only holds %d", r.ROM, MaxROM)
+	}
+
+	return nil
+}
+
+// Write prints the report in a human readable form.
+func (r *Report) Write(w io.Writer) error {
+	var b strings.Builder
+
+	width := len("assembler variables")
+	for _, f := range r.Files {
+		if len(path.Base(f.File)) > width {
+			width = len(path.Base(f.File))
+		}
+	}
+	for _, f := range r.Functions {
+		if len(f.Name) > width {
+			width = len(f.Name)
+		}
+	}
+
+	b.WriteString("Statics:\n")
+	for _, f := range r.Files {
+		b.WriteString(fmt.Sprintf("  %-*s %6d\n", width, path.Base(f.File), f.Statics))
+	}
+	b.WriteString(fmt.Sprintf("  %-*s %6d\n", width, "total", r.Statics()))
+
+	if len(r.Variables) > 0 {
+		variables := append([]string(nil), r.Variables...)
+		sort.Strings(variables)
+		b.WriteString(fmt.Sprintf("  %-*s %6d (%s)\n", width, "assembler variables", len(variables), strings.Join(variables, ", ")))
+	}
+
+	used := r.Statics() + len(r.Variables)
+	b.WriteString(fmt.Sprintf("  %d of %d words used (RAM %d-%d)\n\n", used, MaxVariables, VariableBase, StackBase-1))
+
+	b.WriteString(fmt.Sprintf("%-*s %6s %9s\n", width+2, "Functions:", "locals", "arguments"))
+	for _, f := range r.Functions {
+		b.WriteString(fmt.Sprintf("  %-*s %6d %9d\n", width, f.Name, f.Locals, f.Arguments))
+	}
+
+	if r.ROM >= 0 {
+		b.WriteString(fmt.Sprintf("\nROM: %d of %d instructions\n", r.ROM, MaxROM))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,112 @@
+package usage
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ChelseaDH/VMTranslator/program"
+	"github.com/ChelseaDH/VMTranslator/translator"
+)
+
+func TestAnalyse(t *testing.T) {
+	lines, err := program.Read("Main.vm", strings.NewReader(`function Main.main 2
+push static 0
+push static 3
+push static 0
+call Main.add 2
+pop static 1
+return
+function Main.add 0
+push argument 0
+push argument 1
+add
+return`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := Analyse(lines)
+	if !reflect.DeepEqual(r.Files, []FileStatics{{File: "Main.vm", Statics: 3}}) {
+		t.Errorf("unexpected statics %v", r.Files)
+	}
+
+	expFunctions := []FunctionFrame{{Name: "Main.main", Locals: 2, Arguments: 0}, {Name: "Main.add", Locals: 0, Arguments: 2}}
+	if !reflect.DeepEqual(r.Functions, expFunctions) {
+		t.Errorf("unexpected functions %v", r.Functions)
+	}
+
+	var assembly Assembly
+	tr := translator.Translator{Output: &assembly, Namespace: "Main"}
+	for _, line := range lines {
+		err = tr.Translate(line.Command)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	tr.Terminate()
+
+	r.AddAssembly(&assembly)
+	if !reflect.DeepEqual(r.Variables, []string{"endFrame", "retAddr", "temp"}) {
+		t.Errorf("unexpected assembler variables %v", r.Variables)
+	}
+	if r.ROM <= 0 {
+		t.Errorf("expected the ROM size to be counted, got %d", r.ROM)
+	}
+
+	err = r.Check()
+	if err != nil {
+		t.Errorf("did not expect an error, but %q returned", err)
+	}
+}
+
+type assemblyTest struct {
+	input           string
+	expInstructions int
+	expVariables    []string
+}
+
+var assemblyTests = []assemblyTest{
+	{input: "// comment\n@2\nD=A\n", expInstructions: 2},
+	{input: "(LOOP)\n@i\nM=M+1\n@LOOP\n0;JMP\n", expInstructions: 4, expVariables: []string{"i"}},
+	{input: "@SP\nA=M\n@R13\n@x // trailing\n@x\n@KBD", expInstructions: 6, expVariables: []string{"x"}},
+}
+
+func TestAssembly(t *testing.T) {
+	for _, test := range assemblyTests {
+		var a Assembly
+		// Write a byte at a time to check lines split across writes are counted once
+		for i := range test.input {
+			_, _ = a.Write([]byte{test.input[i]})
+		}
+		_, _ = a.Write([]byte("\n"))
+
+		if a.Instructions() != test.expInstructions {
+			t.Errorf("expected %d instructions but got %d for %q", test.expInstructions, a.Instructions(), test.input)
+		}
+		if !reflect.DeepEqual(a.Variables(), test.expVariables) {
+			t.Errorf("expected variables %v but got %v for %q", test.expVariables, a.Variables(), test.input)
+		}
+	}
+}
+
+func TestReport_Check(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i <= MaxVariables; i++ {
+		b.WriteString(fmt.Sprintf("push static %d\n", i))
+	}
+
+	lines, err := program.Read("Main.vm", strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Analyse(lines).Check(); err == nil {
+		t.Errorf("expected an error but none returned for %d statics", MaxVariables+1)
+	}
+
+	if err := (&Report{ROM: MaxROM + 1}).Check(); err == nil {
+		t.Errorf("expected an error but none returned for %d instructions", MaxROM+1)
+	}
+}
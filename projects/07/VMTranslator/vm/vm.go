@@ -0,0 +1,384 @@
+// Package vm runs VM programs directly, one command at a time, without translating them to Hack assembly first.
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/ChelseaDH/VMTranslator/command"
+	"github.com/ChelseaDH/VMTranslator/program"
+)
+
+const (
+	// StaticBase is the RAM address of the first static variable, matching where the Hack assembler allocates them.
+	StaticBase = 16
+	// StackBase is the RAM address the stack starts at.
+	StackBase = 256
+	// TempBase is the RAM address of temp 0.
+	TempBase = 5
+	// PointerBase is the RAM address of pointer 0, which is THIS.
+	PointerBase = 3
+	// Keyboard is the RAM address of the keyboard register.
+	Keyboard = 24576
+	// RAMSize is the number of words in the Hack RAM.
+	RAMSize = 32768
+)
+
+// RAM addresses of the stack and segment pointers.
+const (
+	SP = iota
+	LCL
+	ARG
+	THIS
+	THAT
+)
+
+// ErrFinished is returned by Step and Run once the program has run past its last command,
+// or its first function has returned.
+var ErrFinished = errors.New("program finished")
+
+// ErrHalted is returned by Run when the program reaches a goto that jumps straight back to itself, or calls Sys.halt.
+var ErrHalted = errors.New("program halted")
+
+// Frame is a function call in progress.
+type Frame struct {
+	Function string
+	// Return is the index of the command the function returns to.
+	Return int
+}
+
+// Machine runs a VM program held as a list of lines, keeping the Hack RAM layout
+// so that the stack, segments and heap are in the same places they would be after translation.
+type Machine struct {
+	Lines []program.Line
+	RAM   [RAMSize]int16
+	// PC is the index in Lines of the next command to run.
+	PC int
+	// Steps counts the commands executed so far.
+	Steps uint64
+	// Frames is the call stack, with the innermost call last.
+	Frames []Frame
+
+	// KeyboardInput, if set, is called to read the keyboard register instead of RAM[Keyboard].
+	KeyboardInput func() int16
+
+	functions map[string]int
+	labels    map[string]int
+	statics   map[string]int
+	// scopes holds the name of the function each line belongs to
+	scopes []string
+}
+
+// New returns a Machine ready to run lines. If the program declares Sys.init then it is called first,
+// as the bootstrap code does, otherwise the program starts at its first command.
+func New(lines []program.Line) (*Machine, error) {
+	m := &Machine{
+		Lines:     lines,
+		functions: make(map[string]int),
+		labels:    make(map[string]int),
+		statics:   make(map[string]int),
+		scopes:    make([]string, len(lines)),
+	}
+
+	scope := ""
+	for i, line := range lines {
+		switch c := line.Command.(type) {
+		case *command.FunctionCommand:
+			if c.Type() != command.Function {
+				break
+			}
+			if _, ok := m.functions[c.Name]; ok {
+				return nil, fmt.Errorf("%s: function %s is declared more than once", line.Position(), c.Name)
+			}
+			m.functions[c.Name] = i
+			scope = c.Name
+
+		case *command.BranchingCommand:
+			if c.Type() == command.Label {
+				m.labels[scope+"$"+c.Label] = i
+			}
+
+		case *command.MemoryAccessCommand:
+			if c.Segment == command.Static {
+				m.staticAddress(line.File, c.Index)
+			}
+		}
+		m.scopes[i] = scope
+	}
+
+	m.RAM[SP] = StackBase
+	if _, ok := m.functions["Sys.init"]; ok {
+		err := m.call("Sys.init", 0, len(lines))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// staticAddress returns the address of a static variable of file, allocating them in order of first use
+// from StaticBase as the Hack assembler does.
+func (m *Machine) staticAddress(file string, index int) int {
+	name := fmt.Sprintf("%s.%d", strings.TrimSuffix(path.Base(file), ".vm"), index)
+	address, ok := m.statics[name]
+	if !ok {
+		address = StaticBase + len(m.statics)
+		m.statics[name] = address
+	}
+
+	return address
+}
+
+// StaticAddress returns the RAM address of static variable index of file, if the program uses it.
+func (m *Machine) StaticAddress(file string, index int) (int, bool) {
+	address, ok := m.statics[fmt.Sprintf("%s.%d", strings.TrimSuffix(path.Base(file), ".vm"), index)]
+	return address, ok
+}
+
+// Function returns the name of the function the command at index belongs to.
+func (m *Machine) Function(index int) string {
+	if index < 0 || index >= len(m.scopes) {
+		return ""
+	}
+	return m.scopes[index]
+}
+
+// Finished reports whether the program has no commands left to run.
+func (m *Machine) Finished() bool {
+	return m.PC < 0 || m.PC >= len(m.Lines)
+}
+
+// Halted reports whether the program is running Sys.halt, or its next command is a goto to the label
+// immediately before it, the loop hand written programs finish in.
+func (m *Machine) Halted() bool {
+	if m.Finished() {
+		return false
+	}
+
+	if m.scopes[m.PC] == "Sys.halt" {
+		return true
+	}
+
+	bc, ok := m.Lines[m.PC].Command.(*command.BranchingCommand)
+	if !ok || bc.Type() != command.Goto {
+		return false
+	}
+
+	target, ok := m.labels[m.scopes[m.PC]+"$"+bc.Label]
+	return ok && target == m.PC-1
+}
+
+func (m *Machine) read(address int16) int16 {
+	a := uint16(address) & 0x7fff
+	if a == Keyboard && m.KeyboardInput != nil {
+		return m.KeyboardInput()
+	}
+	return m.RAM[a]
+}
+
+func (m *Machine) write(address int16, value int16) {
+	m.RAM[uint16(address)&0x7fff] = value
+}
+
+func (m *Machine) push(value int16) {
+	m.write(m.RAM[SP], value)
+	m.RAM[SP]++
+}
+
+func (m *Machine) pop() int16 {
+	m.RAM[SP]--
+	return m.read(m.RAM[SP])
+}
+
+// Step executes the command at PC.
+func (m *Machine) Step() error {
+	if m.Finished() {
+		return ErrFinished
+	}
+
+	line := m.Lines[m.PC]
+	m.PC++
+	m.Steps++
+
+	err := m.execute(line.Command)
+	if err != nil {
+		return fmt.Errorf("%s: %s", line.Position(), err)
+	}
+
+	return nil
+}
+
+// Run executes commands until the program finishes or halts, or until limit commands have run if limit is not 0.
+func (m *Machine) Run(limit uint64) error {
+	for n := uint64(0); limit == 0 || n < limit; n++ {
+		if m.Halted() {
+			return ErrHalted
+		}
+
+		err := m.Step()
+		if err != nil {
+			return err
+		}
+	}
+
+	return fmt.Errorf("stopped after %d commands", limit)
+}
+
+func (m *Machine) execute(c command.Command) error {
+	switch c.Type() {
+	case command.Add:
+		y, x := m.pop(), m.pop()
+		m.push(x + y)
+	case command.Sub:
+		y, x := m.pop(), m.pop()
+		m.push(x - y)
+	case command.Neg:
+		m.push(-m.pop())
+	case command.Eq:
+		y, x := m.pop(), m.pop()
+		m.push(boolean(x == y))
+	case command.Gt:
+		y, x := m.pop(), m.pop()
+		m.push(boolean(x > y))
+	case command.Lt:
+		y, x := m.pop(), m.pop()
+		m.push(boolean(x < y))
+	case command.And:
+		y, x := m.pop(), m.pop()
+		m.push(x & y)
+	case command.Or:
+		y, x := m.pop(), m.pop()
+		m.push(x | y)
+	case command.Not:
+		m.push(^m.pop())
+
+	case command.Push:
+		mac := c.(*command.MemoryAccessCommand)
+		if mac.Segment == command.Constant {
+			m.push(int16(mac.Index))
+			return nil
+		}
+
+		address, err := m.address(mac)
+		if err != nil {
+			return err
+		}
+		m.push(m.read(address))
+
+	case command.Pop:
+		mac := c.(*command.MemoryAccessCommand)
+		address, err := m.address(mac)
+		if err != nil {
+			return err
+		}
+		m.write(address, m.pop())
+
+	case command.Label:
+
+	case command.Goto:
+		return m.jump(c.(*command.BranchingCommand))
+
+	case command.IfGoto:
+		if m.pop() != 0 {
+			return m.jump(c.(*command.BranchingCommand))
+		}
+
+	case command.Function:
+		for i := 0; i < c.(*command.FunctionCommand).Args; i++ {
+			m.push(0)
+		}
+
+	case command.Call:
+		fc := c.(*command.FunctionCommand)
+		return m.call(fc.Name, fc.Args, m.PC)
+
+	case command.Return:
+		m.ret()
+
+	default:
+		return fmt.Errorf("cannot run command of type %s", c.Type())
+	}
+
+	return nil
+}
+
+// address returns the RAM address a push or pop of a memory segment accesses.
+func (m *Machine) address(mac *command.MemoryAccessCommand) (int16, error) {
+	index := int16(mac.Index)
+
+	switch mac.Segment {
+	case command.Local:
+		return m.RAM[LCL] + index, nil
+	case command.Argument:
+		return m.RAM[ARG] + index, nil
+	case command.This:
+		return m.RAM[THIS] + index, nil
+	case command.That:
+		return m.RAM[THAT] + index, nil
+	case command.Pointer:
+		return PointerBase + index, nil
+	case command.Temp:
+		return TempBase + index, nil
+	case command.Static:
+		return int16(m.staticAddress(m.Lines[m.PC-1].File, mac.Index)), nil
+	default:
+		return 0, fmt.Errorf("%s is not a valid segment type for pop", mac.Segment.String())
+	}
+}
+
+func (m *Machine) jump(bc *command.BranchingCommand) error {
+	target, ok := m.labels[m.scopes[m.PC-1]+"$"+bc.Label]
+	if !ok {
+		return fmt.Errorf("label %s is not declared in %s", bc.Label, m.scopes[m.PC-1])
+	}
+
+	m.PC = target
+	return nil
+}
+
+// call saves the caller's frame and jumps to the named function, which returns to ret.
+func (m *Machine) call(name string, args int, ret int) error {
+	target, ok := m.functions[name]
+	if !ok {
+		return fmt.Errorf("call to undefined function %s", name)
+	}
+
+	m.push(int16(ret))
+	m.push(m.RAM[LCL])
+	m.push(m.RAM[ARG])
+	m.push(m.RAM[THIS])
+	m.push(m.RAM[THAT])
+	m.RAM[ARG] = m.RAM[SP] - 5 - int16(args)
+	m.RAM[LCL] = m.RAM[SP]
+
+	m.Frames = append(m.Frames, Frame{Function: name, Return: ret})
+	m.PC = target
+	return nil
+}
+
+func (m *Machine) ret() {
+	frame := m.RAM[LCL]
+	ret := m.read(frame - 5)
+
+	m.write(m.RAM[ARG], m.pop())
+	m.RAM[SP] = m.RAM[ARG] + 1
+	m.RAM[THAT] = m.read(frame - 1)
+	m.RAM[THIS] = m.read(frame - 2)
+	m.RAM[ARG] = m.read(frame - 3)
+	m.RAM[LCL] = m.read(frame - 4)
+
+	if len(m.Frames) > 0 {
+		m.Frames = m.Frames[:len(m.Frames)-1]
+	}
+	m.PC = int(uint16(ret))
+}
+
+func boolean(b bool) int16 {
+	if b {
+		return -1
+	}
+	return 0
+}
@@ -0,0 +1,151 @@
+package vm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ChelseaDH/VMTranslator/program"
+)
+
+const factorial = `function Main.fact 0
+push argument 0
+push constant 1
+gt
+if-goto rec
+push constant 1
+return
+label rec
+push argument 0
+push argument 0
+push constant 1
+sub
+call Main.fact 1
+call Math.multiply 2
+return
+function Math.multiply 1
+label loop
+push argument 1
+push constant 0
+eq
+if-goto end
+push local 0
+push argument 0
+add
+pop local 0
+push argument 1
+push constant 1
+sub
+pop argument 1
+goto loop
+label end
+push local 0
+return
+function Sys.init 0
+push constant 5
+call Main.fact 1
+pop static 0
+push static 0
+pop temp 1
+call Sys.halt 0
+function Sys.halt 0
+label loop
+goto loop`
+
+type runTest struct {
+	input     string
+	expRAM    map[int]int16
+	expErr    error
+	expectErr bool
+}
+
+var runTests = []runTest{
+	{
+		input:  "push constant 7\npush constant 8\nadd\npush constant 3\nlt\npop temp 0\npush constant 2\nneg\npop temp 1",
+		expRAM: map[int]int16{0: 256, 5: 0, 6: -2},
+		expErr: ErrFinished,
+	},
+	{
+		input:  "push constant 3000\npop pointer 1\npush constant 9\npop that 2\npush that 2\npop static 4",
+		expRAM: map[int]int16{4: 3000, 3002: 9, 16: 9},
+		expErr: ErrFinished,
+	},
+	{
+		input:  factorial,
+		expRAM: map[int]int16{6: 120, 16: 120},
+		expErr: ErrHalted,
+	},
+	{
+		input:     "goto missing",
+		expectErr: true,
+	},
+	{
+		input:     "call Missing.f 0",
+		expectErr: true,
+	},
+	{
+		input:     "pop constant 1",
+		expectErr: true,
+	},
+}
+
+func TestMachine_Run(t *testing.T) {
+	for _, test := range runTests {
+		lines, err := program.Read("Test.vm", strings.NewReader(test.input))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		m, err := New(lines)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = m.Run(100000)
+		if test.expectErr {
+			if err == ErrFinished || err == ErrHalted {
+				t.Errorf("expected an error but none returned for %q", test.input)
+			}
+			continue
+		}
+
+		if err != test.expErr {
+			t.Errorf("expected %q but %q returned for %q", test.expErr, err, test.input)
+			continue
+		}
+
+		for address, value := range test.expRAM {
+			if m.RAM[address] != value {
+				t.Errorf("expected RAM[%d] to be %d but got %d for %q", address, value, m.RAM[address], test.input)
+			}
+		}
+	}
+}
+
+func TestMachine_Frames(t *testing.T) {
+	lines, err := program.Read("Test.vm", strings.NewReader(factorial))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := New(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Run until the innermost call to Main.fact, where the call stack is at its deepest
+	deepest := 0
+	for !m.Halted() {
+		err = m.Step()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(m.Frames) > deepest {
+			deepest = len(m.Frames)
+		}
+	}
+
+	// Sys.init, five calls to Main.fact and Sys.halt
+	if deepest != 6 || len(m.Frames) != 2 || m.Frames[1].Function != "Sys.halt" {
+		t.Errorf("expected 6 frames at the deepest and Sys.init, Sys.halt at the end, but got %d and %v", deepest, m.Frames)
+	}
+}
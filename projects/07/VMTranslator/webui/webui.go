@@ -0,0 +1,460 @@
+// Package webui serves a page for running a Hack program in a browser, like the course CPU emulator:
+// the screen, registers, RAM and ROM, the source the program was assembled from, and controls to run, step
+// and set breakpoints. The page is self contained and polls the server, which runs the program with a
+// debugger.Debugger.
+//
+// The server answers:
+//
+//	GET  /                           the page
+//	GET  /state                      the registers, why the program last stopped and the breakpoints, as JSON
+//	GET  /ram?start=SP&count=32      RAM words, from an address given as a number or symbol
+//	GET  /rom?start=LOOP&count=32    disassembled instructions, from an address given as a number or label
+//	GET  /screen                     the screen memory map, as 8K little endian words
+//	GET  /source                     the source lines, with the ROM address each starts
+//	POST /run, /pause, /reset
+//	POST /step?count=1, /back?count=1
+//	POST /breakpoint?address=12&set=true
+//	POST /key?code=65                sets the keyboard register, 0 when no key is pressed
+//
+// POST requests from a browser must come from the page itself: those whose Origin, or Referer when there is
+// no Origin, is another host are refused, so other sites cannot drive the program.
+package webui
+
+import (
+	_ "embed"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ChelseaDH/VMTranslator/debugger"
+	"github.com/ChelseaDH/VMTranslator/hack"
+	"github.com/ChelseaDH/VMTranslator/screen"
+)
+
+//go:embed index.html
+var page []byte
+
+const (
+	// batch is the number of instructions run at a time while the program runs, between which requests are answered.
+	batch = 20000
+	// maxCount is the most RAM words, instructions or steps a single request can ask for.
+	maxCount = 100000
+)
+
+// Server runs a program for the page.
+type Server struct {
+	// Rate is the most instructions run each second, 0 for no limit. Programs written for the course
+	// emulators can run too fast to play without one.
+	Rate uint64
+
+	mu          sync.Mutex
+	program     *hack.Program
+	disassembly *hack.Disassembly
+	history     int
+	d           *debugger.Debugger
+	name        string
+	source      []string
+	running     bool
+	// runs counts the times the program was set running, so that a batch left over from an earlier run stops
+	runs int
+	stop string
+	key  int16
+}
+
+// New returns a server for p, which was assembled from the named source. Up to history instructions can be
+// stepped back over.
+func New(p *hack.Program, name string, source []string, history int) *Server {
+	s := &Server{
+		program:     p,
+		disassembly: hack.Disassemble(p),
+		history:     history,
+		name:        name,
+		source:      source,
+	}
+	s.reset()
+	return s
+}
+
+// reset loads the program into a new CPU, keeping the breakpoints.
+func (s *Server) reset() {
+	var breakpoints []uint16
+	if s.d != nil {
+		breakpoints = s.d.Breakpoints()
+	}
+
+	s.d = debugger.New(s.program, s.history)
+	s.d.CPU.KeyboardInput = func() int16 {
+		return s.key
+	}
+	for _, address := range breakpoints {
+		s.d.SetBreakpoint(address, true)
+	}
+
+	s.running = false
+	s.stop = "Ready"
+}
+
+// Handler returns the handler serving the page and the requests it makes.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page)
+	})
+
+	get := func(path string, handle func(r *http.Request) (interface{}, error)) {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			s.mu.Lock()
+			response, err := handle(r)
+			s.mu.Unlock()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			if data, ok := response.([]byte); ok {
+				w.Header().Set("Content-Type", "application/octet-stream")
+				w.Write(data)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(response)
+		})
+	}
+	post := func(path string, handle func(r *http.Request) error) {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			if !sameOrigin(r) {
+				http.Error(w, "cross-origin request refused", http.StatusForbidden)
+				return
+			}
+			s.mu.Lock()
+			err := handle(r)
+			s.mu.Unlock()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+
+	get("/state", s.state)
+	get("/ram", s.ram)
+	get("/rom", s.rom)
+	get("/screen", s.screen)
+	get("/source", s.sourceLines)
+
+	post("/run", s.run)
+	post("/pause", func(r *http.Request) error {
+		if s.running {
+			s.running = false
+			s.stop = "Paused"
+		}
+		return nil
+	})
+	post("/reset", func(r *http.Request) error {
+		s.reset()
+		return nil
+	})
+	post("/step", s.step)
+	post("/back", s.back)
+	post("/breakpoint", s.breakpoint)
+	post("/key", func(r *http.Request) error {
+		code, err := strconv.ParseInt(r.FormValue("code"), 10, 16)
+		if err != nil {
+			return fmt.Errorf("invalid key code %s", r.FormValue("code"))
+		}
+		s.key = int16(code)
+		return nil
+	})
+
+	return mux
+}
+
+// sameOrigin reports whether r was sent by a page served from the host it was sent to. Requests with neither an
+// Origin nor a Referer header do not come from a browser page, and are allowed.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		origin = r.Header.Get("Referer")
+	}
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+type state struct {
+	PC       uint16 `json:"pc"`
+	A        int16  `json:"a"`
+	D        int16  `json:"d"`
+	Cycles   uint64 `json:"cycles"`
+	Location string `json:"location"`
+	// Line is the source line of the instruction at PC, or 0 if there is none.
+	Line        int      `json:"line"`
+	Running     bool     `json:"running"`
+	Stop        string   `json:"stop"`
+	Breakpoints []uint16 `json:"breakpoints"`
+}
+
+func (s *Server) state(r *http.Request) (interface{}, error) {
+	cpu := s.d.CPU
+	st := state{
+		PC:          cpu.PC,
+		A:           cpu.A,
+		D:           cpu.D,
+		Cycles:      cpu.Cycles,
+		Location:    s.d.Location(cpu.PC),
+		Running:     s.running,
+		Stop:        s.stop,
+		Breakpoints: s.d.Breakpoints(),
+	}
+	if int(cpu.PC) < len(s.program.Source) {
+		st.Line = s.program.Source[cpu.PC].Number
+	}
+	if st.Breakpoints == nil {
+		st.Breakpoints = []uint16{}
+	}
+	return st, nil
+}
+
+// count reads the count parameter of a request, which defaults to 1.
+func count(r *http.Request) (int, error) {
+	value := r.FormValue("count")
+	if value == "" {
+		return 1, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 || n > maxCount {
+		return 0, fmt.Errorf("count must be between 1 and %d", maxCount)
+	}
+	return n, nil
+}
+
+type ram struct {
+	Start  uint16  `json:"start"`
+	Values []int16 `json:"values"`
+}
+
+func (s *Server) ram(r *http.Request) (interface{}, error) {
+	start, err := s.d.RAMAddress(r.FormValue("start"))
+	if err != nil {
+		return nil, err
+	}
+	n, err := count(r)
+	if err != nil {
+		return nil, err
+	}
+
+	end := int(start) + n
+	if end > hack.RAMSize {
+		end = hack.RAMSize
+	}
+	// The response is encoded after the lock is released, so cannot share the CPU's RAM
+	values := append([]int16{}, s.d.CPU.RAM[start:end]...)
+	return ram{Start: start, Values: values}, nil
+}
+
+type instruction struct {
+	Address     int    `json:"address"`
+	Label       string `json:"label,omitempty"`
+	Instruction string `json:"instruction"`
+}
+
+func (s *Server) rom(r *http.Request) (interface{}, error) {
+	start, err := s.d.ROMAddress(r.FormValue("start"))
+	if err != nil {
+		return nil, err
+	}
+	n, err := count(r)
+	if err != nil {
+		return nil, err
+	}
+
+	instructions := []instruction{}
+	for address := int(start); address < int(start)+n && address < len(s.disassembly.Instructions); address++ {
+		text := s.disassembly.Instructions[address]
+		if text == "" {
+			text = fmt.Sprintf("%016b", s.disassembly.ROM[address])
+		}
+		instructions = append(instructions, instruction{
+			Address:     address,
+			Label:       s.disassembly.Labels[uint16(address)],
+			Instruction: text,
+		})
+	}
+	return instructions, nil
+}
+
+func (s *Server) screen(r *http.Request) (interface{}, error) {
+	data := make([]byte, screen.Words*2)
+	for i, word := range s.d.CPU.RAM[screen.Base : screen.Base+screen.Words] {
+		binary.LittleEndian.PutUint16(data[i*2:], uint16(word))
+	}
+	return data, nil
+}
+
+type source struct {
+	Name  string   `json:"name"`
+	Lines []string `json:"lines"`
+	// Addresses holds the address of the first instruction assembled from each line, or -1 for lines with none.
+	Addresses []int `json:"addresses"`
+}
+
+func (s *Server) sourceLines(r *http.Request) (interface{}, error) {
+	src := source{Name: s.name, Lines: s.source, Addresses: make([]int, len(s.source))}
+	if src.Lines == nil {
+		src.Lines = []string{}
+	}
+	for i := range src.Addresses {
+		src.Addresses[i] = -1
+	}
+	for address := len(s.program.Source) - 1; address >= 0; address-- {
+		if line := s.program.Source[address].Number; line >= 1 && line <= len(src.Addresses) {
+			src.Addresses[line-1] = address
+		}
+	}
+	return src, nil
+}
+
+func (s *Server) step(r *http.Request) error {
+	if s.running {
+		return errors.New("the program is running")
+	}
+	n, err := count(r)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < n; i++ {
+		stop, err := s.d.Step()
+		if err != nil {
+			s.stop = err.Error()
+			return nil
+		}
+		if stop.Reason != debugger.Stepped {
+			s.stop = s.describe(stop)
+			return nil
+		}
+	}
+	s.stop = s.describe(debugger.Stop{Reason: debugger.Stepped})
+	return nil
+}
+
+func (s *Server) back(r *http.Request) error {
+	if s.running {
+		return errors.New("the program is running")
+	}
+	n, err := count(r)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < n; i++ {
+		if !s.d.Back() {
+			s.stop = "No more history to step back through"
+			return nil
+		}
+	}
+	s.stop = "Stepped back to " + s.d.Location(s.d.CPU.PC)
+	return nil
+}
+
+func (s *Server) breakpoint(r *http.Request) error {
+	address, err := s.d.ROMAddress(r.FormValue("address"))
+	if err != nil {
+		return err
+	}
+	set, err := strconv.ParseBool(r.FormValue("set"))
+	if err != nil {
+		return fmt.Errorf("set must be true or false")
+	}
+
+	s.d.SetBreakpoint(address, set)
+	return nil
+}
+
+// run starts running the program in the background, a batch of instructions at a time.
+func (s *Server) run(r *http.Request) error {
+	if s.running {
+		return nil
+	}
+	s.running = true
+	s.runs++
+	go s.runBatches(s.runs)
+	return nil
+}
+
+func (s *Server) runBatches(run int) {
+	s.mu.Lock()
+	start, startCycles := time.Now(), s.d.CPU.Cycles
+	// Limited runs take batches of a fiftieth of a second, so that they run smoothly
+	s.d.Limit = batch
+	if s.Rate != 0 && s.Rate/50 < batch {
+		s.d.Limit = s.Rate/50 + 1
+	}
+	s.mu.Unlock()
+
+	for {
+		s.mu.Lock()
+		if !s.running || s.runs != run {
+			s.mu.Unlock()
+			return
+		}
+
+		stop, err := s.d.Continue(nil)
+		switch {
+		case err != nil:
+			s.running = false
+			s.stop = err.Error()
+		case stop.Reason != debugger.Limit:
+			s.running = false
+			s.stop = s.describe(stop)
+		}
+		cycles := s.d.CPU.Cycles
+		s.mu.Unlock()
+
+		if s.Rate != 0 {
+			// Sleep until the instructions run so far are due, which also lets other requests take the lock
+			due := start.Add(time.Duration(float64(time.Second) * float64(cycles-startCycles) / float64(s.Rate)))
+			if wait := time.Until(due); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+	}
+}
+
+func (s *Server) describe(stop debugger.Stop) string {
+	location := s.d.Location(s.d.CPU.PC)
+	switch stop.Reason {
+	case debugger.Breakpoint:
+		return "Breakpoint at " + location
+	case debugger.Halted:
+		return "Halted at " + location
+	case debugger.Watchpoint:
+		return fmt.Sprintf("RAM[%d] changed from %d to %d", stop.Address, stop.Old, stop.New)
+	default:
+		return "Stopped at " + location
+	}
+}
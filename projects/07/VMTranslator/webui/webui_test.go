@@ -0,0 +1,172 @@
+package webui
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ChelseaDH/VMTranslator/hack"
+)
+
+// program adds up the keyboard register into sum until it reaches 100, then draws on the screen and halts.
+const program = `(LOOP)
+@KBD
+D=M
+@sum
+M=M+D
+D=M
+@100
+D=D-A
+@LOOP
+D;JLT
+@SCREEN
+M=-1
+(END)
+@END
+0;JMP`
+
+func newServer(t *testing.T) *httptest.Server {
+	p, err := hack.Assemble(strings.NewReader(program))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := httptest.NewServer(New(p, "Sum.asm", strings.Split(program, "\n"), 100).Handler())
+	t.Cleanup(s.Close)
+	return s
+}
+
+type requestTest struct {
+	method    string
+	path      string
+	expStatus int
+	// expBody is part of the body expected in the response
+	expBody string
+}
+
+var requestTests = []requestTest{
+	{method: "GET", path: "/", expStatus: http.StatusOK, expBody: `<canvas id="screen"`},
+	{method: "GET", path: "/missing", expStatus: http.StatusNotFound},
+	{method: "POST", path: "/state", expStatus: http.StatusMethodNotAllowed},
+	{method: "GET", path: "/state", expStatus: http.StatusOK, expBody: `"pc":0,"a":0,"d":0,"cycles":0,"location":"LOOP","line":2,"running":false,"stop":"Ready","breakpoints":[]`},
+	{method: "POST", path: "/key?code=7", expStatus: http.StatusNoContent},
+	{method: "POST", path: "/step?count=5", expStatus: http.StatusNoContent},
+	{method: "GET", path: "/state", expStatus: http.StatusOK, expBody: `"pc":5,"a":16,"d":7,"cycles":5,"location":"LOOP+5","line":7`},
+	{method: "GET", path: "/ram?start=sum&count=2", expStatus: http.StatusOK, expBody: `{"start":16,"values":[7,0]}`},
+	{method: "POST", path: "/back?count=2", expStatus: http.StatusNoContent},
+	{method: "GET", path: "/ram?start=16&count=1", expStatus: http.StatusOK, expBody: `{"start":16,"values":[0]}`},
+	{method: "GET", path: "/rom?start=END&count=5", expStatus: http.StatusOK, expBody: `[{"address":11,"label":"END","instruction":"@END"},{"address":12,"instruction":"0;JMP"}]`},
+	{method: "GET", path: "/source", expStatus: http.StatusOK, expBody: `"addresses":[-1,0,1,2,3,4,5,6,7,8,9,10,-1,11,12]`},
+	{method: "POST", path: "/breakpoint?address=10&set=true", expStatus: http.StatusNoContent},
+	{method: "POST", path: "/breakpoint?address=NOWHERE&set=true", expStatus: http.StatusBadRequest, expBody: "unknown label NOWHERE"},
+	{method: "POST", path: "/step?count=0", expStatus: http.StatusBadRequest},
+	{method: "GET", path: "/ram?start=nothing", expStatus: http.StatusBadRequest},
+}
+
+func do(t *testing.T, s *httptest.Server, method string, path string) (int, string) {
+	request, err := http.NewRequest(method, s.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response.StatusCode, string(body)
+}
+
+func TestServer_Requests(t *testing.T) {
+	s := newServer(t)
+	for _, test := range requestTests {
+		status, body := do(t, s, test.method, test.path)
+		if status != test.expStatus {
+			t.Errorf("expected %s %s to return %d, got %d: %s", test.method, test.path, test.expStatus, status, body)
+		}
+		if !strings.Contains(body, test.expBody) {
+			t.Errorf("expected %s %s to return %q, got %q", test.method, test.path, test.expBody, body)
+		}
+	}
+}
+
+func TestServer_CrossOrigin(t *testing.T) {
+	s := newServer(t)
+	for _, test := range []struct {
+		header, value string
+		expStatus     int
+	}{
+		{header: "Origin", value: s.URL, expStatus: http.StatusNoContent},
+		{header: "Referer", value: s.URL + "/", expStatus: http.StatusNoContent},
+		{header: "Origin", value: "http://example.com", expStatus: http.StatusForbidden},
+		{header: "Referer", value: "http://example.com/page", expStatus: http.StatusForbidden},
+		{header: "Origin", value: "null", expStatus: http.StatusForbidden},
+	} {
+		request, err := http.NewRequest("POST", s.URL+"/key?code=65", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.Header.Set(test.header, test.value)
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+
+		if response.StatusCode != test.expStatus {
+			t.Errorf("expected a POST with %s %s to return %d, got %d", test.header, test.value, test.expStatus, response.StatusCode)
+		}
+	}
+}
+
+// wait polls the state until the program stops running.
+func wait(t *testing.T, s *httptest.Server) state {
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(time.Millisecond) {
+		_, body := do(t, s, "GET", "/state")
+		var st state
+		err := json.Unmarshal([]byte(body), &st)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !st.Running {
+			return st
+		}
+	}
+	t.Fatal("the program did not stop running")
+	return state{}
+}
+
+func TestServer_Run(t *testing.T) {
+	s := newServer(t)
+	do(t, s, "POST", "/breakpoint?address=10&set=true")
+	do(t, s, "POST", "/key?code=30")
+
+	do(t, s, "POST", "/run")
+	if st := wait(t, s); st.PC != 10 || st.Stop != "Breakpoint at LOOP+10" {
+		t.Errorf("expected to stop at the breakpoint, got %+v", st)
+	}
+
+	do(t, s, "POST", "/run")
+	if st := wait(t, s); st.PC != 11 || st.Stop != "Halted at END" {
+		t.Errorf("expected the program to halt, got %+v", st)
+	}
+
+	_, screen := do(t, s, "GET", "/screen")
+	if len(screen) != 16384 || screen[0] != 0xff || screen[1] != 0xff || screen[2] != 0 {
+		t.Errorf("expected the first word of the screen to be set")
+	}
+
+	// Resetting keeps the breakpoints
+	do(t, s, "POST", "/reset")
+	_, body := do(t, s, "GET", "/state")
+	if !strings.Contains(body, `"pc":0,`) || !strings.Contains(body, `"breakpoints":[10]`) {
+		t.Errorf("expected the program to be reset with its breakpoint, got %s", body)
+	}
+}
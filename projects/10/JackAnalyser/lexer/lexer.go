@@ -20,6 +20,7 @@ type Lexer struct {
 	reader *bufio.Reader
 
 	cr    rune // current character
+	line  int  // line of the current character
 	isEOF bool
 	err   error
 }
@@ -27,9 +28,15 @@ type Lexer struct {
 func NewLexer(input io.Reader) *Lexer {
 	return &Lexer{
 		reader: bufio.NewReader(input),
+		line:   1,
 	}
 }
 
+// Line returns the line number of the last token returned by Next.
+func (l *Lexer) Line() int {
+	return l.line
+}
+
 func (l *Lexer) nextRune() {
 	r, _, err := l.reader.ReadRune()
 	if err != nil {
@@ -37,6 +44,17 @@ func (l *Lexer) nextRune() {
 	}
 
 	l.cr = r
+	if err == nil && r == '\n' {
+		l.line++
+	}
+}
+
+// Puts the current character back to be read again, so that it is only counted once if it is a newline.
+func (l *Lexer) unreadRune() {
+	if l.cr == '\n' {
+		l.line--
+	}
+	l.reader.UnreadRune()
 }
 
 func (l *Lexer) parseError(err error) {
@@ -96,7 +114,7 @@ func (l *Lexer) Next() (token.Token, string, error) {
 				return l.Next()
 			}
 		} else {
-			l.reader.UnreadRune()
+			l.unreadRune()
 			return token.Div, "/", nil
 		}
 		break
@@ -134,7 +152,7 @@ func (l *Lexer) scanIdentifier() (token.Token, string) {
 		if isLetter(l.cr) || unicode.IsDigit(l.cr) {
 			runes = append(runes, l.cr)
 		} else {
-			l.reader.UnreadRune()
+			l.unreadRune()
 			break
 		}
 	}
@@ -162,7 +180,7 @@ func (l *Lexer) scanNumber() (token.Token, string, error) {
 		if unicode.IsDigit(l.cr) {
 			runes = append(runes, l.cr)
 		} else {
-			l.reader.UnreadRune()
+			l.unreadRune()
 			break
 		}
 	}
@@ -212,6 +230,7 @@ func (l *Lexer) scanInLineComment() bool {
 		return false
 	}
 
+	l.line++
 	return true
 }
 
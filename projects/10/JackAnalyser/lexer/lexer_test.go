@@ -3,6 +3,7 @@ package lexer
 import (
 	"bufio"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -215,3 +216,66 @@ func TestNext_File(t *testing.T) {
 		}
 	}
 }
+
+type lineTest struct {
+	input    string
+	expLines []int
+}
+
+var lineTests = []lineTest{
+	{input: "let x\n= 1;", expLines: []int{1, 1, 2, 2, 2}},
+	{input: "// comment\n\n  do /* one\ntwo */ x\n", expLines: []int{3, 4}},
+	{input: "a/\nb", expLines: []int{1, 1, 2}},
+}
+
+func TestLexer_Line(t *testing.T) {
+	for _, test := range lineTests {
+		lexer := NewLexer(strings.NewReader(test.input))
+
+		for i, expLine := range test.expLines {
+			_, _, err := lexer.Next()
+			if err != nil {
+				t.Fatalf("did not expect an error, but %q returned for %q", err, test.input)
+			}
+
+			if lexer.Line() != expLine {
+				t.Errorf("expected token %d to be on line %d but got %d for %q", i, expLine, lexer.Line(), test.input)
+			}
+		}
+	}
+}
+
+// FuzzLexer checks that the lexer always reaches the end of its input, never reports a line past the last,
+// and returns an error rather than panicking on anything it cannot read.
+func FuzzLexer(f *testing.F) {
+	files, _ := filepath.Glob("../TestFiles/*.jack")
+	for _, name := range files {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(data))
+	}
+	f.Add("let s = \"unterminated")
+	f.Add("/* unterminated comment")
+	f.Add("let x = 32768;")
+
+	f.Fuzz(func(t *testing.T, input string) {
+		lexer := NewLexer(strings.NewReader(input))
+		lines := strings.Count(input, "\n") + 1
+
+		// Every token but the last consumes at least one character
+		for i := 0; i <= len(input)+1; i++ {
+			tok, _, err := lexer.Next()
+			if lexer.Line() < 1 || lexer.Line() > lines {
+				t.Fatalf("line %d is outside the %d lines of %q", lexer.Line(), lines, input)
+			}
+
+			if err != nil || tok == token.End || tok == token.Error {
+				return
+			}
+		}
+
+		t.Fatalf("no end of input after %d tokens of %q", len(input)+2, input)
+	})
+}
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"path"
+	"runtime"
 	"strings"
+	"sync"
 
 	"github.com/ChelseaDH/JackAnalyser/lexer"
 	"github.com/ChelseaDH/JackAnalyser/parser"
@@ -15,12 +18,20 @@ const inputFileExt = ".jack"
 const outputFileExt = ".vm"
 
 func main() {
-	args := os.Args
-	if len(args) != 2 {
+	workers := flag.Int("workers", runtime.NumCPU(), "number of files to compile at once")
+	symbols := flag.Bool("symbols", false, fmt.Sprintf("write a %s symbol file for debuggers alongside each %s file", parser.SymbolFileExt, outputFileExt))
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) != 1 {
 		log.Fatal("Incorrect number of command line arguments provided")
 	}
 
-	name := args[1]
+	if *workers < 1 {
+		log.Fatal("At least one worker is required")
+	}
+
+	name := args[0]
 	fileInfo, err := os.Stat(name)
 	if err != nil {
 		log.Fatal(err)
@@ -45,35 +56,85 @@ func main() {
 		}
 
 	default:
-		log.Fatal(fmt.Sprintf("Second command line argument must be a %s file or directory containing one or more %s files", inputFileExt, inputFileExt))
+		log.Fatal(fmt.Sprintf("Command line argument must be a %s file or directory containing one or more %s files", inputFileExt, inputFileExt))
+	}
+
+	failed := false
+	for _, err := range handleFiles(filePaths, *workers, *symbols) {
+		if err != nil {
+			log.Print(err)
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// handleFiles compiles each file using a pool of workers.
+// Every file is compiled to its own output, so the order they finish in does not matter,
+// and the errors are returned in the same order as filePaths.
+func handleFiles(filePaths []string, workers int, symbols bool) []error {
+	errs := make([]error, len(filePaths))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				errs[i] = handleFile(filePaths[i], symbols)
+			}
+		}()
 	}
 
-	for _, filePath := range filePaths {
-		handleFile(filePath)
+	for i := range filePaths {
+		jobs <- i
 	}
+	close(jobs)
+	wg.Wait()
+
+	return errs
 }
 
-func handleFile(filePath string) {
+func handleFile(filePath string, symbols bool) (err error) {
+	// Compiling an invalid class panics, which would otherwise take down every worker
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("%s: %v", filePath, recovered)
+		}
+	}()
+
 	inputFile, err := os.Open(filePath)
 	if err != nil {
-		log.Print(err)
-		return
+		return err
 	}
 	defer inputFile.Close()
 
 	outputFile, err := os.OpenFile(strings.Replace(filePath, inputFileExt, outputFileExt, 1), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
-		log.Print(err)
-		return
+		return err
 	}
 	defer outputFile.Close()
 
 	p := parser.NewParser(lexer.NewLexer(inputFile))
 	class, err := p.Parse()
 	if err != nil {
-		log.Print(err)
-		return
+		return fmt.Errorf("%s: %s", filePath, err)
+	}
+
+	if !symbols {
+		parser.WriteClassToFile(class, outputFile)
+		return nil
+	}
+
+	symbolFile, err := os.OpenFile(strings.Replace(filePath, inputFileExt, parser.SymbolFileExt, 1), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
 	}
+	defer symbolFile.Close()
 
-	parser.WriteClassToFile(class, outputFile)
+	return parser.WriteClassWithSymbols(class, outputFile, symbolFile)
 }
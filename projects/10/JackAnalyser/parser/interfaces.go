@@ -25,6 +25,7 @@ type Param struct {
 }
 
 type JackSubroutine struct {
+	Line       int
 	SType      token.Token
 	ReturnType Type
 	SName      string
@@ -44,27 +45,32 @@ type Statement interface{
 }
 
 type LetStatement struct {
+	Line  int
 	Name  string
 	Index Expression
 	Value Expression
 }
 
 type IfStatement struct {
+	Line      int
 	Condition Expression
 	Body      []Statement
 	Else      []Statement
 }
 
 type WhileStatement struct {
+	Line      int
 	Condition Expression
 	Body      []Statement
 }
 
 type DoStatement struct {
+	Line int
 	Call SubroutineCall
 }
 
 type ReturnStatement struct {
+	Line  int
 	Value Expression
 }
 
@@ -16,6 +16,7 @@ type Parser struct {
 	current          token.Token
 	next             token.Token
 	value, nextValue string
+	line, nextLine   int
 }
 
 func NewParser(lexer *lexer.Lexer) Parser {
@@ -68,8 +69,9 @@ func (p *Parser) Parse() (j *JackClass, err error) {
 
 func (p *Parser) advance() {
 	var err error
-	p.current, p.value = p.next, p.nextValue
+	p.current, p.value, p.line = p.next, p.nextValue, p.nextLine
 	p.next, p.nextValue, err = p.lexer.Next()
+	p.nextLine = p.lexer.Line()
 	if err != nil {
 		panic(err)
 	}
@@ -145,6 +147,7 @@ func (p *Parser) parseSubroutine() *JackSubroutine {
 	default:
 		return nil
 	}
+	line := p.line
 
 	var typ Type
 	if p.accept(token.Void) {
@@ -186,6 +189,7 @@ func (p *Parser) parseSubroutine() *JackSubroutine {
 	vars, statements := p.parseSubroutineBody()
 
 	return &JackSubroutine{
+		Line:       line,
 		SType:      sTyp,
 		ReturnType: typ,
 		SName:      name,
@@ -294,6 +298,7 @@ func (p *Parser) parseLetStatement() Statement {
 	} else {
 		return nil
 	}
+	line := p.line
 
 	p.expect(token.Identifier)
 	name := p.value
@@ -310,6 +315,7 @@ func (p *Parser) parseLetStatement() Statement {
 	p.expect(token.SemiColon)
 
 	return &LetStatement{
+		Line:  line,
 		Name:  name,
 		Index: index,
 		Value: expression,
@@ -322,6 +328,7 @@ func (p *Parser) parseIfStatement() Statement {
 	} else {
 		return nil
 	}
+	line := p.line
 
 	p.expect(token.LeftParen)
 	condition := p.parseExpression()
@@ -340,6 +347,7 @@ func (p *Parser) parseIfStatement() Statement {
 	}
 
 	return &IfStatement{
+		Line:      line,
 		Condition: condition,
 		Body:      body,
 		Else:      elseBody,
@@ -352,6 +360,7 @@ func (p *Parser) parseWhileStatement() Statement {
 	} else {
 		return nil
 	}
+	line := p.line
 
 	p.expect(token.LeftParen)
 	condition := p.parseExpression()
@@ -362,6 +371,7 @@ func (p *Parser) parseWhileStatement() Statement {
 	p.expect(token.RightBrace)
 
 	return &WhileStatement{
+		Line:      line,
 		Condition: condition,
 		Body:      statements,
 	}
@@ -373,12 +383,13 @@ func (p *Parser) parseDoStatement() Statement {
 	} else {
 		return nil
 	}
+	line := p.line
 
 	p.expect(token.Identifier)
 	call := p.parseSubroutineCall()
 	p.expect(token.SemiColon)
 
-	return &DoStatement{Call: call}
+	return &DoStatement{Line: line, Call: call}
 }
 
 func (p *Parser) parseReturnStatement() Statement {
@@ -387,6 +398,7 @@ func (p *Parser) parseReturnStatement() Statement {
 	} else {
 		return nil
 	}
+	line := p.line
 
 	var value Expression
 	if !p.accept(token.SemiColon) {
@@ -395,7 +407,7 @@ func (p *Parser) parseReturnStatement() Statement {
 
 	p.expect(token.SemiColon)
 
-	return &ReturnStatement{Value: value}
+	return &ReturnStatement{Line: line, Value: value}
 }
 
 func (p *Parser) parseSubroutineCall() SubroutineCall {
@@ -1,8 +1,12 @@
 package parser
 
 import (
+	"io"
 	"os"
+	"path/filepath"
 	"reflect"
+	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/ChelseaDH/JackAnalyser/lexer"
@@ -45,6 +49,7 @@ var parserTests = []parserTest{
 			},
 			Subroutines: []JackSubroutine{
 				{
+					Line:  5,
 					SType: token.Constructor,
 					ReturnType: Type{
 						Token: token.Identifier,
@@ -65,8 +70,14 @@ var parserTests = []parserTest{
 							Name: "y",
 						},
 					},
+					Statements: []Statement{
+						&LetStatement{Line: 6, Name: "x", Value: &VarName{Name: "x"}},
+						&LetStatement{Line: 7, Name: "y", Value: &VarName{Name: "y"}},
+						&ReturnStatement{Line: 8, Value: &ThisConstant{}},
+					},
 				},
 				{
+					Line:  11,
 					SType: token.Function,
 					ReturnType: Type{
 						Token: token.Void,
@@ -75,11 +86,15 @@ var parserTests = []parserTest{
 					Vars: []VarDec{
 						{
 							Type: Type{
-								Token: token.Int,
+								Token: token.Boolean,
 							},
-							Name: "a",
+							Name: "steve",
 						},
 					},
+					Statements: []Statement{
+						&LetStatement{Line: 13, Name: "steve", Value: &BooleanConstant{Value: true}},
+						&WhileStatement{Line: 15, Condition: &VarName{Name: "steve"}, Body: []Statement{}},
+					},
 				},
 			},
 		},
@@ -108,3 +123,36 @@ func TestParser_Parse(t *testing.T) {
 		}
 	}
 }
+
+// FuzzParser checks that the parser and compiler report invalid classes, which they do by returning or panicking
+// with an error or message, and never fail with a runtime error such as an index out of range.
+func FuzzParser(f *testing.F) {
+	files, _ := filepath.Glob("../TestFiles/*.jack")
+	for _, name := range files {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(data))
+	}
+	f.Add("class Main { function void main() { var Array a; let a[1] = -(2 * x); do Main.main(); return; } }")
+	f.Add("class Main { method int f() { if (~(this = null)) { return f(); } else { return 1; } } }")
+
+	f.Fuzz(func(t *testing.T, input string) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				if err, ok := recovered.(runtime.Error); ok {
+					t.Fatalf("%s for %q", err, input)
+				}
+			}
+		}()
+
+		p := NewParser(lexer.NewLexer(strings.NewReader(input)))
+		class, err := p.Parse()
+		if err != nil {
+			return
+		}
+
+		WriteClassToFile(class, io.Discard)
+	})
+}
@@ -0,0 +1,80 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ChelseaDH/JackAnalyser/token"
+)
+
+// SymbolFileExt is the extension of the symbol files written alongside each .vm file.
+const SymbolFileExt = ".jsym"
+
+// WriteClassWithSymbols compiles class to file, writing the names, types and source lines a debugger needs
+// to map the VM code back to the Jack source to symbols.
+//
+// The symbol file is line based. The class is described first, followed by each subroutine in turn
+// and then the line table:
+//
+//	class Main
+//	static 0 int count
+//	field 0 Array cells
+//	subroutine method Main.draw 12
+//	argument 0 Main this
+//	local 0 int i
+//	line 1 12
+//	line 5 13
+//
+// Variables are listed with their segment index and type. A subroutine entry gives its kind, VM function name
+// and the Jack line it is declared on. Each line entry states that the VM code from that line of the .vm file
+// onwards was compiled from that Jack line, until the next entry.
+func WriteClassWithSymbols(class *JackClass, file io.Writer, symbols io.Writer) error {
+	w := &FileWriter{File: file}
+	class.toVm(w)
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("class %s\n", class.Name))
+
+	staticCount, fieldCount := 0, 0
+	for _, vd := range class.VarDecs {
+		if vd.Static {
+			b.WriteString(fmt.Sprintf("static %d %s %s\n", staticCount, typeName(vd.VarDec.Type), vd.VarDec.Name))
+			staticCount++
+		} else {
+			b.WriteString(fmt.Sprintf("field %d %s %s\n", fieldCount, typeName(vd.VarDec.Type), vd.VarDec.Name))
+			fieldCount++
+		}
+	}
+
+	for _, s := range class.Subroutines {
+		b.WriteString(fmt.Sprintf("subroutine %s %s.%s %d\n", s.SType.String(), class.Name, s.SName, s.Line))
+
+		argCount := 0
+		if s.SType == token.Method {
+			b.WriteString(fmt.Sprintf("argument 0 %s this\n", class.Name))
+			argCount++
+		}
+		for _, p := range s.ParamList {
+			b.WriteString(fmt.Sprintf("argument %d %s %s\n", argCount, typeName(p.Type), p.Name))
+			argCount++
+		}
+		for j, v := range s.Vars {
+			b.WriteString(fmt.Sprintf("local %d %s %s\n", j, typeName(v.Type), v.Name))
+		}
+	}
+
+	for _, l := range w.Lines {
+		b.WriteString(fmt.Sprintf("line %d %d\n", l.VM, l.Jack))
+	}
+
+	_, err := io.WriteString(symbols, b.String())
+	return err
+}
+
+func typeName(t Type) string {
+	if t.Token == token.Identifier {
+		return t.Class
+	}
+	return t.Token.String()
+}
@@ -11,6 +11,7 @@ import (
 
 type instructionWriter interface {
 	Write(string)
+	markLine(line int)
 	getCondCount() int
 	incrementCondCount()
 }
@@ -23,6 +24,7 @@ type TestWriter struct {
 func (w *TestWriter) Write(s string) {
 	w.output = append(w.output, s)
 }
+func (w *TestWriter) markLine(line int) {}
 func (w *TestWriter) getCondCount() int {
 	return w.condCount
 }
@@ -30,13 +32,25 @@ func (w *TestWriter) incrementCondCount() {
 	w.condCount++
 }
 
+// SourceLine records that the code written from line VM of the output onwards was compiled from line Jack of the class.
+type SourceLine struct {
+	VM   int
+	Jack int
+}
+
 type FileWriter struct {
 	File      io.Writer
+	Lines     []SourceLine
 	condCount int
+	written   int
 }
 
 func (w *FileWriter) Write(s string) {
 	fmt.Fprintf(w.File, fmt.Sprintf("%s\n", s))
+	w.written++
+}
+func (w *FileWriter) markLine(line int) {
+	w.Lines = append(w.Lines, SourceLine{VM: w.written + 1, Jack: line})
 }
 func (w *FileWriter) getCondCount() int {
 	return w.condCount
@@ -156,6 +170,7 @@ func (s *JackSubroutine) toVm(scope ClassScope, writer instructionWriter) {
 		localCount++
 	}
 
+	writer.markLine(s.Line)
 	writer.Write(fmt.Sprintf("function %s.%s %d", scope.Name, s.SName, localCount))
 
 	// For constructor:
@@ -180,12 +195,13 @@ func (s *JackSubroutine) toVm(scope ClassScope, writer instructionWriter) {
 }
 
 func (s LetStatement) toVm(classScope ClassScope, routineScope map[string]variable, writer instructionWriter) {
+	writer.markLine(s.Line)
 	variableInScope := findVariableInScope(s.Name, classScope, routineScope)
 
 	// Handle assigning to an array
 	// Avoid conflicting use of pointer if Value is an array access expression
 	if s.Index != nil {
-		writer.Write(fmt.Sprintf("push %s %d", variableKindMap[variableInScope.kind], variableInScope.position))
+		writeVariable("push", variableInScope, writer)
 		s.Index.toVm(classScope, routineScope, writer)
 		writer.Write("add") // top stack value = base addr of array + Index
 
@@ -203,6 +219,7 @@ func (s LetStatement) toVm(classScope ClassScope, routineScope map[string]variab
 }
 
 func (s IfStatement) toVm(classScope ClassScope, routineScope map[string]variable, writer instructionWriter) {
+	writer.markLine(s.Line)
 	label1 := generateCondLabel(classScope.Name, writer)
 	label2 := generateCondLabel(classScope.Name, writer)
 
@@ -225,6 +242,7 @@ func (s IfStatement) toVm(classScope ClassScope, routineScope map[string]variabl
 }
 
 func (s WhileStatement) toVm(classScope ClassScope, routineScope map[string]variable, writer instructionWriter) {
+	writer.markLine(s.Line)
 	label1 := generateCondLabel(classScope.Name, writer)
 	label2 := generateCondLabel(classScope.Name, writer)
 
@@ -242,12 +260,14 @@ func (s WhileStatement) toVm(classScope ClassScope, routineScope map[string]vari
 }
 
 func (s DoStatement) toVm(classScope ClassScope, routineScope map[string]variable, writer instructionWriter) {
+	writer.markLine(s.Line)
 	s.Call.toVm(classScope, routineScope, writer)
 	// dump return value of call
 	writer.Write("pop temp 0")
 }
 
 func (s ReturnStatement) toVm(classScope ClassScope, routineScope map[string]variable, writer instructionWriter) {
+	writer.markLine(s.Line)
 	if s.Value == nil {
 		// Functions must return a value to the stack, use dummy value when there is no return
 		IntegerConst{Value: 0}.toVm(classScope, routineScope, writer)
@@ -310,7 +330,7 @@ func (a ArrayAccess) toVm(classScope ClassScope, routineScope map[string]variabl
 	array := findVariableInScope(a.Name, classScope, routineScope)
 
 	a.Index.toVm(classScope, routineScope, writer)
-	writer.Write(fmt.Sprintf("push %s %d", variableKindMap[array.kind], array.position))
+	writeVariable("push", array, writer)
 
 	writer.Write("add")
 	writer.Write("pop pointer 1")
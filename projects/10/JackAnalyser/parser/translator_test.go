@@ -9,7 +9,7 @@ import (
 
 type expressionTest struct {
 	input        string
-	classScope   map[string]variable
+	classScope   ClassScope
 	routineScope map[string]variable
 	expOutput    []string
 }
@@ -17,14 +17,20 @@ type expressionTest struct {
 var expressionTests = []expressionTest{
 	{
 		input: "x + g(2, y, -z) * 5",
-		classScope: map[string]variable{
-			"x": {
-				typ: Type{
-					Token: token.Int,
-					Class: "",
+		classScope: ClassScope{
+			Name: "Main",
+			SymbolTable: map[string]variable{
+				"x": {
+					typ: Type{
+						Token: token.Int,
+						Class: "",
+					},
+					kind:     Static,
+					position: 0,
 				},
-				kind:     Static,
-				position: 0,
+			},
+			SubroutineTable: map[string]token.Token{
+				"Main.g": token.Function,
 			},
 		},
 		routineScope: map[string]variable{
@@ -45,7 +51,50 @@ var expressionTests = []expressionTest{
 				position: 0,
 			},
 		},
-		expOutput: []string{"push static 0", "push 2", "push argument 0", "push local 0", "-", "call g", "push 5", "*", "+"},
+		// Operators are right associative, so the multiplication is done before the addition
+		expOutput: []string{
+			"push static 0", "push constant 2", "push argument 0", "push local 0", "neg", "call Main.g 3",
+			"push constant 5", "call Math.multiply 2", "add",
+		},
+	},
+	{
+		// A field array is pushed from the this segment, like any other field
+		input: "a[i] + b[1]",
+		classScope: ClassScope{
+			Name: "Main",
+			SymbolTable: map[string]variable{
+				"a": {
+					typ: Type{
+						Token: token.Identifier,
+						Class: "Array",
+					},
+					kind:     Field,
+					position: 2,
+				},
+			},
+		},
+		routineScope: map[string]variable{
+			"i": {
+				typ: Type{
+					Token: token.Int,
+					Class: "",
+				},
+				kind:     Local,
+				position: 0,
+			},
+			"b": {
+				typ: Type{
+					Token: token.Identifier,
+					Class: "Array",
+				},
+				kind:     Argument,
+				position: 1,
+			},
+		},
+		expOutput: []string{
+			"push local 0", "push this 2", "add", "pop pointer 1", "push that 0",
+			"push constant 1", "push argument 1", "add", "pop pointer 1", "push that 0", "add",
+		},
 	},
 }
 
@@ -56,7 +105,7 @@ func TestExpression(t *testing.T) {
 		w := &TestWriter{}
 		expression.toVm(test.classScope, test.routineScope, w)
 
-		if reflect.DeepEqual(test.expOutput, w.output) {
+		if !reflect.DeepEqual(test.expOutput, w.output) {
 			t.Errorf("expected output %v got %v", test.expOutput, w.output)
 		}
 	}